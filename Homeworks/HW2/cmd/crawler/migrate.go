@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yourname/go-image-crawler/internal/storage"
+)
+
+// runMigrate implements "crawler migrate up|down|status".
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	mysqlDSN := fs.String("mysql", "", "MySQL DSN (e.g. user:pass@tcp(host:3306)/db?parseTime=true)")
+	dbDSN := fs.String("db", "", "database DSN: mysql://... or sqlite://images.db (overrides -mysql)")
+	steps := fs.Int("steps", 1, "number of migrations to revert with down")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: crawler migrate [flags] up|down|status")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	dsn := *dbDSN
+	if dsn == "" {
+		dsn = *mysqlDSN
+	}
+	if dsn == "" {
+		fmt.Fprintln(os.Stderr, "error: -db or -mysql is required")
+		return 2
+	}
+
+	repo, err := storage.Open(dsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "db:", err)
+		return 1
+	}
+	defer repo.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	switch fs.Arg(0) {
+	case "up":
+		applied, err := repo.MigrateUp(ctx)
+		for _, v := range applied {
+			fmt.Printf("applied %04d\n", v)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate up:", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		reverted, err := repo.MigrateDown(ctx, *steps)
+		for _, v := range reverted {
+			fmt.Printf("reverted %04d\n", v)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate down:", err)
+			return 1
+		}
+	case "status":
+		st, err := repo.MigrationStatus(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate status:", err)
+			return 1
+		}
+		for _, s := range st {
+			switch {
+			case s.Unknown:
+				fmt.Printf("%04d  %-24s applied %s (unknown to this binary)\n", s.Version, "?", s.AppliedAt.Format(time.RFC3339))
+			case s.Applied:
+				fmt.Printf("%04d  %-24s applied %s\n", s.Version, s.Name, s.AppliedAt.Format(time.RFC3339))
+			default:
+				fmt.Printf("%04d  %-24s pending\n", s.Version, s.Name)
+			}
+		}
+	default:
+		fs.Usage()
+		return 2
+	}
+	return 0
+}
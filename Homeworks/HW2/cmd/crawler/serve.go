@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/yourname/go-image-crawler/internal/jobs"
+	"github.com/yourname/go-image-crawler/internal/metrics"
+)
+
+// runServe implements "crawler serve": crawls from a jobs file on their
+// schedules, with job status served over HTTP.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	mysqlDSN := fs.String("mysql", "", "MySQL DSN (e.g. user:pass@tcp(host:3306)/db?parseTime=true)")
+	dbDSN := fs.String("db", "", "database DSN: mysql://... or sqlite://images.db (overrides -mysql)")
+	migrate := fs.Bool("migrate", true, "apply pending schema migrations on startup")
+	jobsPath := fs.String("jobs", "", "jobs file (JSON) with each job's seeds, schedule and config overrides")
+	listen := fs.String("status-addr", "127.0.0.1:8081", "listen address of the job status and /metrics endpoints (empty disables them)")
+	config := crawlFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: crawler serve [flags] -jobs jobs.json")
+		fmt.Fprintln(os.Stderr, "The crawl flags below are the defaults each job's \"config\" overrides.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if *jobsPath == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	dsn := *dbDSN
+	if dsn == "" {
+		dsn = *mysqlDSN
+	}
+	if dsn == "" {
+		fmt.Fprintln(os.Stderr, "error: -db or -mysql is required")
+		return 2
+	}
+
+	base := config()
+	jobList, err := jobs.Load(*jobsPath, base)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	repo, err := openRepo(dsn, *migrate)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer repo.Close()
+
+	d, err := jobs.NewDaemon(repo, jobList, base.StateDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "jobs state:", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop() // a second signal kills the process instead of waiting
+	}()
+
+	var srv *http.Server
+	if *listen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/", d.Handler())
+		mux.Handle("/metrics", metrics.Default.Handler())
+		srv = &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("status endpoint: %v", err)
+			}
+		}()
+		log.Printf("job status on http://%s/jobs, metrics on http://%s/metrics", *listen, *listen)
+	}
+
+	log.Printf("serving %d job(s) from %s", len(jobList), *jobsPath)
+	d.Run(ctx) // returns once ctx is canceled and every running crawl has checkpointed
+	log.Printf("shut down")
+
+	if srv != nil {
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(sctx)
+	}
+	return 0
+}
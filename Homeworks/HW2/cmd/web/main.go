@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"html/template"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/yourname/go-image-crawler/internal/storage"
@@ -16,27 +19,48 @@ import (
 func main() {
 	var (
 		mysqlDSN  = flag.String("mysql", "", "MySQL DSN")
+		dbDSN     = flag.String("db", "", "database DSN: mysql://... or sqlite://images.db (overrides -mysql)")
 		listen    = flag.String("listen", ":8080", "listen address")
 		pageSize  = flag.Int("page-size", 40, "results per page")
 		templates = flag.String("templates", "./web/templates", "templates directory")
+		migrate   = flag.Bool("migrate", true, "apply pending schema migrations on startup")
+		drain     = flag.Duration("shutdown-timeout", 10*time.Second, "on SIGINT/SIGTERM, how long to let in-flight requests finish")
 	)
 	flag.Parse()
 
-	if *mysqlDSN == "" {
-		fmt.Fprintln(os.Stderr, "error: -mysql is required")
+	dsn := *dbDSN
+	if dsn == "" {
+		dsn = *mysqlDSN
+	}
+	if dsn == "" {
+		fmt.Fprintln(os.Stderr, "error: -db or -mysql is required")
 		os.Exit(2)
 	}
 
-	repo, err := storage.OpenMySQL(*mysqlDSN)
+	repo, err := storage.Open(dsn)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "mysql:", err)
+		fmt.Fprintln(os.Stderr, "db:", err)
 		os.Exit(1)
 	}
 	defer repo.Close()
 
+	if *migrate {
+		mctx, mcancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		applied, err := repo.MigrateUp(mctx)
+		mcancel()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			os.Exit(1)
+		}
+		if len(applied) > 0 {
+			fmt.Println("applied migrations:", applied)
+		}
+	}
+
 	funcs := template.FuncMap{
-		"add": func(a, b int) int { return a + b },
-		"sub": func(a, b int) int { return a - b },
+		"add":    func(a, b int) int { return a + b },
+		"sub":    func(a, b int) int { return a - b },
+		"srcset": webui.SrcSet,
 	}
 	tmpl, err := template.New("").Funcs(funcs).ParseGlob(filepath.Join(*templates, "*.html"))
 	if err != nil {
@@ -56,9 +80,30 @@ func main() {
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
 	fmt.Println("listening on", *listen)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		fmt.Fprintln(os.Stderr, "server:", err)
-		os.Exit(1)
+
+	select {
+	case err := <-errc:
+		if err != nil && err != http.ErrServerClosed {
+			fmt.Fprintln(os.Stderr, "server:", err)
+			os.Exit(1)
+		}
+		return
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process
+
+	// Stop accepting connections and let in-flight requests finish.
+	fmt.Println("shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), *drain)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		fmt.Fprintln(os.Stderr, "shutdown:", err)
+		_ = srv.Close()
 	}
 }
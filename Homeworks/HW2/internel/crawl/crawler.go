@@ -4,17 +4,22 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/url"
 	"path"
+	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/yourname/go-image-crawler/internal/extract"
 	"github.com/yourname/go-image-crawler/internal/images"
 	"github.com/yourname/go-image-crawler/internal/render"
+	"github.com/yourname/go-image-crawler/internal/robots"
 	"github.com/yourname/go-image-crawler/internal/storage"
 	"golang.org/x/net/publicsuffix"
 )
@@ -32,22 +37,88 @@ type Config struct {
 	Render         bool
 	UserAgent      string
 	ThumbDir       string
-	Logf           func(format string, args ...any)
+	Logf           func(format string, args ...any) `json:"-"`
+
+	// ThumbProfiles lists the thumbnail variants to make, e.g.
+	// "200w,400w,sq200" (see images.ParseProfiles); empty means
+	// images.DefaultProfiles.
+	ThumbProfiles string
+	// KeepSVG also stores a sanitized copy of SVG images in ThumbDir (see
+	// images.Downloader.KeepSVG).
+	KeepSVG bool
+
+	// RespectRobots makes workers honor robots.txt Allow/Disallow rules and Crawl-delay.
+	RespectRobots bool
+
+	// Sitemaps are sitemap.xml URLs (or sitemap indexes, optionally gzipped) whose
+	// pages and image entries are added to the frontier.
+	Sitemaps []string
+	// DiscoverSitemaps looks up Sitemap: lines in each seed host's robots.txt,
+	// falling back to /sitemap.xml.
+	DiscoverSitemaps bool
+
+	// MaxPerHost caps concurrent requests to a single host.
+	MaxPerHost int
+	// HostDelay is the minimum time between two requests to the same host
+	// (raised to the host's robots.txt Crawl-delay when that is larger).
+	HostDelay time.Duration
+
+	// StateDir is where crawl checkpoints are written. Empty disables checkpointing.
+	StateDir string
+	// CrawlID names the checkpoint. A new id is generated when empty.
+	CrawlID string
+	// Resume continues the checkpointed crawl CrawlID instead of starting from the seeds.
+	Resume bool
+	// CheckpointEvery is the interval between periodic checkpoints.
+	CheckpointEvery time.Duration
+
+	// RetryAttempts is the total number of tries for a page or image fetch
+	// (1 disables retries). Transient failures (timeouts, dropped
+	// connections, 429, 5xx) are retried after RetryBaseDelay, doubling up
+	// to RetryMaxDelay, or after the server's Retry-After.
+	RetryAttempts  int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	// RecrawlAfter skips pages and images an earlier crawl fetched or
+	// revalidated less than this long ago; older ones are fetched
+	// conditionally (If-None-Match / If-Modified-Since). 0 revalidates
+	// everything.
+	RecrawlAfter time.Duration
 }
 
+// Stop reasons recorded in the crawls table.
+const (
+	StopExhausted = "exhausted" // frontier empty
+	StopMaxPages  = "max_pages" // -max-pages reached, remaining links skipped
+	StopTimeout   = "timeout"   // Config.Timeout elapsed with work left
+	StopCanceled  = "canceled"  // the caller canceled the crawl (shutdown); see the checkpoint
+)
+
 type URLTask struct {
 	URL   string
 	Depth int
-	Kind  string // "page" or "resource"
+	Kind  string // "page", "resource" or "sitemap"
 }
 
 type pageResult struct {
-	Task      URLTask
-	FinalURL  string
-	Links     []string              // page links
-	Resources []extract.ResourceRef // css/js resources
-	Images    []extract.ImageRef
-	Err       error
+	Task        URLTask
+	FinalURL    string
+	Redirects   []render.Redirect
+	Status      int
+	ContentType string
+	Rendered    bool
+	FetchedAt   time.Time
+	Links       []string              // page links
+	Resources   []extract.ResourceRef // css/js resources
+	Images      []extract.ImageRef
+	Attempts    int
+	// NotModified marks a page reused from an earlier crawl (fresh, or
+	// answered 304); its links are the stored ones.
+	NotModified bool
+	// Checked is set after a successful fetch or revalidation.
+	Checked *storage.Validators
+	Err     error
 }
 
 type imageTask struct {
@@ -57,16 +128,31 @@ type imageTask struct {
 type imageResult struct {
 	Task imageTask
 	Proc images.Processed
-	Err  error
+	// Checked is set after a successful download or revalidation.
+	Checked *storage.Validators
+	// Replace is set when an image fetched by an earlier crawl was
+	// downloaded again because it changed.
+	Replace bool
+	Err     error
 }
 
-func Run(seeds []string, repo *storage.Repository, cfg Config) error {
-	if len(seeds) == 0 {
-		return errors.New("no seed URLs provided")
-	}
+// Run crawls from seeds until the frontier is exhausted, a limit is hit or
+// ctx is canceled. A canceled crawl is checkpointed like a timed-out one and
+// can be resumed.
+func Run(ctx context.Context, seeds []string, repo storage.Repository, cfg Config) error {
 	if repo == nil {
 		return errors.New("nil repository")
 	}
+	if cfg.Resume && cfg.StateDir == "" {
+		return errors.New("resume requires a state directory")
+	}
+	profiles := images.DefaultProfiles
+	if cfg.ThumbProfiles != "" {
+		var err error
+		if profiles, err = images.ParseProfiles(cfg.ThumbProfiles); err != nil {
+			return err
+		}
+	}
 	if cfg.Workers <= 0 {
 		cfg.Workers = 8
 	}
@@ -91,6 +177,43 @@ func Run(seeds []string, repo *storage.Repository, cfg Config) error {
 	if cfg.Logf == nil {
 		cfg.Logf = log.Printf
 	}
+	if cfg.MaxPerHost <= 0 {
+		cfg.MaxPerHost = 2
+	}
+	if cfg.HostDelay < 0 {
+		cfg.HostDelay = 0
+	}
+	if cfg.CheckpointEvery <= 0 {
+		cfg.CheckpointEvery = 15 * time.Second
+	}
+	if cfg.RetryAttempts <= 0 {
+		cfg.RetryAttempts = render.DefaultRetryPolicy.Attempts
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = render.DefaultRetryPolicy.BaseDelay
+	}
+	if cfg.RetryMaxDelay <= 0 {
+		cfg.RetryMaxDelay = render.DefaultRetryPolicy.MaxDelay
+	}
+
+	var resumed *State
+	if cfg.Resume {
+		st, err := LoadState(cfg.StateDir, cfg.CrawlID)
+		if err != nil {
+			return err
+		}
+		resumed = st
+		seeds = append(append([]string{}, st.Seeds...), seeds...)
+	}
+	if len(seeds) == 0 && len(cfg.Sitemaps) == 0 {
+		return errors.New("no seed URLs provided")
+	}
+	if cfg.CrawlID == "" {
+		cfg.CrawlID = NewCrawlID()
+	}
+	if !validCrawlID(cfg.CrawlID) {
+		return fmt.Errorf("invalid crawl id %q", cfg.CrawlID)
+	}
 
 	// Best-effort cap for our goroutines.
 	overhead := 8
@@ -108,26 +231,38 @@ func Run(seeds []string, repo *storage.Repository, cfg Config) error {
 		cfg.Logf("adjusted workers to respect -max-goroutines: workers=%d imageWorkers=%d", cfg.Workers, cfg.ImageWorkers)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
 	defer cancel()
 
 	allowedDomains := make(map[string]struct{})
-	for _, s := range seeds {
+	for _, s := range append(append([]string{}, seeds...), cfg.Sitemaps...) {
 		d := effectiveDomain(s)
 		if d != "" {
 			allowedDomains[d] = struct{}{}
 		}
 	}
 
+	retry := render.RetryPolicy{
+		Attempts:  cfg.RetryAttempts,
+		BaseDelay: cfg.RetryBaseDelay,
+		MaxDelay:  cfg.RetryMaxDelay,
+		OnRetry: func(u string, attempt int, err error, wait time.Duration) {
+			cfg.Logf("retry %d/%d in %s [%s]: %s: %v", attempt+1, cfg.RetryAttempts, wait.Round(time.Millisecond), render.Classify(err), u, err)
+		},
+	}
+
 	// Fetchers
-	httpFetcher := render.NewHTTPFetcher(cfg.UserAgent)
+	var httpFetcher render.Fetcher = &render.RetryFetcher{Fetcher: timedFetcher{render.NewHTTPFetcher(cfg.UserAgent), "http"}, Policy: retry}
 	var domFetcher render.Fetcher
 	var err error
 	if cfg.Render {
-		domFetcher, err = render.NewChromedpFetcher(cfg.UserAgent)
+		var cdp *render.ChromedpFetcher
+		cdp, err = render.NewChromedpFetcher(cfg.UserAgent)
 		if err != nil {
 			cfg.Logf("chromedp unavailable (%v), falling back to HTTP fetcher", err)
 			domFetcher = httpFetcher
+		} else {
+			domFetcher = &render.RetryFetcher{Fetcher: timedFetcher{cdp, "chromedp"}, Policy: retry}
 		}
 	} else {
 		domFetcher = httpFetcher
@@ -138,166 +273,504 @@ func Run(seeds []string, repo *storage.Repository, cfg Config) error {
 	}()
 
 	downloader := images.NewDownloader(cfg.UserAgent, cfg.ThumbDir)
+	downloader.Retry = retry
+	downloader.Profiles = profiles
+	downloader.KeepSVG = cfg.KeepSVG
+
+	var robotsCache *robots.Cache
+	if cfg.RespectRobots {
+		robotsCache = robots.NewCache(cfg.UserAgent)
+	}
 
 	// Channels
-	jobs := make(chan URLTask, cfg.Workers*4)
 	pageResults := make(chan pageResult, cfg.Workers*4)
-	imgJobs := make(chan imageTask, max(1, cfg.ImageWorkers)*8)
 	imgResults := make(chan imageResult, max(1, cfg.ImageWorkers)*8)
-	dbInserts := make(chan storage.ImageInsert, 256)
+	// DB writes queue without bound so the coordinator never waits on the DB.
+	dbInserts := newQueue[storage.ImageInsert]()
+	pageInserts := newQueue[storage.PageInsert]()
+	failures := newQueue[storage.FailureInsert]()
+	validators := newQueue[storage.Validators]()
+
+	// Page/resource tasks and image downloads go through per-host schedulers
+	// instead of shared queues; both draw on the same per-host limits. With
+	// robots enabled a host's robots.txt is fetched before its first request,
+	// so its Crawl-delay applies from the start.
+	var crawlDelay func(string) time.Duration
+	if robotsCache != nil {
+		crawlDelay = robotsCache.CrawlDelay
+	}
+	limits := newHostLimits(cfg.MaxPerHost, cfg.HostDelay, crawlDelay)
+	if robotsCache != nil {
+		limits.prepare = func(u string) { _, _ = robotsCache.Get(ctx, u) }
+	}
+	sched := newHostScheduler[URLTask](limits)
+	imgSched := newHostScheduler[imageTask](limits)
+	var schedWG sync.WaitGroup
+	schedWG.Add(2)
+	go func() {
+		defer schedWG.Done()
+		sched.Run(ctx)
+	}()
+	go func() {
+		defer schedWG.Done()
+		imgSched.Run(ctx)
+	}()
 
 	// Workers
 	var workerWG sync.WaitGroup
 	var dbWG sync.WaitGroup
-	startPageWorkers(ctx, &workerWG, cfg.Workers, jobs, pageResults, domFetcher, httpFetcher)
-	startImageWorkers(ctx, &workerWG, cfg.ImageWorkers, imgJobs, imgResults, downloader)
-	startDBWriter(ctx, &dbWG, repo, dbInserts, cfg.Logf)
+	var dbStats, pageStats, failureStats, validatorStats writerStats
+	rc := &recrawl{repo: repo, after: cfg.RecrawlAfter}
+	startPageWorkers(ctx, &workerWG, cfg.Workers, sched.Jobs(), pageResults, domFetcher, httpFetcher, robotsCache, rc)
+	startImageWorkers(ctx, &workerWG, cfg.ImageWorkers, imgSched.Jobs(), imgResults, downloader, robotsCache, rc)
+	// Writers outlive the crawl context so that results already handed to
+	// them are saved after a timeout or cancellation.
+	wctx := context.WithoutCancel(ctx)
+	startWriter(wctx, &dbWG, dbInserts, "images", &dbStats, 5*time.Second, repo.InsertImage, func(_ storage.ImageInsert, err error) {
+		cfg.Logf("db insert error: %v", err)
+	})
+	startWriter(wctx, &dbWG, pageInserts, "pages", &pageStats, 10*time.Second, repo.SavePage, func(p storage.PageInsert, err error) {
+		cfg.Logf("db page error: %s: %v", p.URL, err)
+	})
+	startWriter(wctx, &dbWG, failures, "fetch_failures", &failureStats, 5*time.Second, repo.RecordFailure, func(f storage.FailureInsert, err error) {
+		cfg.Logf("db failure error: %s: %v", f.URL, err)
+	})
+	startWriter(wctx, &dbWG, validators, "http_validators", &validatorStats, 5*time.Second, repo.SaveValidators, func(v storage.Validators, err error) {
+		cfg.Logf("db validators error: %s: %v", v.URL, err)
+	})
 
-	visited := make(map[string]struct{})       // all crawled URLs (pages + resources)
+	visited := make(map[string]URLTask)        // all crawled URLs (pages + resources)
 	visitedImages := make(map[string]struct{}) // dedupe downloads
 
-	// Seed enqueue
+	// Everything enqueued but not yet finished; this is what a checkpoint saves as the frontier.
+	pendingTasks := make(map[string]URLTask)
+	pendingImages := make(map[string]extract.ImageRef)
+
 	activeTasks := 0
 	activeImages := 0
 	processedTasks := 0
+	// counters holds totals from earlier runs of a resumed crawl plus this
+	// run's page/image errors; the DB writer's counts are added on read.
+	var counters Counters
+	counts := func() Counters {
+		c := counters
+		c.ImagesStored += int(dbStats.stored.Load())
+		c.DBErrors += int(dbStats.errors.Load() + pageStats.errors.Load() + failureStats.errors.Load() + validatorStats.errors.Load())
+		return c
+	}
+	recordFailure := func(u, kind string, err error) {
+		failures.Push(storage.FailureInsert{
+			CrawlID:    cfg.CrawlID,
+			URL:        nonEmpty(imageKey(u), u),
+			Kind:       kind,
+			Class:      string(failureClass(err)),
+			StatusCode: failureStatus(err),
+			Message:    err.Error(),
+			At:         time.Now(),
+		})
+	}
 
+	enqueue := func(t URLTask) {
+		visited[t.URL] = t
+		pendingTasks[t.URL] = t
+		activeTasks++
+		sched.Push(t)
+	}
+	enqueueImage := func(key string, im extract.ImageRef) {
+		visitedImages[key] = struct{}{}
+		pendingImages[key] = im
+		activeImages++
+		imgSched.Push(imageTask{Ref: im})
+	}
+
+	if resumed != nil {
+		for u, t := range resumed.Visited {
+			visited[u] = t
+		}
+		for _, k := range resumed.Images {
+			visitedImages[k] = struct{}{}
+		}
+		processedTasks = resumed.Processed
+		counters = resumed.Counters
+		for _, t := range resumed.Pending {
+			enqueue(t)
+		}
+		for _, im := range resumed.PendingImages {
+			if key := imageKey(im.URL); key != "" {
+				enqueueImage(key, im)
+			}
+		}
+		cfg.Logf("resuming crawl %s: pending=%d pending_images=%d visited=%d processed=%d",
+			cfg.CrawlID, len(resumed.Pending), len(resumed.PendingImages), len(visited), processedTasks)
+	}
+
+	// Seed enqueue
+	var seedList []string
+	seenSeeds := make(map[string]struct{})
 	for _, s := range seeds {
 		u := canonicalizeHTTP(s)
 		if u == "" {
 			continue
 		}
+		if _, ok := seenSeeds[u]; !ok {
+			seenSeeds[u] = struct{}{}
+			seedList = append(seedList, u)
+		}
 		if _, ok := visited[u]; ok {
 			continue
 		}
-		visited[u] = struct{}{}
-		activeTasks++
-		jobs <- URLTask{URL: u, Depth: 0, Kind: "page"}
+		enqueue(URLTask{URL: u, Depth: 0, Kind: "page"})
 	}
 
-	cfg.Logf("crawl start: workers=%d imageWorkers=%d followExternal=%v render=%v timeout=%s",
-		cfg.Workers, cfg.ImageWorkers, cfg.FollowExternal, cfg.Render, cfg.Timeout)
+	sitemaps := cfg.Sitemaps
+	if cfg.DiscoverSitemaps {
+		sitemaps = append(append([]string{}, sitemaps...), discoverSitemaps(ctx, seedList, cfg.UserAgent)...)
+	}
+	for _, sm := range sitemaps {
+		u := canonicalizeHTTP(sm)
+		if u == "" {
+			continue
+		}
+		if _, ok := visited[u]; ok {
+			continue
+		}
+		enqueue(URLTask{URL: u, Depth: 0, Kind: "sitemap"})
+	}
 
-	for {
-		if ctx.Err() != nil {
-			cfg.Logf("crawl stopped: %v", ctx.Err())
-			break
+	checkpoint := func() {
+		if cfg.StateDir == "" {
+			return
 		}
-		if activeTasks == 0 && activeImages == 0 {
-			break
+		st := State{
+			ID:        cfg.CrawlID,
+			Seeds:     seedList,
+			Processed: processedTasks,
+			Visited:   visited,
+			Counters:  counts(),
+		}
+		for _, t := range pendingTasks {
+			st.Pending = append(st.Pending, t)
+		}
+		for k := range visitedImages {
+			st.Images = append(st.Images, k)
+		}
+		for _, im := range pendingImages {
+			st.PendingImages = append(st.PendingImages, im)
 		}
+		if err := st.Save(cfg.StateDir); err != nil {
+			cfg.Logf("checkpoint error: %v", err)
+		}
+	}
+	ticker := time.NewTicker(cfg.CheckpointEvery)
+	defer ticker.Stop()
 
-		select {
-		case <-ctx.Done():
-			cfg.Logf("timeout reached")
-			goto done
-		case pr := <-pageResults:
-			if pr.Task.URL == "" && pr.Err == nil && len(pr.Links) == 0 && len(pr.Resources) == 0 && len(pr.Images) == 0 {
-				continue
-			}
-			activeTasks--
-			processedTasks++
-			if pr.Err != nil {
-				cfg.Logf("fetch error: %s: %v", pr.Task.URL, pr.Err)
-				continue
-			}
-			if processedTasks >= cfg.MaxPages {
-				continue
-			}
+	cfgJSON, err := configJSON(cfg)
+	if err != nil {
+		return err
+	}
+	sctx, scancel := context.WithTimeout(ctx, 5*time.Second)
+	err = repo.StartCrawl(sctx, storage.CrawlStart{ID: cfg.CrawlID, Seeds: seedList, Config: string(cfgJSON), StartedAt: time.Now()})
+	scancel()
+	if err != nil {
+		return fmt.Errorf("record crawl session: %w", err)
+	}
+	stopReason := StopExhausted
+	depth := make(depthGauges)
+	defer depth.reset()
 
-			scopeBase := pr.FinalURL
-			if scopeBase == "" {
-				scopeBase = pr.Task.URL
-			}
+	// handlePage and handleImage apply a worker's result: bookkeeping, DB
+	// writes and new frontier entries.
+	handlePage := func(pr pageResult) {
+		activeTasks--
+		processedTasks++
+		counters.Retries += max(pr.Attempts-1, 0)
+		delete(pendingTasks, pr.Task.URL)
+		sched.Done(pr.Task)
+		if errors.Is(pr.Err, robots.ErrDisallowed) {
+			counters.RobotsSkipped++
+			recordFailure(pr.Task.URL, pr.Task.Kind, pr.Err)
+			cfg.Logf("robots: skip %s", pr.Task.URL)
+			return
+		}
+		switch {
+		case !pr.NotModified:
+			pagesFetched.Inc(pr.Task.Kind, statusLabel(pr.Status))
+		case pr.Attempts > 0: // revalidated; a fresh page is reused without a request
+			pagesFetched.Inc(pr.Task.Kind, "304")
+		}
+		if pr.NotModified {
+			counters.Unchanged++
+		} else {
+			pageInserts.Push(pageRecord(pr, cfg.CrawlID))
+		}
+		if pr.Checked != nil {
+			validators.Push(*pr.Checked)
+		}
+		if pr.Err != nil {
+			counters.FetchErrors++
+			recordFailure(pr.Task.URL, pr.Task.Kind, pr.Err)
+			cfg.Logf("fetch error [%s] after %d attempt(s): %s: %v", failureClass(pr.Err), pr.Attempts, pr.Task.URL, pr.Err)
+			return
+		}
+		if processedTasks >= cfg.MaxPages {
+			stopReason = StopMaxPages
+			return
+		}
 
-			// Enqueue page links (subject to external + depth)
-			if pr.Task.Kind == "page" && pr.Task.Depth < cfg.MaxDepth {
-				for _, l := range pr.Links {
-					lc := canonicalizeHTTP(l)
-					if lc == "" {
-						continue
-					}
-					if _, ok := visited[lc]; ok {
-						continue
-					}
-					if !cfg.FollowExternal && isExternal(scopeBase, lc, allowedDomains) {
-						continue
-					}
-					visited[lc] = struct{}{}
-					activeTasks++
-					jobs <- URLTask{URL: lc, Depth: pr.Task.Depth + 1, Kind: "page"}
-				}
-			}
+		scopeBase := pr.FinalURL
+		if scopeBase == "" {
+			scopeBase = pr.Task.URL
+		}
 
-			// Enqueue resources (CSS/JS) regardless of FollowExternal (CDNs should be allowed)
-			for _, r := range pr.Resources {
-				rc := canonicalizeHTTP(r.URL)
-				if rc == "" {
+		// Enqueue page links (subject to external + depth)
+		if pr.Task.Kind == "page" && pr.Task.Depth < cfg.MaxDepth {
+			for _, l := range pr.Links {
+				lc := canonicalizeHTTP(l)
+				if lc == "" {
 					continue
 				}
-				if _, ok := visited[rc]; ok {
+				if _, ok := visited[lc]; ok {
 					continue
 				}
-				visited[rc] = struct{}{}
-				activeTasks++
-				jobs <- URLTask{URL: rc, Depth: pr.Task.Depth, Kind: "resource"}
+				if !cfg.FollowExternal && isExternal(scopeBase, lc, allowedDomains) {
+					continue
+				}
+				enqueue(URLTask{URL: lc, Depth: pr.Task.Depth + 1, Kind: "page"})
 			}
+		}
 
-			// Enqueue images (may be on CDNs; do not apply FollowExternal)
-			for _, im := range pr.Images {
-				key := imageKey(im.URL)
-				if key == "" {
+		// Sitemap pages act like seeds (depth 0); keep them within -max-pages.
+		if pr.Task.Kind == "sitemap" {
+			for _, l := range pr.Links {
+				if activeTasks+processedTasks >= cfg.MaxPages {
+					break
+				}
+				lc := canonicalizeHTTP(l)
+				if lc == "" {
 					continue
 				}
-				if _, ok := visitedImages[key]; ok {
+				if _, ok := visited[lc]; ok {
 					continue
 				}
-				visitedImages[key] = struct{}{}
-				activeImages++
-				imgJobs <- imageTask{Ref: im}
+				if !cfg.FollowExternal && isExternal(scopeBase, lc, allowedDomains) {
+					continue
+				}
+				enqueue(URLTask{URL: lc, Depth: 0, Kind: "page"})
 			}
+		}
 
-		case ir := <-imgResults:
-			if ir.Task.Ref.URL == "" && ir.Err == nil && ir.Proc.OriginalURL == "" {
+		// Enqueue resources (CSS/JS) regardless of FollowExternal (CDNs should be allowed)
+		for _, r := range pr.Resources {
+			rc := canonicalizeHTTP(r.URL)
+			if rc == "" {
 				continue
 			}
-			activeImages--
-			if ir.Err != nil {
-				cfg.Logf("image error: %s: %v", ir.Task.Ref.URL, ir.Err)
+			if _, ok := visited[rc]; ok {
 				continue
 			}
-			dbInserts <- storage.ImageInsert{
-				URL:       ir.Task.Ref.URL,
-				PageURL:   ir.Task.Ref.PageURL,
-				Filename:  nonEmpty(ir.Task.Ref.Filename, filenameFromURL(ir.Task.Ref.URL)),
-				Alt:       ir.Task.Ref.Alt,
-				Title:     ir.Task.Ref.Title,
-				Width:     ir.Proc.Width,
-				Height:    ir.Proc.Height,
-				Format:    ir.Proc.Format,
-				ThumbPath: ir.Proc.ThumbPath,
-				ThumbMIME: ir.Proc.ThumbMIME,
-				ThumbBlob: ir.Proc.ThumbBytes,
+			kind := "resource"
+			if r.Kind == "sitemap" {
+				kind = "sitemap"
 			}
+			enqueue(URLTask{URL: rc, Depth: pr.Task.Depth, Kind: kind})
+		}
 
-		default:
-			time.Sleep(10 * time.Millisecond)
+		// Enqueue images (may be on CDNs; do not apply FollowExternal)
+		for _, im := range pr.Images {
+			key := imageKey(im.URL)
+			if key == "" {
+				continue
+			}
+			if _, ok := visitedImages[key]; ok {
+				continue
+			}
+			enqueueImage(key, im)
+		}
+	}
+	handleImage := func(ir imageResult) {
+		imgSched.Done(ir.Task)
+		activeImages--
+		delete(pendingImages, imageKey(ir.Task.Ref.URL))
+		if ir.Err != nil {
+			counters.Retries += render.Attempts(ir.Err) - 1
+		} else {
+			counters.Retries += max(ir.Proc.Attempts-1, 0)
+		}
+		if errors.Is(ir.Err, robots.ErrDisallowed) {
+			counters.RobotsSkipped++
+			recordFailure(ir.Task.Ref.URL, "image", ir.Err)
+			cfg.Logf("robots: skip image %s", ir.Task.Ref.URL)
+			return
+		}
+		if ir.Err != nil {
+			switch failureClass(ir.Err) {
+			case render.ClassDecode:
+				imageDecodeFailures.Inc(nonEmpty(ir.Proc.Format, "unknown"))
+			case render.ClassUnsupported:
+				imagesUnsupported.Inc(ir.Proc.Format)
+			}
+			counters.ImageErrors++
+			recordFailure(ir.Task.Ref.URL, "image", ir.Err)
+			cfg.Logf("image error [%s] after %d attempt(s): %s: %v", failureClass(ir.Err), render.Attempts(ir.Err), ir.Task.Ref.URL, ir.Err)
+			return
+		}
+		if ir.Checked != nil {
+			validators.Push(*ir.Checked)
+		}
+		if ir.Proc.NotModified {
+			counters.Unchanged++
+			return
+		}
+		dbInserts.Push(storage.ImageInsert{
+			URL:       ir.Task.Ref.URL,
+			PageURL:   ir.Task.Ref.PageURL,
+			Filename:  nonEmpty(ir.Task.Ref.Filename, filenameFromURL(ir.Task.Ref.URL)),
+			Alt:       ir.Task.Ref.Alt,
+			Title:     ir.Task.Ref.Title,
+			Caption:   ir.Task.Ref.Caption,
+			PageTitle: ir.Task.Ref.PageTitle,
+			Width:     ir.Proc.Width,
+			Height:    ir.Proc.Height,
+			Format:    ir.Proc.Format,
+			ThumbPath: ir.Proc.ThumbPath,
+			ThumbMIME: ir.Proc.ThumbMIME,
+			ThumbBlob: ir.Proc.ThumbBytes,
+			Thumbs:    thumbInserts(ir.Proc.Thumbs),
+			PHash:     ir.Proc.PHash,
+			HasPHash:  ir.Proc.HasPHash,
+			ColorHist: ir.Proc.ColorHist,
+			CrawlID:   cfg.CrawlID,
+
+			Bytes:       ir.Proc.Bytes,
+			BitDepth:    ir.Proc.BitDepth,
+			Frames:      ir.Proc.Frames,
+			ICCProfile:  ir.Proc.ICCProfile,
+			CameraMake:  ir.Proc.EXIF.Make,
+			CameraModel: ir.Proc.EXIF.Model,
+			Lens:        ir.Proc.EXIF.Lens,
+			TakenAt:     ir.Proc.EXIF.Taken,
+			Orientation: ir.Proc.EXIF.Orientation,
+			HasGPS:      ir.Proc.EXIF.HasGPS,
+			GPSLat:      ir.Proc.EXIF.Lat,
+			GPSLon:      ir.Proc.EXIF.Lon,
+			Colors:      imageColors(ir.Proc.Colors),
+			Replace:     ir.Replace,
+		})
+	}
+
+	cfg.Logf("crawl start: id=%s workers=%d imageWorkers=%d followExternal=%v render=%v timeout=%s perHost=%d hostDelay=%s",
+		cfg.CrawlID, cfg.Workers, cfg.ImageWorkers, cfg.FollowExternal, cfg.Render, cfg.Timeout, cfg.MaxPerHost, cfg.HostDelay)
+
+	// The coordinator owns all crawl state and sleeps in one select until a
+	// worker reports, a checkpoint is due or ctx ends. It never blocks
+	// anywhere else: pages and images go to the schedulers' unbounded host
+	// queues and DB writes to unbounded queues. However many links a
+	// page yields, only those queues grow, and the workers, which send every
+	// result, always find the coordinator receiving.
+	for {
+		if ctx.Err() != nil {
+			cfg.Logf("crawl stopped: %v", ctx.Err())
+			stopReason = ctxStopReason(ctx)
+			break
+		}
+		if activeTasks == 0 && activeImages == 0 {
+			break
+		}
+
+		depth.set("jobs", sched.Queued())
+		depth.set("imgJobs", imgSched.Queued())
+		depth.set("dbInserts", dbInserts.Len())
+		depth.set("pageInserts", pageInserts.Len())
+		depth.set("failures", failures.Len())
+		depth.set("validators", validators.Len())
+
+		select {
+		case <-ctx.Done():
+			stopReason = ctxStopReason(ctx)
+			if stopReason == StopTimeout {
+				cfg.Logf("timeout reached")
+			} else {
+				cfg.Logf("crawl canceled")
+			}
+			goto done
+		case <-ticker.C:
+			checkpoint()
+		case pr := <-pageResults:
+			handlePage(pr)
+		case ir := <-imgResults:
+			handleImage(ir)
 		}
 	}
 
 done:
-	close(jobs)
-	close(imgJobs)
-	workerWG.Wait()
+	sched.Stop()
+	imgSched.Stop()
+	schedWG.Wait()
+
+	// Workers deliver whatever they were working on. Apply what completed
+	// so finished pages and images still reach the DB; failures (mostly
+	// fetches cut short by the cancellation) stay in the checkpointed
+	// frontier for a resume.
+	go func(pages chan pageResult, imgs chan imageResult) {
+		workerWG.Wait()
+		close(pages)
+		close(imgs)
+	}(pageResults, imgResults)
+	reason := stopReason
+	for pages, imgs := pageResults, imgResults; pages != nil || imgs != nil; {
+		select {
+		case pr, ok := <-pages:
+			if !ok {
+				pages = nil
+			} else if pr.Err == nil {
+				handlePage(pr)
+			}
+		case ir, ok := <-imgs:
+			if !ok {
+				imgs = nil
+			} else if ir.Err == nil {
+				handleImage(ir)
+			}
+		}
+	}
+	stopReason = reason
 
-	close(dbInserts)
+	dbInserts.Close()
+	pageInserts.Close()
+	failures.Close()
+	validators.Close()
 	dbWG.Wait()
 
-	cfg.Logf("crawl finished: tasks_processed=%d visited_urls=%d unique_images=%d",
-		processedTasks, len(visited), len(visitedImages))
+	checkpoint()
+	if cfg.StateDir != "" && len(pendingTasks)+len(pendingImages) > 0 {
+		cfg.Logf("checkpoint saved: id=%s pending=%d pending_images=%d (continue with -resume %s)",
+			cfg.CrawlID, len(pendingTasks), len(pendingImages), cfg.CrawlID)
+	}
+
+	final := counts()
+	fctx, fcancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer fcancel()
+	if err := repo.FinishCrawl(fctx, cfg.CrawlID, stopReason, storage.CrawlStats{
+		PagesProcessed: processedTasks,
+		ImagesStored:   final.ImagesStored,
+		FetchErrors:    final.FetchErrors,
+		ImageErrors:    final.ImageErrors,
+		DBErrors:       final.DBErrors,
+		RobotsSkipped:  final.RobotsSkipped,
+		Retries:        final.Retries,
+		Unchanged:      final.Unchanged,
+	}); err != nil {
+		cfg.Logf("record crawl session: %v", err)
+	}
+
+	cfg.Logf("crawl finished: id=%s stop=%s tasks_processed=%d visited_urls=%d unique_images=%d images_stored=%d fetch_errors=%d image_errors=%d robots_skipped=%d retries=%d unchanged=%d",
+		cfg.CrawlID, stopReason, processedTasks, len(visited), len(visitedImages), final.ImagesStored, final.FetchErrors, final.ImageErrors, final.RobotsSkipped, final.Retries, final.Unchanged)
 	return nil
 }
 
-func startPageWorkers(ctx context.Context, wg *sync.WaitGroup, n int, jobs <-chan URLTask, out chan<- pageResult, domFetcher render.Fetcher, httpFetcher render.Fetcher) {
+// Workers send every result they produce, even after ctx ends: Run keeps
+// receiving until they have all returned.
+func startPageWorkers(ctx context.Context, wg *sync.WaitGroup, n int, jobs <-chan URLTask, out chan<- pageResult, domFetcher render.Fetcher, httpFetcher render.Fetcher, rb *robots.Cache, rc *recrawl) {
 	for i := 0; i < n; i++ {
 		wg.Add(1)
 		go func(workerID int) {
@@ -310,95 +783,94 @@ func startPageWorkers(ctx context.Context, wg *sync.WaitGroup, n int, jobs <-cha
 					if !ok {
 						return
 					}
+					activeWorkers.Inc("page")
+					pr := processTask(ctx, t, domFetcher, httpFetcher, rb, rc)
+					activeWorkers.Dec("page")
+					out <- pr
+				}
+			}
+		}(i)
+	}
+}
 
-					// Use DOM renderer for pages; for resources use HTTP.
-					var fp render.FetchedPage
-					var err error
-					if t.Kind == "page" {
-						fp, err = domFetcher.Fetch(ctx, t.URL)
-						if err != nil && httpFetcher != nil && httpFetcher != domFetcher {
-							fp, err = httpFetcher.Fetch(ctx, t.URL)
-						}
-					} else {
-						fp, err = httpFetcher.Fetch(ctx, t.URL)
-					}
-					if err != nil {
-						select {
-						case out <- pageResult{Task: t, Err: err}:
-						case <-ctx.Done():
-							return
-						}
-						continue
-					}
+// processTask fetches one page, resource or sitemap task and extracts its
+// links.
+func processTask(ctx context.Context, t URLTask, domFetcher, httpFetcher render.Fetcher, rb *robots.Cache, rc *recrawl) pageResult {
+	if err := checkRobots(ctx, rb, t.URL); err != nil {
+		return pageResult{Task: t, Err: err}
+	}
 
-					finalURL := fp.FinalURL
-					if finalURL == "" {
-						finalURL = t.URL
-					}
+	if t.Kind == "sitemap" {
+		ctx = render.WithMaxBytes(ctx, extract.MaxSitemapBytes)
+	}
+	fetchedAt := time.Now()
+	v, fresh, _ := rc.lookup(ctx, t.URL)
+	if fresh {
+		if pr, ok := rc.unchanged(ctx, t, fetchedAt); ok {
+			return pr
+		}
+		v = render.Validators{} // stored copy is gone: fetch in full
+	}
 
-					if looksLikeHTML(fp.ContentType, fp.Body) {
-						ext, err := extract.FromHTML(finalURL, fp.Body)
-						if err != nil {
-							select {
-							case out <- pageResult{Task: t, FinalURL: finalURL, Err: err}:
-							case <-ctx.Done():
-								return
-							}
-							continue
-						}
-						select {
-						case out <- pageResult{
-							Task:      t,
-							FinalURL:  finalURL,
-							Links:     ext.Links,
-							Resources: ext.Resources,
-							Images:    ext.Images,
-						}:
-						case <-ctx.Done():
-							return
-						}
-						continue
-					}
+	fp, err := fetchTask(render.WithValidators(ctx, v), t, domFetcher, httpFetcher)
+	if err == nil && fp.NotModified {
+		if pr, ok := rc.unchanged(ctx, t, fetchedAt); ok {
+			pr.Attempts = fp.Attempts
+			pr.Checked = checked(t.URL, v, fetchedAt)
+			return pr
+		}
+		fp, err = fetchTask(ctx, t, domFetcher, httpFetcher)
+	}
+	// base carries the response metadata into every result below;
+	// a status error still reports the final URL and redirects.
+	base := fetchResult(t, fp, fetchedAt)
+	if err != nil {
+		base.Attempts = render.Attempts(err)
+		base.Err = err
+		return base
+	}
+	finalURL := nonEmpty(base.FinalURL, t.URL)
+	base.FinalURL = finalURL
+	base.Checked = checked(t.URL, render.ResponseValidators(fp.Header), fetchedAt)
 
-					// Parse CSS resources to find images and imported css
-					if looksLikeCSS(fp.ContentType, finalURL) {
-						cssText := string(fp.Body)
-						imports, imgURLs := extract.FromCSS(cssText)
-						var res []extract.ResourceRef
-						for _, imp := range imports {
-							res = append(res, extract.ResourceRef{URL: resolveURL(finalURL, imp), Kind: "css", PageURL: finalURL})
-						}
-						var imgs []extract.ImageRef
-						for _, u := range imgURLs {
-							ru := resolveURL(finalURL, u)
-							imgs = append(imgs, extract.ImageRef{URL: ru, PageURL: finalURL, Filename: filenameFromURL(ru)})
-						}
-						select {
-						case out <- pageResult{
-							Task:      t,
-							FinalURL:  finalURL,
-							Resources: res,
-							Images:    imgs,
-						}:
-						case <-ctx.Done():
-							return
-						}
-						continue
-					}
+	if t.Kind == "sitemap" {
+		return parseSitemap(base, fp.Body)
+	}
 
-					// JS and other resources: nothing to extract
-					select {
-					case out <- pageResult{Task: t, FinalURL: finalURL}:
-					case <-ctx.Done():
-						return
-					}
-				}
-			}
-		}(i)
+	if looksLikeHTML(fp.ContentType, fp.Body) {
+		ext, err := extract.FromHTML(finalURL, fp.Body)
+		res := base
+		if err != nil {
+			res.Err = render.DecodeError(err)
+		} else {
+			res.Links, res.Resources, res.Images = ext.Links, ext.Resources, ext.Images
+		}
+		return res
 	}
+
+	// Parse CSS resources to find images and imported css
+	if looksLikeCSS(fp.ContentType, finalURL) {
+		cssText := string(fp.Body)
+		imports, imgURLs := extract.FromCSS(cssText)
+		var res []extract.ResourceRef
+		for _, imp := range imports {
+			res = append(res, extract.ResourceRef{URL: resolveURL(finalURL, imp), Kind: "css", PageURL: finalURL})
+		}
+		var imgs []extract.ImageRef
+		for _, u := range imgURLs {
+			ru := resolveURL(finalURL, u)
+			imgs = append(imgs, extract.ImageRef{URL: ru, PageURL: finalURL, Filename: filenameFromURL(ru)})
+		}
+		pr := base
+		pr.Resources, pr.Images = res, imgs
+		return pr
+	}
+
+	// JS and other resources: nothing to extract
+	return base
 }
 
-func startImageWorkers(ctx context.Context, wg *sync.WaitGroup, n int, jobs <-chan imageTask, out chan<- imageResult, dl *images.Downloader) {
+func startImageWorkers(ctx context.Context, wg *sync.WaitGroup, n int, jobs <-chan imageTask, out chan<- imageResult, dl *images.Downloader, rb *robots.Cache, rc *recrawl) {
 	if n <= 0 {
 		wg.Add(1)
 		go func() {
@@ -428,35 +900,324 @@ func startImageWorkers(ctx context.Context, wg *sync.WaitGroup, n int, jobs <-ch
 					if !ok {
 						return
 					}
-					ictx, cancel := context.WithTimeout(ctx, 45*time.Second)
-					proc, err := dl.DownloadAndThumbnail(ictx, t.Ref.URL)
-					cancel()
-					select {
-					case out <- imageResult{Task: t, Proc: proc, Err: err}:
-					case <-ctx.Done():
-						return
+					activeWorkers.Inc("image")
+					res := imageResult{Task: t}
+					res.Err = checkRobots(ctx, rb, t.Ref.URL)
+					if res.Err == nil {
+						res.Proc, res.Checked, res.Replace, res.Err = fetchImage(ctx, dl, rc, t.Ref.URL)
 					}
+					activeWorkers.Dec("image")
+					out <- res
 				}
 			}
 		}(i)
 	}
 }
 
-func startDBWriter(ctx context.Context, wg *sync.WaitGroup, repo *storage.Repository, in <-chan storage.ImageInsert, logf func(string, ...any)) {
+// pageRecord converts a fetch result into its pages row and outgoing edges.
+func pageRecord(pr pageResult, crawlID string) storage.PageInsert {
+	p := storage.PageInsert{
+		URL:         pr.Task.URL,
+		FinalURL:    pr.FinalURL,
+		Status:      pr.Status,
+		ContentType: pr.ContentType,
+		Depth:       pr.Task.Depth,
+		Kind:        pr.Task.Kind,
+		Rendered:    pr.Rendered,
+		FetchedAt:   pr.FetchedAt,
+		CrawlID:     crawlID,
+	}
+	if pr.Err != nil {
+		p.Error = pr.Err.Error()
+	}
+	for _, r := range pr.Redirects {
+		p.Redirects = append(p.Redirects, storage.Redirect{URL: r.URL, Status: r.StatusCode})
+	}
+	for _, l := range pr.Links {
+		if lc := canonicalizeHTTP(l); lc != "" {
+			p.Links = append(p.Links, storage.Link{URL: lc, Kind: storage.LinkPage})
+		}
+	}
+	for _, r := range pr.Resources {
+		if rc := canonicalizeHTTP(r.URL); rc != "" {
+			p.Links = append(p.Links, storage.Link{URL: rc, Kind: storage.LinkResource})
+		}
+	}
+	for _, im := range pr.Images {
+		if strings.HasPrefix(im.URL, "data:") {
+			continue // inline, nothing to look up later
+		}
+		p.Links = append(p.Links, storage.Link{URL: im.URL, Kind: storage.LinkImage,
+			Alt: im.Alt, Title: im.Title, Caption: im.Caption, PageTitle: im.PageTitle})
+	}
+	return p
+}
+
+// configJSON renders the effective cfg for the crawls table, with durations
+// written like the command-line flags ("2m0s") instead of nanoseconds.
+func configJSON(cfg Config) ([]byte, error) {
+	m := map[string]any{}
+	v := reflect.ValueOf(cfg)
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.Tag.Get("json") == "-" {
+			continue
+		}
+		if d, ok := v.Field(i).Interface().(time.Duration); ok {
+			m[f.Name] = d.String()
+			continue
+		}
+		m[f.Name] = v.Field(i).Interface()
+	}
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// ctxStopReason tells a Config.Timeout expiry from the caller canceling.
+func ctxStopReason(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return StopCanceled
+	}
+	return StopTimeout
+}
+
+// ApplyOverrides sets the fields of cfg named in overrides, in the form
+// configJSON records them: Go field names as keys and durations as strings
+// ("90s"). CrawlID and Resume belong to a single run and are rejected.
+func ApplyOverrides(cfg *Config, overrides map[string]json.RawMessage) error {
+	v := reflect.ValueOf(cfg).Elem()
+	for name, raw := range overrides {
+		f, ok := v.Type().FieldByName(name)
+		if !ok || f.Tag.Get("json") == "-" || name == "CrawlID" || name == "Resume" {
+			return fmt.Errorf("config field %q cannot be set", name)
+		}
+		fv := v.FieldByIndex(f.Index)
+		if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return fmt.Errorf("config field %s: want a duration string like \"90s\"", name)
+			}
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("config field %s: %w", name, err)
+			}
+			fv.SetInt(int64(d))
+			continue
+		}
+		if err := json.Unmarshal(raw, fv.Addr().Interface()); err != nil {
+			return fmt.Errorf("config field %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// writerStats counts a DB writer's outcomes; read by the coordinator.
+type writerStats struct {
+	stored atomic.Int64
+	errors atomic.Int64
+}
+
+// startWriter saves every value from in on its own goroutine until in is
+// closed and empty, giving each save its own timeout.
+func startWriter[T any](ctx context.Context, wg *sync.WaitGroup, in *queue[T], table string, stats *writerStats, timeout time.Duration, save func(context.Context, T) error, onErr func(T, error)) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		for rec := range in {
-			ictx, cancel := context.WithTimeout(ctx, 5*time.Second)
-			err := repo.InsertImage(ictx, rec)
+		for {
+			v, ok := in.Pop()
+			if !ok {
+				return
+			}
+			start := time.Now()
+			sctx, cancel := context.WithTimeout(ctx, timeout)
+			err := save(sctx, v)
 			cancel()
+			dbWriteDuration.Observe(time.Since(start).Seconds(), table)
 			if err != nil {
-				logf("db insert error: %v", err)
+				dbWriteErrors.Inc(table)
+				stats.errors.Add(1)
+				onErr(v, err)
+				continue
 			}
+			stats.stored.Add(1)
 		}
 	}()
 }
 
+// failureClass is render.Classify plus robots.txt blocks.
+func failureClass(err error) render.ErrorClass {
+	if errors.Is(err, robots.ErrDisallowed) {
+		return render.ClassRobots
+	}
+	return render.Classify(err)
+}
+
+// failureStatus is the HTTP status carried by err, or 0.
+func failureStatus(err error) int {
+	var fe *render.Error
+	if errors.As(err, &fe) {
+		return fe.StatusCode
+	}
+	return 0
+}
+
+// fetchResult is the pageResult for fp without anything extracted yet.
+func fetchResult(t URLTask, fp render.FetchedPage, fetchedAt time.Time) pageResult {
+	return pageResult{
+		Task:        t,
+		FinalURL:    fp.FinalURL,
+		Redirects:   fp.Redirects,
+		Status:      fp.StatusCode,
+		ContentType: fp.ContentType,
+		Rendered:    fp.Rendered,
+		FetchedAt:   fetchedAt,
+		Attempts:    fp.Attempts,
+	}
+}
+
+// fetchTask fetches t: pages with the DOM renderer, everything else over
+// plain HTTP. The renderer cannot send conditional requests, so when ctx
+// carries validators a conditional GET over HTTP first checks whether the
+// page changed at all.
+func fetchTask(ctx context.Context, t URLTask, domFetcher, httpFetcher render.Fetcher) (render.FetchedPage, error) {
+	if t.Kind != "page" {
+		return httpFetcher.Fetch(ctx, t.URL)
+	}
+	var probe render.FetchedPage
+	probed := false
+	if domFetcher != httpFetcher && !render.ValidatorsFrom(ctx).IsZero() {
+		fp, err := httpFetcher.Fetch(ctx, t.URL)
+		if err != nil || fp.NotModified {
+			return fp, err
+		}
+		probe, probed = fp, true
+	}
+	fp, err := domFetcher.Fetch(ctx, t.URL)
+	if err != nil && httpFetcher != domFetcher && failureStatus(err) == 0 {
+		// The browser failed; a status error is the server's answer, which
+		// plain HTTP would only get again. The probe already holds the page
+		// as plain HTTP sees it.
+		if probed {
+			return probe, nil
+		}
+		return httpFetcher.Fetch(ctx, t.URL)
+	}
+	if fp.Header == nil {
+		fp.Header = probe.Header // keep the validators the renderer can't see
+	}
+	return fp, err
+}
+
+// thumbInserts converts thumbnail variants for storage.
+func thumbInserts(thumbs []images.Thumb) []storage.ThumbInsert {
+	out := make([]storage.ThumbInsert, 0, len(thumbs))
+	for _, t := range thumbs {
+		out = append(out, storage.ThumbInsert{
+			ThumbVariant: storage.ThumbVariant{Profile: t.Profile, Width: t.Width, Height: t.Height, MIME: t.MIME},
+			Path:         t.Path,
+			Blob:         t.Bytes,
+		})
+	}
+	return out
+}
+
+// imageColors converts dominant colors for storage.
+func imageColors(colors []images.DominantColor) []storage.ImageColor {
+	out := make([]storage.ImageColor, 0, len(colors))
+	for _, c := range colors {
+		out = append(out, storage.ImageColor{R: c.R, G: c.G, B: c.B, Share: c.Share})
+	}
+	return out
+}
+
+// fetchImage downloads one image, conditionally on its stored validators,
+// or skips it when it is still fresh. A skipped or 304 image comes back
+// with Proc.NotModified set; replace reports a new download of an image an
+// earlier crawl already stored.
+func fetchImage(ctx context.Context, dl *images.Downloader, rc *recrawl, u string) (images.Processed, *storage.Validators, bool, error) {
+	if strings.HasPrefix(strings.ToLower(u), "data:") {
+		proc, err := dl.DownloadAndThumbnail(ctx, u)
+		return proc, nil, false, err
+	}
+	now := time.Now()
+	v, fresh, seen := rc.lookup(ctx, u)
+	if fresh {
+		return images.Processed{OriginalURL: u, NotModified: true}, nil, false, nil
+	}
+	ictx, cancel := context.WithTimeout(render.WithValidators(ctx, v), 45*time.Second)
+	defer cancel()
+	start := time.Now()
+	proc, err := dl.DownloadAndThumbnail(ictx, u)
+	fetchDuration.Observe(time.Since(start).Seconds(), "image")
+	bytesDownloaded.Add(float64(proc.Bytes), "image")
+	if err != nil {
+		return proc, nil, false, err
+	}
+	if !proc.NotModified {
+		v = render.Validators{ETag: proc.ETag, LastModified: proc.LastModified}
+	}
+	return proc, checked(u, v, now), seen && !proc.NotModified, nil
+}
+
+// parseSitemap fills in a fetched sitemap's pages and images. Nested
+// sitemaps (from a sitemap index) come back as "sitemap" resources.
+func parseSitemap(pr pageResult, body []byte) pageResult {
+	sm, err := extract.FromSitemap(pr.FinalURL, body)
+	if err != nil {
+		pr.Err = render.DecodeError(err)
+		return pr
+	}
+	for _, u := range sm.Sitemaps {
+		pr.Resources = append(pr.Resources, extract.ResourceRef{URL: u, Kind: "sitemap", PageURL: pr.FinalURL})
+	}
+	pr.Links, pr.Images = sm.Pages, sm.Images
+	return pr
+}
+
+// discoverSitemaps returns the Sitemap: URLs from each seed host's robots.txt,
+// or /sitemap.xml for hosts whose robots.txt lists none.
+func discoverSitemaps(ctx context.Context, seeds []string, userAgent string) []string {
+	rc := robots.NewCache(userAgent)
+	seenHosts := make(map[string]struct{})
+	var out []string
+	for _, s := range seeds {
+		pu, err := url.Parse(s)
+		if err != nil || pu.Host == "" {
+			continue
+		}
+		base := pu.Scheme + "://" + pu.Host
+		if _, ok := seenHosts[base]; ok {
+			continue
+		}
+		seenHosts[base] = struct{}{}
+
+		rctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		rb, err := rc.Get(rctx, base+"/")
+		cancel()
+		if err == nil && len(rb.Sitemaps) > 0 {
+			out = append(out, rb.Sitemaps...)
+			continue
+		}
+		out = append(out, base+"/sitemap.xml")
+	}
+	return out
+}
+
+// checkRobots returns robots.ErrDisallowed for blocked URLs. Crawl-delay is
+// enforced by the scheduler. data: URLs and a nil cache are always allowed.
+func checkRobots(ctx context.Context, rb *robots.Cache, u string) error {
+	if rb == nil || strings.HasPrefix(strings.ToLower(u), "data:") {
+		return nil
+	}
+	ok, err := rb.Allowed(ctx, u)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return robots.ErrDisallowed
+	}
+	return nil
+}
+
 func looksLikeHTML(contentType string, body []byte) bool {
 	ct := strings.ToLower(contentType)
 	if strings.Contains(ct, "text/html") || strings.Contains(ct, "application/xhtml") {
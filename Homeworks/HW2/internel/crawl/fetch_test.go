@@ -0,0 +1,61 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yourname/go-image-crawler/internal/render"
+)
+
+// fakeFetcher answers every URL with the same page or error and counts calls.
+type fakeFetcher struct {
+	fp    render.FetchedPage
+	err   error
+	calls int
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context, url string) (render.FetchedPage, error) {
+	f.calls++
+	return f.fp, f.err
+}
+
+func (f *fakeFetcher) Close() error { return nil }
+
+func TestProcessTask_RenderedErrorPage(t *testing.T) {
+	dom := &fakeFetcher{
+		fp:  render.FetchedPage{FinalURL: "http://x/gone", StatusCode: 404, Rendered: true},
+		err: render.StatusError(404, ""),
+	}
+	http := &fakeFetcher{fp: render.FetchedPage{StatusCode: 200, ContentType: "text/html", Body: []byte(`<a href="/secret">x</a>`)}}
+
+	rc := &recrawl{repo: testRepo(t)}
+	pr := processTask(context.Background(), URLTask{URL: "http://x/gone", Kind: "page"}, dom, http, nil, rc)
+	if render.Classify(pr.Err) != render.ClassHTTP4xx || pr.Status != 404 || !pr.Rendered || len(pr.Links) != 0 {
+		t.Fatalf("result = %+v", pr)
+	}
+	if http.calls != 0 {
+		t.Fatalf("status error fell back to HTTP (%d calls)", http.calls)
+	}
+
+	// A browser failure still falls back to plain HTTP.
+	dom.fp, dom.err = render.FetchedPage{}, errors.New("chrome crashed")
+	pr = processTask(context.Background(), URLTask{URL: "http://x/", Kind: "page"}, dom, http, nil, rc)
+	if pr.Err != nil || http.calls != 1 || len(pr.Links) != 1 {
+		t.Fatalf("fallback: %+v, %d HTTP calls", pr, http.calls)
+	}
+}
+
+func TestFetchTask_BrowserFailsAfterProbe(t *testing.T) {
+	dom := &fakeFetcher{err: errors.New("chrome crashed")}
+	http := &fakeFetcher{fp: render.FetchedPage{StatusCode: 200, ContentType: "text/html", Body: []byte("<html>changed</html>")}}
+
+	ctx := render.WithValidators(context.Background(), render.Validators{ETag: `"v1"`})
+	fp, err := fetchTask(ctx, URLTask{URL: "http://x/", Kind: "page"}, dom, http)
+	if err != nil || string(fp.Body) != "<html>changed</html>" {
+		t.Fatalf("fetchTask = %+v, %v", fp, err)
+	}
+	if http.calls != 1 || dom.calls != 1 {
+		t.Fatalf("%d HTTP and %d browser fetches, want the probe reused", http.calls, dom.calls)
+	}
+}
@@ -0,0 +1,73 @@
+package crawl
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/yourname/go-image-crawler/internal/metrics"
+	"github.com/yourname/go-image-crawler/internal/render"
+)
+
+// Crawler metrics, served by "crawler -metrics-addr" and "crawler serve".
+// They are process-wide: concurrent crawls add up.
+var (
+	pagesFetched = metrics.Default.NewCounter("crawler_pages_fetched_total",
+		`Page, resource and sitemap fetches by kind and final HTTP status ("none" when no response arrived).`, "kind", "status")
+	bytesDownloaded = metrics.Default.NewCounter("crawler_downloaded_bytes_total",
+		"Response body bytes downloaded, by fetcher (http, chromedp, image).", "fetcher")
+	fetchDuration = metrics.Default.NewHistogram("crawler_fetch_duration_seconds",
+		"Time per page fetch attempt, or per image download and thumbnail, by fetcher.", nil, "fetcher")
+	imageDecodeFailures = metrics.Default.NewCounter("crawler_image_decode_failures_total",
+		"Images that could not be decoded, by declared format.", "format")
+	imagesUnsupported = metrics.Default.NewCounter("crawler_images_unsupported_total",
+		"Images skipped because their format (such as AVIF) has no decoder, by detected format.", "format")
+	queueDepth = metrics.Default.NewGauge("crawler_queue_depth",
+		"Items waiting in each crawler queue, sampled on every coordinator event.", "queue")
+	activeWorkers = metrics.Default.NewGauge("crawler_active_workers",
+		"Workers busy with a task, by pool.", "pool")
+	dbWriteDuration = metrics.Default.NewHistogram("crawler_db_write_duration_seconds",
+		"Time per DB write, by table.", nil, "table")
+	dbWriteErrors = metrics.Default.NewCounter("crawler_db_write_errors_total",
+		"Failed DB writes, by table.", "table")
+)
+
+// timedFetcher records the latency and body size of every fetch attempt.
+type timedFetcher struct {
+	render.Fetcher
+	name string
+}
+
+func (f timedFetcher) Fetch(ctx context.Context, url string) (render.FetchedPage, error) {
+	start := time.Now()
+	fp, err := f.Fetcher.Fetch(ctx, url)
+	fetchDuration.Observe(time.Since(start).Seconds(), f.name)
+	bytesDownloaded.Add(float64(len(fp.Body)), f.name)
+	return fp, err
+}
+
+// statusLabel is the status label of a fetched page.
+func statusLabel(code int) string {
+	if code == 0 {
+		return "none"
+	}
+	return strconv.Itoa(code)
+}
+
+// depthGauges is one crawl's share of crawler_queue_depth. It adds the
+// change since the last sample, so concurrent crawls sum up.
+type depthGauges map[string]int
+
+func (d depthGauges) set(queue string, n int) {
+	if n != d[queue] {
+		queueDepth.Add(float64(n-d[queue]), queue)
+		d[queue] = n
+	}
+}
+
+// reset withdraws this crawl's share when it ends.
+func (d depthGauges) reset() {
+	for q := range d {
+		d.set(q, 0)
+	}
+}
@@ -0,0 +1,75 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRun_Metrics(t *testing.T) {
+	img := pngBytes(t, 200)
+	const avif = "\x00\x00\x00\x1cftypavif\x00\x00\x00\x00avifmif1miaf"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<a href="/gone">x</a><img src="/ok.png"><img src="/broken.gif"><img src="/new.avif">`))
+		case "/ok.png":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(img)
+		case "/broken.gif":
+			w.Header().Set("Content-Type", "image/gif")
+			_, _ = w.Write([]byte("GIF89a not really"))
+		case "/new.avif":
+			w.Header().Set("Content-Type", "image/avif")
+			_, _ = w.Write([]byte(avif))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ok := pagesFetched.Value("page", "200")
+	missing := pagesFetched.Value("page", "404")
+	broken := imageDecodeFailures.Value("gif")
+	unsupported := imagesUnsupported.Value("avif")
+	fetches := fetchDuration.Count("http")
+	images := fetchDuration.Count("image")
+	bytes := bytesDownloaded.Value("image")
+	writes := dbWriteDuration.Count("images")
+
+	cfg := testConfig(t)
+	cfg.RetryAttempts = 1
+	if err := Run(context.Background(), []string{srv.URL + "/"}, testRepo(t), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"pages 200", pagesFetched.Value("page", "200") - ok, 1},
+		{"pages 404", pagesFetched.Value("page", "404") - missing, 1},
+		{"gif decode failures", imageDecodeFailures.Value("gif") - broken, 1},
+		{"unsupported avif", imagesUnsupported.Value("avif") - unsupported, 1},
+		{"http fetches", float64(fetchDuration.Count("http") - fetches), 2},
+		{"image fetches", float64(fetchDuration.Count("image") - images), 3},
+		{"image bytes", bytesDownloaded.Value("image") - bytes, float64(len(img) + len("GIF89a not really") + len(avif))},
+		{"image writes", float64(dbWriteDuration.Count("images") - writes), 1},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: %v, want %v", c.name, c.got, c.want)
+		}
+	}
+	for _, q := range []string{"jobs", "imgJobs", "dbInserts", "pageInserts", "failures", "validators"} {
+		if v := queueDepth.Value(q); v != 0 {
+			t.Errorf("queue %s depth %v after the crawl, want 0", q, v)
+		}
+	}
+	for _, pool := range []string{"page", "image"} {
+		if v := activeWorkers.Value(pool); v != 0 {
+			t.Errorf("%d %s workers still active", int(v), pool)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package crawl
+
+import (
+	"context"
+	"time"
+
+	"github.com/yourname/go-image-crawler/internal/extract"
+	"github.com/yourname/go-image-crawler/internal/render"
+	"github.com/yourname/go-image-crawler/internal/storage"
+)
+
+// maxStoredLinks caps the links replayed for an unchanged page.
+const maxStoredLinks = 100000
+
+// recrawl decides, per URL, whether an earlier crawl's copy can be reused.
+// URLs checked within after are skipped without a request; older ones are
+// fetched conditionally on their stored ETag/Last-Modified. Unchanged pages
+// replay their stored links so the crawl still reaches everything below
+// them.
+type recrawl struct {
+	repo  storage.Repository
+	after time.Duration
+}
+
+// lookup returns the stored validators for u, whether u is still fresh and
+// whether it was fetched successfully before at all. Lookup errors are
+// treated as "never fetched".
+func (rc *recrawl) lookup(ctx context.Context, u string) (v render.Validators, fresh, seen bool) {
+	lctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	sv, err := rc.repo.GetValidators(lctx, u)
+	if err != nil {
+		return render.Validators{}, false, false
+	}
+	fresh = rc.after > 0 && time.Since(sv.CheckedAt) < rc.after
+	return render.Validators{ETag: sv.ETag, LastModified: sv.LastModified}, fresh, true
+}
+
+// unchanged rebuilds the result of page task t from the stored page and its
+// links. ok is false when the page was never stored or its last fetch
+// failed, since then there are no links to replay.
+func (rc *recrawl) unchanged(ctx context.Context, t URLTask, fetchedAt time.Time) (pageResult, bool) {
+	lctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	page, err := rc.repo.GetPage(lctx, t.URL)
+	if err != nil || page.Error.Valid || page.Status.Int64 < 200 || page.Status.Int64 > 299 {
+		return pageResult{}, false
+	}
+	links, err := rc.repo.PageLinks(lctx, page.ID, maxStoredLinks)
+	if err != nil {
+		return pageResult{}, false
+	}
+	pr := pageResult{
+		Task:        t,
+		FinalURL:    nonEmpty(page.FinalURL.String, t.URL),
+		Status:      int(page.Status.Int64),
+		ContentType: page.ContentType.String,
+		Rendered:    page.Rendered,
+		FetchedAt:   fetchedAt,
+		NotModified: true,
+	}
+	for _, l := range links {
+		switch l.Kind {
+		case storage.LinkPage:
+			pr.Links = append(pr.Links, l.URL)
+		case storage.LinkImage:
+			pr.Images = append(pr.Images, extract.ImageRef{URL: l.URL, PageURL: pr.FinalURL, Filename: filenameFromURL(l.URL),
+				Alt: l.Alt, Title: l.Title, Caption: l.Caption, PageTitle: l.PageTitle})
+		case storage.LinkResource:
+			kind := "resource"
+			if t.Kind == "sitemap" {
+				kind = "sitemap" // nested sitemaps of an index
+			}
+			pr.Resources = append(pr.Resources, extract.ResourceRef{URL: l.URL, Kind: kind, PageURL: pr.FinalURL})
+		}
+	}
+	return pr, true
+}
+
+// checked is the validators row for a successful fetch of u at at.
+func checked(u string, v render.Validators, at time.Time) *storage.Validators {
+	return &storage.Validators{URL: u, ETag: v.ETag, LastModified: v.LastModified, CheckedAt: at}
+}
@@ -0,0 +1,603 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourname/go-image-crawler/internal/storage"
+)
+
+// testRepo opens a migrated SQLite database in a temp dir.
+func testRepo(t *testing.T) storage.Repository {
+	t.Helper()
+	repo, err := storage.Open("sqlite://" + filepath.Join(t.TempDir(), "crawl.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	if _, err := repo.MigrateUp(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func testConfig(t *testing.T) Config {
+	return Config{
+		Workers:      4,
+		ImageWorkers: 4,
+		Timeout:      30 * time.Second,
+		MaxPages:     100000,
+		ThumbDir:     t.TempDir(),
+		StateDir:     t.TempDir(),
+		UserAgent:    "test",
+		MaxPerHost:   8,
+		Logf:         func(string, ...any) {},
+	}
+}
+
+func pngBytes(t *testing.T, shade uint8) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = shade
+	}
+	img.Set(0, 0, color.Black)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// slowInserts makes image inserts queue up behind the DB writer.
+type slowInserts struct{ storage.Repository }
+
+func (r slowInserts) InsertImage(ctx context.Context, in storage.ImageInsert) error {
+	select {
+	case <-time.After(20 * time.Millisecond):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return r.Repository.InsertImage(ctx, in)
+}
+
+func TestRun_CancelKeepsFinishedWork(t *testing.T) {
+	const nImages = 20
+	var served atomic.Int32
+	slowHit := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/":
+			var b strings.Builder
+			b.WriteString(`<html><body><a href="/slow">slow</a>`)
+			for i := range nImages {
+				fmt.Fprintf(&b, `<img src="/img/%d.png">`, i)
+			}
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(b.String()))
+		case r.URL.Path == "/slow":
+			select {
+			case slowHit <- struct{}{}:
+			default:
+			}
+			<-r.Context().Done() // never answers
+		case strings.HasPrefix(r.URL.Path, "/img/"):
+			var i int
+			_, _ = fmt.Sscanf(r.URL.Path, "/img/%d.png", &i)
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(pngBytes(t, uint8(i*10)))
+			served.Add(1)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	repo := testRepo(t)
+	cfg := testConfig(t)
+	cfg.CrawlID = "cancel-test"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-slowHit
+		for served.Load() < nImages {
+			time.Sleep(time.Millisecond)
+		}
+		// Let the last downloads finish; most inserts are still queued.
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	if err := Run(ctx, []string{srv.URL + "/"}, slowInserts{repo}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	srv.CloseClientConnections()
+
+	rec, err := repo.GetCrawl(context.Background(), cfg.CrawlID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.StopReason.String != StopCanceled {
+		t.Errorf("stop reason = %q, want %q", rec.StopReason.String, StopCanceled)
+	}
+	_, total, err := repo.Search(context.Background(), storage.SearchParams{CrawlID: cfg.CrawlID, PageSize: 100})
+	if err != nil || total != nImages || rec.ImagesStored != nImages {
+		t.Errorf("images stored: search=%d counter=%d err=%v, want %d", total, rec.ImagesStored, err, nImages)
+	}
+
+	st, err := LoadState(cfg.StateDir, cfg.CrawlID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Pending) != 1 || st.Pending[0].URL != srv.URL+"/slow" {
+		t.Errorf("checkpointed frontier = %+v, want just /slow", st.Pending)
+	}
+}
+
+// TestRun_Resume interrupts a crawl while a page and an image hang, then
+// resumes it by id: the two pending tasks are fetched, nothing already
+// stored is downloaded again, and new links keep being followed.
+func TestRun_Resume(t *testing.T) {
+	const nImages = 5
+	var (
+		resumed  atomic.Bool
+		mu       sync.Mutex
+		hits     = map[string]int{}
+		slowPage = make(chan struct{}, 1)
+		slowImg  = make(chan struct{}, 1)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		html := func(body string) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html><body>" + body))
+		}
+		switch {
+		case r.URL.Path == "/":
+			var b strings.Builder
+			b.WriteString(`<a href="/slow">slow</a><img src="/img/slow.png">`)
+			for i := range nImages {
+				fmt.Fprintf(&b, `<img src="/img/%d.png">`, i)
+			}
+			html(b.String())
+		case r.URL.Path == "/slow" && !resumed.Load():
+			slowPage <- struct{}{}
+			<-r.Context().Done()
+		case r.URL.Path == "/slow":
+			html(`<a href="/next">next</a><img src="/img/0.png"><img src="/img/new.png">`)
+		case r.URL.Path == "/next":
+			html(`<a href="/">home</a>`)
+		case r.URL.Path == "/img/slow.png" && !resumed.Load():
+			slowImg <- struct{}{}
+			<-r.Context().Done()
+		case strings.HasPrefix(r.URL.Path, "/img/"):
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(pngBytes(t, uint8(len(r.URL.Path)*7)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	served := func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return hits[path]
+	}
+
+	repo := testRepo(t)
+	cfg := testConfig(t)
+	cfg.CrawlID = "resume-test"
+	cfg.RetryAttempts = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-slowPage
+		<-slowImg
+		for i := range nImages {
+			for served(fmt.Sprintf("/img/%d.png", i)) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	if err := Run(ctx, []string{srv.URL + "/"}, repo, cfg); err != nil {
+		t.Fatal(err)
+	}
+	srv.CloseClientConnections()
+	st, err := LoadState(cfg.StateDir, cfg.CrawlID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Pending) != 1 || len(st.PendingImages) != 1 {
+		t.Fatalf("checkpoint: pending %+v, pending images %+v", st.Pending, st.PendingImages)
+	}
+
+	resumed.Store(true)
+	cfg.Resume = true
+	if err := Run(context.Background(), nil, repo, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]int{
+		"/": 1, "/slow": 2, "/next": 1, "/img/slow.png": 2, "/img/0.png": 1, "/img/new.png": 1,
+	} {
+		if got := served(path); got != want {
+			t.Errorf("%s fetched %d times, want %d", path, got, want)
+		}
+	}
+	rec, err := repo.GetCrawl(context.Background(), cfg.CrawlID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, total, err := repo.Search(context.Background(), storage.SearchParams{CrawlID: cfg.CrawlID, PageSize: 100})
+	if err != nil || total != nImages+2 || rec.ImagesStored != nImages+2 {
+		t.Errorf("images: search=%d counter=%d err=%v, want %d", total, rec.ImagesStored, err, nImages+2)
+	}
+	if rec.StopReason.String != StopExhausted || rec.PagesProcessed != 3 {
+		t.Errorf("crawl: stop %q, %d pages, want %q and 3", rec.StopReason.String, rec.PagesProcessed, StopExhausted)
+	}
+	if st, err := LoadState(cfg.StateDir, cfg.CrawlID); err != nil || len(st.Pending)+len(st.PendingImages) != 0 {
+		t.Errorf("final checkpoint: %v, %+v", err, st)
+	}
+}
+
+// TestRun_HighFanOut crawls pages with thousands of links each through tiny
+// worker pools, so every page yields far more tasks than the channels hold.
+func TestRun_HighFanOut(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test")
+	}
+	const (
+		leaves  = 3000 // pages linked from the root
+		hubs    = 4    // pages linking to every leaf and every image
+		nImages = 300
+	)
+	img := pngBytes(t, 128)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b strings.Builder
+		b.WriteString("<html><body>")
+		switch {
+		case r.URL.Path == "/":
+			for i := range hubs {
+				fmt.Fprintf(&b, `<a href="/hub/%d">hub</a>`, i)
+			}
+			for i := range leaves {
+				fmt.Fprintf(&b, `<a href="/leaf/%d">leaf</a>`, i)
+			}
+		case strings.HasPrefix(r.URL.Path, "/hub/"):
+			for i := range leaves {
+				fmt.Fprintf(&b, `<a href="/leaf/%d">leaf</a>`, leaves-1-i)
+			}
+			for i := range nImages {
+				fmt.Fprintf(&b, `<img src="/img/%d.png">`, i)
+			}
+		case strings.HasPrefix(r.URL.Path, "/leaf/"):
+			b.WriteString(`<a href="/">home</a>`)
+		case strings.HasPrefix(r.URL.Path, "/img/"):
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(img)
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(b.String()))
+	}))
+	defer srv.Close()
+
+	repo := testRepo(t)
+	cfg := testConfig(t)
+	cfg.CrawlID = "fan-out"
+	cfg.Workers, cfg.ImageWorkers = 2, 1
+	cfg.Timeout = 2 * time.Minute
+
+	start := time.Now()
+	if err := Run(context.Background(), []string{srv.URL + "/"}, repo, cfg); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := repo.GetCrawl(context.Background(), cfg.CrawlID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.StopReason.String != StopExhausted || rec.PagesProcessed != 1+hubs+leaves || rec.ImagesStored != nImages || rec.FetchErrors != 0 {
+		t.Fatalf("after %s: stop=%s pages=%d images=%d fetch errors=%d; want %s, %d pages, %d images",
+			time.Since(start).Round(time.Millisecond), rec.StopReason.String, rec.PagesProcessed, rec.ImagesStored, rec.FetchErrors,
+			StopExhausted, 1+hubs+leaves, nImages)
+	}
+}
+
+func TestRun_ImagesPerHostLimit(t *testing.T) {
+	img := pngBytes(t, 90)
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/img/") {
+			mu.Lock()
+			inFlight++
+			peak = max(peak, inFlight)
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+			}()
+			time.Sleep(20 * time.Millisecond)
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(img)
+			return
+		}
+		var b strings.Builder
+		for i := range 8 {
+			fmt.Fprintf(&b, `<img src="/img/%d.png">`, i)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><body>" + b.String() + "</body></html>"))
+	}))
+	defer srv.Close()
+
+	repo := testRepo(t)
+	cfg := testConfig(t)
+	cfg.CrawlID = "img-per-host"
+	cfg.MaxPerHost = 1
+	if err := Run(context.Background(), []string{srv.URL + "/"}, repo, cfg); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := repo.GetCrawl(context.Background(), cfg.CrawlID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.ImagesStored != 8 {
+		t.Fatalf("stored %d images, want 8", rec.ImagesStored)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if peak != 1 {
+		t.Fatalf("%d concurrent image requests to one host, want 1", peak)
+	}
+}
+
+func TestRun_RobotsCrawlDelay(t *testing.T) {
+	img := pngBytes(t, 60)
+	var mu sync.Mutex
+	var paths []string
+	var starts []time.Time
+	inFlight, peak := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path != "/robots.txt" {
+			starts = append(starts, time.Now())
+			inFlight++
+			peak = max(peak, inFlight)
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			if r.URL.Path != "/robots.txt" {
+				inFlight--
+			}
+			mu.Unlock()
+		}()
+		switch {
+		case r.URL.Path == "/robots.txt":
+			_, _ = w.Write([]byte("User-agent: *\nCrawl-delay: 0.05\n"))
+		case strings.HasPrefix(r.URL.Path, "/img/"):
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(img)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body><a href="/a">a</a><a href="/b">b</a><img src="/img/1.png"><img src="/img/2.png"></body></html>`))
+		}
+	}))
+	defer srv.Close()
+
+	repo := testRepo(t)
+	cfg := testConfig(t)
+	cfg.CrawlID = "crawl-delay"
+	cfg.RespectRobots = true
+	if err := Run(context.Background(), []string{srv.URL + "/"}, repo, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) != 6 || paths[0] != "/robots.txt" {
+		t.Fatalf("requests %v, want robots.txt first and 5 more", paths)
+	}
+	if peak != 1 {
+		t.Fatalf("%d concurrent requests under a Crawl-delay, want 1", peak)
+	}
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap < 45*time.Millisecond {
+			t.Fatalf("requests %d and %d only %s apart", i-1, i, gap)
+		}
+	}
+}
+
+// TestRun_RecrawlAfterFailure runs a crawl, a recrawl in which /mid fails
+// and a recrawl in which everything is unchanged: /mid must be fetched in
+// full again, or its stored (empty) links would cut /leaf off for good.
+func TestRun_RecrawlAfterFailure(t *testing.T) {
+	var (
+		failing atomic.Bool
+		mu      sync.Mutex
+		full    = map[string]int{} // 200 responses per path
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/mid" && failing.Load() {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		mu.Lock()
+		full[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(`<html><body><a href="/mid">mid</a></body></html>`))
+		case "/mid":
+			_, _ = w.Write([]byte(`<html><body><a href="/leaf">leaf</a></body></html>`))
+		default:
+			_, _ = w.Write([]byte(`<html><body>leaf</body></html>`))
+		}
+	}))
+	defer srv.Close()
+
+	type summary struct{ pages, unchanged, errors int }
+	repo := testRepo(t)
+	crawl := func(id string) summary {
+		t.Helper()
+		cfg := testConfig(t)
+		cfg.CrawlID = id
+		if err := Run(context.Background(), []string{srv.URL + "/"}, repo, cfg); err != nil {
+			t.Fatal(err)
+		}
+		rec, err := repo.GetCrawl(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return summary{rec.PagesProcessed, rec.Unchanged, rec.FetchErrors}
+	}
+
+	if got := crawl("first"); got != (summary{pages: 3}) {
+		t.Fatalf("first crawl: %+v", got)
+	}
+	failing.Store(true)
+	if got := crawl("failing"); got != (summary{pages: 2, unchanged: 1, errors: 1}) {
+		t.Fatalf("failing recrawl: %+v", got)
+	}
+	failing.Store(false)
+	if got := crawl("unchanged"); got != (summary{pages: 3, unchanged: 2}) {
+		t.Fatalf("recrawl after the failure: %+v", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if full["/mid"] != 2 || full["/leaf"] != 1 || full["/"] != 1 {
+		t.Fatalf("full fetches %v, want /mid twice and the rest once", full)
+	}
+}
+
+func TestRun_RecrawlChangedImage(t *testing.T) {
+	var version atomic.Int32
+	version.Store(1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/img/a.png" {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<html><body><img src="/img/a.png" alt="a"></body></html>`))
+			return
+		}
+		etag := fmt.Sprintf(`"v%d"`, version.Load())
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		// Each version is a different picture of a different size.
+		v := int(version.Load())
+		img := image.NewGray(image.Rect(0, 0, 4*v, 3*v))
+		for i := range img.Pix {
+			img.Pix[i] = uint8(100 * v)
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_ = png.Encode(w, img)
+	}))
+	defer srv.Close()
+
+	repo := testRepo(t)
+	stored := func(id string) storage.ImageRecord {
+		t.Helper()
+		cfg := testConfig(t)
+		cfg.CrawlID = id
+		if err := Run(context.Background(), []string{srv.URL + "/"}, repo, cfg); err != nil {
+			t.Fatal(err)
+		}
+		items, total, err := repo.Search(context.Background(), storage.SearchParams{})
+		if err != nil || total != 1 {
+			t.Fatalf("%s: %d images, %v", id, total, err)
+		}
+		rec, err := repo.GetImage(context.Background(), items[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	first := stored("first")
+	version.Store(2)
+	second := stored("changed")
+	if first.Width.Int64 != 4 || second.Width.Int64 != 8 || second.Colors[0].Hex() != "#c8c8c8" {
+		t.Fatalf("width %d then %d, color %s: the changed image was not stored",
+			first.Width.Int64, second.Width.Int64, second.Colors[0].Hex())
+	}
+	if second.Alt.String != "a" || second.CrawlID.String != "first" {
+		t.Fatalf("alt %q crawl %q, want the first-seen values", second.Alt.String, second.CrawlID.String)
+	}
+}
+
+func TestRun_RecrawlImageText(t *testing.T) {
+	var imageOK atomic.Bool
+	var pageFetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/img/a.png" {
+			if !imageOK.Load() {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(pngBytes(t, 200))
+			return
+		}
+		w.Header().Set("ETag", `"home"`)
+		if r.Header.Get("If-None-Match") == `"home"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		pageFetches.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><title>Home</title></head><body><figure>
+<img src="/img/a.png" alt="a cat" title="Cat"><figcaption>A cat on a mat</figcaption></figure></body></html>`))
+	}))
+	defer srv.Close()
+
+	repo := testRepo(t)
+	for _, id := range []string{"first", "second"} {
+		cfg := testConfig(t)
+		cfg.CrawlID = id
+		if err := Run(context.Background(), []string{srv.URL + "/"}, repo, cfg); err != nil {
+			t.Fatal(err)
+		}
+		// The image is only served from the second crawl on, when the page
+		// answers 304 and its image is replayed from the stored links.
+		imageOK.Store(true)
+	}
+	if n := pageFetches.Load(); n != 1 {
+		t.Fatalf("page fetched in full %d times, want 1", n)
+	}
+	items, total, err := repo.Search(context.Background(), storage.SearchParams{Q: "mat"})
+	if err != nil || total != 1 {
+		t.Fatalf("search by caption: %d images, %v", total, err)
+	}
+	if im := items[0]; im.Alt.String != "a cat" || im.Title.String != "Cat" ||
+		im.Caption.String != "A cat on a mat" || im.PageTitle.String != "Home" {
+		t.Fatalf("replayed image text = %q %q %q %q", im.Alt.String, im.Title.String, im.Caption.String, im.PageTitle.String)
+	}
+}
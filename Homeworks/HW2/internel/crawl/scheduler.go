@@ -0,0 +1,311 @@
+package crawl
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostTask is anything the scheduler can queue by the host of its URL.
+type hostTask interface {
+	target() string
+}
+
+func (t URLTask) target() string   { return t.URL }
+func (t imageTask) target() string { return t.Ref.URL }
+
+// hostLimits holds what the crawler knows about each host: requests in
+// flight and when the next one may start. Schedulers sharing one hostLimits
+// apply -per-host and the delays to their combined traffic, so pages and
+// images to the same host never add up to more than the limits.
+type hostLimits struct {
+	maxPerHost int
+	delay      time.Duration
+	// delayFor may return a larger delay for a task's host (e.g. robots.txt
+	// Crawl-delay). A host with such a delay gets one request at a time.
+	delayFor func(taskURL string) time.Duration
+	// prepare, if set, runs once per host before its first task is
+	// dispatched (e.g. fetching robots.txt); the host waits until it returns.
+	prepare func(taskURL string)
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+	wakes []chan struct{} // one per scheduler, signaled when a host frees up
+}
+
+type hostState struct {
+	active    int
+	nextAt    time.Time
+	prepared  bool
+	preparing bool
+}
+
+func newHostLimits(maxPerHost int, delay time.Duration, delayFor func(string) time.Duration) *hostLimits {
+	if maxPerHost <= 0 {
+		maxPerHost = 1
+	}
+	return &hostLimits{
+		maxPerHost: maxPerHost,
+		delay:      delay,
+		delayFor:   delayFor,
+		hosts:      make(map[string]*hostState),
+	}
+}
+
+// acquire takes a request slot for taskURL's host. When the host is not
+// eligible it returns how long until it becomes so (0 means wait for a
+// signal). Tasks without a host (data: URLs) need no request and are never
+// held back.
+func (l *hostLimits) acquire(taskURL string, now time.Time) (time.Duration, bool) {
+	h := taskHost(taskURL)
+	if h == "" {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := l.hosts[h]
+	if st == nil {
+		st = &hostState{prepared: l.prepare == nil}
+		l.hosts[h] = st
+	}
+	if !st.prepared {
+		if !st.preparing {
+			st.preparing = true
+			go l.runPrepare(st, taskURL)
+		}
+		return 0, false
+	}
+
+	limit := l.maxPerHost
+	if l.delayFor != nil && l.delayFor(taskURL) > 0 {
+		limit = 1
+	}
+	if st.active >= limit {
+		return 0, false
+	}
+	if d := st.nextAt.Sub(now); d > 0 {
+		return d, false
+	}
+	st.active++
+	st.nextAt = now.Add(l.hostDelay(taskURL))
+	return 0, true
+}
+
+func (l *hostLimits) runPrepare(st *hostState, taskURL string) {
+	l.prepare(taskURL)
+	l.mu.Lock()
+	st.prepared, st.preparing = true, false
+	l.mu.Unlock()
+	l.signal()
+}
+
+// release frees the slot taken by acquire.
+func (l *hostLimits) release(taskURL string) {
+	h := taskHost(taskURL)
+	if h == "" {
+		return
+	}
+	l.mu.Lock()
+	if st := l.hosts[h]; st != nil && st.active > 0 {
+		st.active--
+	}
+	l.mu.Unlock()
+	l.signal()
+}
+
+func (l *hostLimits) hostDelay(taskURL string) time.Duration {
+	d := l.delay
+	if l.delayFor != nil {
+		if hd := l.delayFor(taskURL); hd > d {
+			d = hd
+		}
+	}
+	return d
+}
+
+func (l *hostLimits) signal() {
+	l.mu.Lock()
+	wakes := l.wakes
+	l.mu.Unlock()
+	for _, w := range wakes {
+		select {
+		case w <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// hostScheduler hands tasks to the workers while being polite to every host:
+// it keeps a queue per host, takes a slot from its hostLimits for every task
+// it dispatches and round-robins across hosts so one large site cannot
+// starve the others.
+//
+// Push never blocks (queues are unbounded); workers receive from Jobs().
+type hostScheduler[T hostTask] struct {
+	limits *hostLimits
+
+	out  chan T
+	wake chan struct{}
+	stop chan struct{}
+
+	mu     sync.Mutex
+	hosts  map[string]*hostQueue[T]
+	ring   []string // hosts with queued tasks, in round-robin order
+	cursor int
+	queued int
+}
+
+type hostQueue[T hostTask] struct {
+	tasks  []T
+	inRing bool
+}
+
+func newHostScheduler[T hostTask](limits *hostLimits) *hostScheduler[T] {
+	s := &hostScheduler[T]{
+		limits: limits,
+		out:    make(chan T),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		hosts:  make(map[string]*hostQueue[T]),
+	}
+	limits.mu.Lock()
+	limits.wakes = append(limits.wakes, s.wake)
+	limits.mu.Unlock()
+	return s
+}
+
+// Jobs is the channel the workers read from. It is closed when the scheduler stops.
+func (s *hostScheduler[T]) Jobs() <-chan T { return s.out }
+
+// Push queues a task for its host.
+func (s *hostScheduler[T]) Push(t T) {
+	h := taskHost(t.target())
+	s.mu.Lock()
+	q := s.hosts[h]
+	if q == nil {
+		q = &hostQueue[T]{}
+		s.hosts[h] = q
+	}
+	q.tasks = append(q.tasks, t)
+	s.queued++
+	if !q.inRing {
+		q.inRing = true
+		s.ring = append(s.ring, h)
+	}
+	s.mu.Unlock()
+	s.signal()
+}
+
+// Done releases the host slot taken by a dispatched task.
+func (s *hostScheduler[T]) Done(t T) { s.limits.release(t.target()) }
+
+// Queued is the number of tasks waiting in host queues (not yet handed to a worker).
+func (s *hostScheduler[T]) Queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queued
+}
+
+// Stop ends Run; Jobs() is closed afterwards.
+func (s *hostScheduler[T]) Stop() { close(s.stop) }
+
+func (s *hostScheduler[T]) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run dispatches tasks until ctx is done or Stop is called.
+func (s *hostScheduler[T]) Run(ctx context.Context) {
+	defer close(s.out)
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		t, wait, ok := s.next(time.Now())
+		if ok {
+			select {
+			case s.out <- t:
+				continue
+			case <-ctx.Done():
+				return
+			case <-s.stop:
+				return
+			}
+		}
+
+		var timerC <-chan time.Time
+		if wait > 0 {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			timerC = timer.C
+		}
+		select {
+		case <-s.wake:
+		case <-timerC:
+		case <-ctx.Done():
+			return
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// next pops the next eligible task in round-robin order. When nothing is
+// eligible it returns how long until the earliest host becomes eligible
+// (0 means wait for a Push/Done).
+func (s *hostScheduler[T]) next(now time.Time) (T, time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var wait time.Duration
+	for i := 0; i < len(s.ring); i++ {
+		idx := (s.cursor + i) % len(s.ring)
+		h := s.ring[idx]
+		q := s.hosts[h]
+		if d, ok := s.limits.acquire(q.tasks[0].target(), now); !ok {
+			if d > 0 && (wait == 0 || d < wait) {
+				wait = d
+			}
+			continue
+		}
+
+		t := q.tasks[0]
+		var zero T
+		q.tasks[0] = zero
+		q.tasks = q.tasks[1:]
+		s.queued--
+
+		if len(q.tasks) == 0 {
+			q.tasks = nil
+			q.inRing = false
+			s.ring = append(s.ring[:idx], s.ring[idx+1:]...)
+			if len(s.ring) > 0 {
+				s.cursor = idx % len(s.ring)
+			} else {
+				s.cursor = 0
+			}
+		} else {
+			s.cursor = (idx + 1) % len(s.ring)
+		}
+		return t, 0, true
+	}
+	var zero T
+	return zero, wait, false
+}
+
+func taskHost(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(pu.Host)
+}
@@ -0,0 +1,193 @@
+package crawl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yourname/go-image-crawler/internal/extract"
+)
+
+func TestHostScheduler_RoundRobinAcrossHosts(t *testing.T) {
+	s := newHostScheduler[URLTask](newHostLimits(1, 0, nil))
+	for i := 0; i < 3; i++ {
+		s.Push(URLTask{URL: "https://a.example/" + string(rune('0'+i))})
+	}
+	s.Push(URLTask{URL: "https://b.example/0"})
+	s.Push(URLTask{URL: "https://c.example/0"})
+
+	var hosts []string
+	for {
+		task, _, ok := s.next(time.Now())
+		if !ok {
+			break
+		}
+		hosts = append(hosts, taskHost(task.URL))
+		s.Done(task)
+	}
+	want := []string{"a.example", "b.example", "c.example", "a.example", "a.example"}
+	if len(hosts) != len(want) {
+		t.Fatalf("got %v, want %v", hosts, want)
+	}
+	for i := range want {
+		if hosts[i] != want[i] {
+			t.Fatalf("got %v, want %v", hosts, want)
+		}
+	}
+}
+
+func TestHostScheduler_PerHostLimitAndDelay(t *testing.T) {
+	s := newHostScheduler[URLTask](newHostLimits(1, 50*time.Millisecond, nil))
+	s.Push(URLTask{URL: "https://a.example/1"})
+	s.Push(URLTask{URL: "https://a.example/2"})
+
+	now := time.Now()
+	first, _, ok := s.next(now)
+	if !ok {
+		t.Fatalf("expected first task")
+	}
+	if _, _, ok := s.next(now); ok {
+		t.Fatalf("second task dispatched while the first one is still active")
+	}
+	s.Done(first)
+	_, wait, ok := s.next(now.Add(10 * time.Millisecond))
+	if ok {
+		t.Fatalf("second task dispatched before the host delay elapsed")
+	}
+	if wait <= 0 || wait > 40*time.Millisecond {
+		t.Fatalf("unexpected wait %v", wait)
+	}
+	if _, _, ok := s.next(now.Add(60 * time.Millisecond)); !ok {
+		t.Fatalf("expected second task after the host delay")
+	}
+}
+
+func TestHostScheduler_RunDeliversAndCloses(t *testing.T) {
+	s := newHostScheduler[URLTask](newHostLimits(2, time.Millisecond, nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go s.Run(ctx)
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		s.Push(URLTask{URL: "https://a.example/" + string(rune('a'+i))})
+	}
+	got := 0
+	for got < n {
+		select {
+		case task := <-s.Jobs():
+			got++
+			s.Done(task)
+		case <-ctx.Done():
+			t.Fatalf("timed out after %d tasks", got)
+		}
+	}
+	s.Stop()
+	select {
+	case _, ok := <-s.Jobs():
+		if ok {
+			t.Fatalf("unexpected task after Stop")
+		}
+	case <-ctx.Done():
+		t.Fatalf("Jobs() not closed after Stop")
+	}
+}
+
+func TestHostScheduler_Images(t *testing.T) {
+	s := newHostScheduler[imageTask](newHostLimits(1, time.Hour, nil))
+	s.Push(imageTask{Ref: extract.ImageRef{URL: "https://a.example/1.png"}})
+	s.Push(imageTask{Ref: extract.ImageRef{URL: "https://a.example/2.png"}})
+	for i := 0; i < 3; i++ {
+		s.Push(imageTask{Ref: extract.ImageRef{URL: "data:image/png;base64,AAAA" + string(rune('0'+i))}})
+	}
+
+	now := time.Now()
+	var got []string
+	for {
+		task, _, ok := s.next(now)
+		if !ok {
+			break
+		}
+		got = append(got, task.Ref.URL)
+	}
+	// One download from a.example; inline images are not limited.
+	if len(got) != 4 || got[0] != "https://a.example/1.png" {
+		t.Fatalf("dispatched %v", got)
+	}
+	if s.Queued() != 1 {
+		t.Fatalf("queued = %d, want 1", s.Queued())
+	}
+}
+
+func TestHostLimits_SharedAndCrawlDelay(t *testing.T) {
+	delayFor := func(u string) time.Duration {
+		if taskHost(u) == "slow.example" {
+			return time.Millisecond
+		}
+		return 0
+	}
+	limits := newHostLimits(2, 0, delayFor)
+	pages := newHostScheduler[URLTask](limits)
+	imgs := newHostScheduler[imageTask](limits)
+	now := time.Now()
+
+	// Pages and images to one host share its two slots.
+	pages.Push(URLTask{URL: "https://a.example/1"})
+	pages.Push(URLTask{URL: "https://a.example/2"})
+	imgs.Push(imageTask{Ref: extract.ImageRef{URL: "https://a.example/x.png"}})
+	p1, _, _ := pages.next(now)
+	if _, _, ok := pages.next(now); !ok {
+		t.Fatal("second page not dispatched")
+	}
+	if _, _, ok := imgs.next(now); ok {
+		t.Fatal("image dispatched with both slots of its host taken by pages")
+	}
+	pages.Done(p1)
+	if _, _, ok := imgs.next(now); !ok {
+		t.Fatal("image not dispatched after a page finished")
+	}
+
+	// A Crawl-delay limits the host to one request at a time.
+	pages.Push(URLTask{URL: "https://slow.example/1"})
+	pages.Push(URLTask{URL: "https://slow.example/2"})
+	if _, _, ok := pages.next(now); !ok {
+		t.Fatal("first slow.example page not dispatched")
+	}
+	if _, _, ok := pages.next(now.Add(time.Second)); ok {
+		t.Fatal("second request to a Crawl-delay host while the first is in flight")
+	}
+}
+
+func TestHostLimits_PrepareBeforeFirstDispatch(t *testing.T) {
+	release := make(chan struct{})
+	var prepared []string
+	limits := newHostLimits(4, 0, nil)
+	limits.prepare = func(u string) {
+		<-release
+		prepared = append(prepared, u)
+	}
+	s := newHostScheduler[URLTask](limits)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go s.Run(ctx)
+	defer s.Stop()
+
+	s.Push(URLTask{URL: "https://a.example/1"})
+	s.Push(URLTask{URL: "https://a.example/2"})
+	select {
+	case task := <-s.Jobs():
+		t.Fatalf("%s dispatched before its host was prepared", task.URL)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	for range 2 {
+		select {
+		case <-s.Jobs():
+		case <-ctx.Done():
+			t.Fatal("tasks not dispatched after prepare returned")
+		}
+	}
+	if len(prepared) != 1 {
+		t.Fatalf("prepared %v, want one call", prepared)
+	}
+}
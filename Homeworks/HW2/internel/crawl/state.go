@@ -0,0 +1,122 @@
+package crawl
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yourname/go-image-crawler/internal/extract"
+)
+
+// State is the durable part of a crawl. It is checkpointed periodically and on
+// shutdown so that a later run can continue from the same frontier instead of
+// starting over from the seeds.
+type State struct {
+	ID        string    `json:"id"`
+	Seeds     []string  `json:"seeds"`
+	Processed int       `json:"processed"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Visited holds every URL ever enqueued (pages + resources) with its depth/kind.
+	Visited map[string]URLTask `json:"visited"`
+	// Pending is the frontier: tasks enqueued or in flight when the checkpoint was taken.
+	Pending []URLTask `json:"pending"`
+
+	// Images holds image dedupe keys (see imageKey) of all images ever enqueued.
+	Images []string `json:"images"`
+	// PendingImages are images enqueued but not yet stored.
+	PendingImages []extract.ImageRef `json:"pending_images"`
+
+	// Counters carries the session totals across resumes.
+	Counters Counters `json:"counters"`
+}
+
+// Counters are the outcome totals of a crawl, recorded in its crawls row.
+type Counters struct {
+	ImagesStored  int `json:"images_stored"`
+	FetchErrors   int `json:"fetch_errors"`
+	ImageErrors   int `json:"image_errors"`
+	DBErrors      int `json:"db_errors"`
+	RobotsSkipped int `json:"robots_skipped"`
+	Retries       int `json:"retries"`
+	Unchanged     int `json:"unchanged"`
+}
+
+// ErrNoState is returned by LoadState when no checkpoint exists for the given id.
+var ErrNoState = errors.New("crawl state not found")
+
+func statePath(dir, id string) string {
+	return filepath.Join(dir, id+".json")
+}
+
+// LoadState reads the checkpoint of crawl id from dir.
+func LoadState(dir, id string) (*State, error) {
+	if !validCrawlID(id) {
+		return nil, fmt.Errorf("invalid crawl id %q", id)
+	}
+	b, err := os.ReadFile(statePath(dir, id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNoState, id)
+		}
+		return nil, err
+	}
+	var st State
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("crawl state %s: %w", id, err)
+	}
+	if st.Visited == nil {
+		st.Visited = make(map[string]URLTask)
+	}
+	return &st, nil
+}
+
+// Save writes the checkpoint atomically (temp file + rename), so a crash
+// while saving never leaves a truncated state behind.
+func (s *State) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	s.UpdatedAt = time.Now().UTC()
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, s.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), statePath(dir, s.ID))
+}
+
+// NewCrawlID returns a sortable, unique id like "20240131-154502-9f2c1a7b".
+func NewCrawlID() string {
+	var rb [4]byte
+	_, _ = rand.Read(rb[:])
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(rb[:])
+}
+
+// validCrawlID keeps ids usable as file names (no path separators etc.).
+func validCrawlID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	return strings.IndexFunc(id, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	}) < 0
+}
@@ -33,7 +33,7 @@ func isLikelyImageURL(u string) bool {
 	if strings.Contains(lu, ".woff") || strings.Contains(lu, ".ttf") || strings.Contains(lu, ".eot") || strings.Contains(lu, ".otf") {
 		return false
 	}
-	for _, ext := range []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".bmp", ".svg", ".ico", ".avif"} {
+	for _, ext := range []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".bmp", ".svg", ".ico", ".avif", ".tif", ".cur"} {
 		if strings.Contains(lu, ext) {
 			return true
 		}
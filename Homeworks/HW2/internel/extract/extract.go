@@ -16,6 +16,11 @@ type ImageRef struct {
 	Title    string
 	PageURL  string
 	Filename string
+	// Caption is the <figcaption> of the enclosing <figure>, or the
+	// <image:caption> of an image sitemap entry, if any.
+	Caption string `json:",omitempty"`
+	// PageTitle is the <title> of the page the image was found on.
+	PageTitle string `json:",omitempty"`
 }
 
 type ResourceRef struct {
@@ -25,6 +30,7 @@ type ResourceRef struct {
 }
 
 type Extracted struct {
+	Title     string        // document <title>
 	Links     []string      // page links to traverse
 	Resources []ResourceRef // non-page web resources to crawl (css/js)
 	Images    []ImageRef
@@ -48,8 +54,21 @@ func FromHTML(pageURL string, htmlBytes []byte) (Extracted, error) {
 	seenImgs := map[string]struct{}{}
 
 	var out Extracted
+	out.Title = findTitle(root)
+	// caption is the figcaption text of the <figure> being walked.
+	caption := ""
+	addPageImg := func(img ImageRef) {
+		img.Caption = caption
+		img.PageTitle = out.Title
+		addImg(&out, seenImgs, img)
+	}
 	var walk func(n *html.Node)
 	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && strings.EqualFold(n.Data, "figure") {
+			outer := caption
+			caption = figcaption(n)
+			defer func() { caption = outer }()
+		}
 		if n.Type == html.ElementNode {
 			switch strings.ToLower(n.Data) {
 			case "a":
@@ -82,7 +101,7 @@ func FromHTML(pageURL string, htmlBytes []byte) (Extracted, error) {
 				// icons as images
 				if href != "" && (strings.Contains(rel, "icon") || strings.Contains(rel, "apple-touch-icon") || strings.Contains(rel, "shortcut")) {
 					ru := resolve(base, href)
-					addImg(&out, seenImgs, ImageRef{
+					addPageImg(ImageRef{
 						URL:      ru,
 						PageURL:  pageURL,
 						Filename: filenameFromURL(ru),
@@ -93,7 +112,7 @@ func FromHTML(pageURL string, htmlBytes []byte) (Extracted, error) {
 				cssText := nodeText(n)
 				for _, u := range parseCSSURLs(cssText) {
 					ru := resolve(base, u)
-					addImg(&out, seenImgs, ImageRef{
+					addPageImg(ImageRef{
 						URL:      ru,
 						PageURL:  pageURL,
 						Filename: filenameFromURL(ru),
@@ -108,7 +127,7 @@ func FromHTML(pageURL string, htmlBytes []byte) (Extracted, error) {
 				)
 				if src != "" {
 					ru := resolve(base, src)
-					addImg(&out, seenImgs, ImageRef{
+					addPageImg(ImageRef{
 						URL:      ru,
 						Alt:      attr(n, "alt"),
 						Title:    attr(n, "title"),
@@ -119,7 +138,7 @@ func FromHTML(pageURL string, htmlBytes []byte) (Extracted, error) {
 				if ss := attr(n, "srcset"); ss != "" {
 					for _, u := range parseSrcset(ss) {
 						ru := resolve(base, u)
-						addImg(&out, seenImgs, ImageRef{
+						addPageImg(ImageRef{
 							URL:      ru,
 							Alt:      attr(n, "alt"),
 							Title:    attr(n, "title"),
@@ -132,7 +151,7 @@ func FromHTML(pageURL string, htmlBytes []byte) (Extracted, error) {
 				if ss := attr(n, "srcset"); ss != "" {
 					for _, u := range parseSrcset(ss) {
 						ru := resolve(base, u)
-						addImg(&out, seenImgs, ImageRef{
+						addPageImg(ImageRef{
 							URL:      ru,
 							PageURL:  pageURL,
 							Filename: filenameFromURL(ru),
@@ -143,7 +162,7 @@ func FromHTML(pageURL string, htmlBytes []byte) (Extracted, error) {
 				if strings.EqualFold(attr(n, "property"), "og:image") || strings.EqualFold(attr(n, "name"), "og:image") {
 					if c := attr(n, "content"); c != "" {
 						ru := resolve(base, c)
-						addImg(&out, seenImgs, ImageRef{
+						addPageImg(ImageRef{
 							URL:      ru,
 							PageURL:  pageURL,
 							Filename: filenameFromURL(ru),
@@ -154,7 +173,7 @@ func FromHTML(pageURL string, htmlBytes []byte) (Extracted, error) {
 				// SVG <image href="..."> or xlink:href
 				if href := firstNonEmpty(attr(n, "href"), attr(n, "xlink:href")); href != "" {
 					ru := resolve(base, href)
-					addImg(&out, seenImgs, ImageRef{
+					addPageImg(ImageRef{
 						URL:      ru,
 						PageURL:  pageURL,
 						Filename: filenameFromURL(ru),
@@ -168,7 +187,7 @@ func FromHTML(pageURL string, htmlBytes []byte) (Extracted, error) {
 			if st := attr(n, "style"); st != "" {
 				for _, u := range parseCSSURLs(st) {
 					ru := resolve(base, u)
-					addImg(&out, seenImgs, ImageRef{
+					addPageImg(ImageRef{
 						URL:      ru,
 						PageURL:  pageURL,
 						Filename: filenameFromURL(ru),
@@ -207,6 +226,42 @@ func findBaseHref(root *html.Node) string {
 	return href
 }
 
+func findTitle(root *html.Node) string {
+	var title string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if title != "" {
+			return
+		}
+		if n.Type == html.ElementNode && strings.EqualFold(n.Data, "svg") {
+			return // <title> inside inline SVG labels the drawing, not the page
+		}
+		if n.Type == html.ElementNode && strings.EqualFold(n.Data, "title") {
+			title = collapseSpace(nodeText(n))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return title
+}
+
+// figcaption returns the text of the figure's own <figcaption>.
+func figcaption(fig *html.Node) string {
+	for c := fig.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && strings.EqualFold(c.Data, "figcaption") {
+			return collapseSpace(nodeText(c))
+		}
+	}
+	return ""
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
 func nodeText(n *html.Node) string {
 	var b strings.Builder
 	var walk func(*html.Node)
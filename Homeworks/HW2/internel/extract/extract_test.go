@@ -0,0 +1,33 @@
+package extract
+
+import "testing"
+
+func TestFromHTML_CaptionAndPageTitle(t *testing.T) {
+	page := []byte(`<html><head><title>
+  Cats of   the week </title></head><body>
+<svg><title>icon</title></svg>
+<figure>
+  <img src="/cat.jpg" alt="a cat">
+  <figcaption>Tom, <b>asleep</b> on the sofa</figcaption>
+</figure>
+<img src="/logo.png">
+</body></html>`)
+
+	ex, err := FromHTML("https://x/week", page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Title != "Cats of the week" {
+		t.Fatalf("Title = %q", ex.Title)
+	}
+	if len(ex.Images) != 2 {
+		t.Fatalf("images = %+v", ex.Images)
+	}
+	cat, logo := ex.Images[0], ex.Images[1]
+	if cat.Caption != "Tom, asleep on the sofa" || cat.PageTitle != "Cats of the week" {
+		t.Fatalf("cat = %+v", cat)
+	}
+	if logo.Caption != "" || logo.PageTitle != "Cats of the week" {
+		t.Fatalf("logo = %+v", logo)
+	}
+}
@@ -0,0 +1,146 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Sitemap is what a sitemap.xml (or sitemap index) contributes to a crawl.
+type Sitemap struct {
+	Pages    []string   // <urlset><url><loc>
+	Sitemaps []string   // <sitemapindex><sitemap><loc>
+	Images   []ImageRef // <image:image> entries; PageURL is the enclosing <url><loc>
+}
+
+// MaxSitemapBytes is the uncompressed size limit from the sitemaps.org protocol.
+const MaxSitemapBytes = 50 << 20
+
+type xmlURLSet struct {
+	URLs []struct {
+		Loc    string `xml:"loc"`
+		Images []struct {
+			Loc     string `xml:"loc"`
+			Caption string `xml:"caption"`
+			Title   string `xml:"title"`
+		} `xml:"image"`
+	} `xml:"url"`
+}
+
+type xmlSitemapIndex struct {
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// FromSitemap parses a sitemap, sitemap index or plain-text sitemap (one URL per line).
+// Gzipped bodies are detected by their magic bytes, regardless of the URL or content type.
+func FromSitemap(sitemapURL string, body []byte) (Sitemap, error) {
+	if len(body) >= 2 && body[0] == 0x1f && body[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return Sitemap{}, err
+		}
+		defer zr.Close()
+		body, err = io.ReadAll(io.LimitReader(zr, MaxSitemapBytes))
+		if err != nil {
+			return Sitemap{}, err
+		}
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return Sitemap{}, nil
+	}
+	if trimmed[0] != '<' {
+		return fromTextSitemap(sitemapURL, trimmed), nil
+	}
+
+	root, err := rootElement(trimmed)
+	if err != nil {
+		return Sitemap{}, err
+	}
+
+	var out Sitemap
+	switch root {
+	case "urlset":
+		var us xmlURLSet
+		if err := xml.Unmarshal(trimmed, &us); err != nil {
+			return Sitemap{}, err
+		}
+		seenImgs := map[string]struct{}{}
+		for _, u := range us.URLs {
+			loc := resolve(sitemapURL, u.Loc)
+			if loc != "" {
+				out.Pages = append(out.Pages, loc)
+			}
+			for _, im := range u.Images {
+				ru := resolve(sitemapURL, im.Loc)
+				if ru == "" {
+					continue
+				}
+				if _, ok := seenImgs[ru+"\x00"+loc]; ok {
+					continue
+				}
+				seenImgs[ru+"\x00"+loc] = struct{}{}
+				out.Images = append(out.Images, ImageRef{
+					URL:      ru,
+					Title:    strings.TrimSpace(im.Title),
+					PageURL:  firstNonEmpty(loc, sitemapURL),
+					Filename: filenameFromURL(ru),
+					Caption:  strings.TrimSpace(im.Caption),
+				})
+			}
+		}
+	case "sitemapindex":
+		var si xmlSitemapIndex
+		if err := xml.Unmarshal(trimmed, &si); err != nil {
+			return Sitemap{}, err
+		}
+		for _, s := range si.Sitemaps {
+			if loc := resolve(sitemapURL, s.Loc); loc != "" {
+				out.Sitemaps = append(out.Sitemaps, loc)
+			}
+		}
+	default:
+		return Sitemap{}, errors.New("not a sitemap: root element <" + root + ">")
+	}
+
+	out.Pages = normalizeHTTPURLs(out.Pages)
+	out.Sitemaps = normalizeHTTPURLs(out.Sitemaps)
+	return out, nil
+}
+
+func rootElement(b []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local, nil
+		}
+	}
+}
+
+func fromTextSitemap(sitemapURL string, b []byte) Sitemap {
+	var out Sitemap
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if u := resolve(sitemapURL, line); u != "" {
+			out.Pages = append(out.Pages, u)
+		}
+	}
+	out.Pages = normalizeHTTPURLs(out.Pages)
+	return out
+}
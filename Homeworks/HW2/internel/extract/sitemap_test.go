@@ -0,0 +1,76 @@
+package extract
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+const urlsetXML = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
+        xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+  <url>
+    <loc>https://example.com/gallery</loc>
+    <image:image>
+      <image:loc>https://cdn.example.com/a.jpg</image:loc>
+      <image:caption>A sunset</image:caption>
+      <image:title>Sunset</image:title>
+    </image:image>
+    <image:image>
+      <image:loc>/img/b.png</image:loc>
+    </image:image>
+  </url>
+  <url><loc>https://example.com/about#team</loc></url>
+</urlset>`
+
+func TestFromSitemap_URLSetWithImages(t *testing.T) {
+	sm, err := FromSitemap("https://example.com/sitemap.xml", []byte(urlsetXML))
+	if err != nil {
+		t.Fatalf("FromSitemap: %v", err)
+	}
+	if len(sm.Pages) != 2 || sm.Pages[0] != "https://example.com/gallery" || sm.Pages[1] != "https://example.com/about" {
+		t.Fatalf("Pages = %v", sm.Pages)
+	}
+	if len(sm.Images) != 2 {
+		t.Fatalf("Images = %+v", sm.Images)
+	}
+	a := sm.Images[0]
+	if a.URL != "https://cdn.example.com/a.jpg" || a.Caption != "A sunset" || a.Alt != "" || a.Title != "Sunset" || a.PageURL != "https://example.com/gallery" || a.Filename != "a.jpg" {
+		t.Fatalf("first image = %+v", a)
+	}
+	if sm.Images[1].URL != "https://example.com/img/b.png" {
+		t.Fatalf("relative image loc not resolved: %+v", sm.Images[1])
+	}
+}
+
+func TestFromSitemap_GzippedIndex(t *testing.T) {
+	idx := `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/s1.xml.gz</loc></sitemap>
+  <sitemap><loc>https://example.com/s2.xml</loc></sitemap>
+</sitemapindex>`
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write([]byte(idx))
+	_ = zw.Close()
+
+	sm, err := FromSitemap("https://example.com/sitemap_index.xml.gz", buf.Bytes())
+	if err != nil {
+		t.Fatalf("FromSitemap: %v", err)
+	}
+	if len(sm.Sitemaps) != 2 || sm.Sitemaps[1] != "https://example.com/s2.xml" {
+		t.Fatalf("Sitemaps = %v", sm.Sitemaps)
+	}
+	if len(sm.Pages) != 0 || len(sm.Images) != 0 {
+		t.Fatalf("unexpected pages/images in index: %+v", sm)
+	}
+}
+
+func TestFromSitemap_TextAndInvalid(t *testing.T) {
+	sm, err := FromSitemap("https://example.com/sitemap.txt", []byte("https://example.com/a\n\nhttps://example.com/b\n"))
+	if err != nil || len(sm.Pages) != 2 {
+		t.Fatalf("text sitemap: %v %v", sm.Pages, err)
+	}
+	if _, err := FromSitemap("https://example.com/x.xml", []byte("<html><body>nope</body></html>")); err == nil {
+		t.Fatalf("expected an error for a non-sitemap document")
+	}
+}
@@ -0,0 +1,98 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"sort"
+)
+
+// DominantColor is one of the main colors of an image and its share of the
+// visible pixels (0..1).
+type DominantColor struct {
+	R, G, B uint8
+	Share   float64
+}
+
+// Hex formats the color as "#rrggbb".
+func (c DominantColor) Hex() string { return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B) }
+
+const (
+	// MaxDominantColors is the number of colors processRaster keeps.
+	MaxDominantColors = 5
+	// minColorShare drops colors covering less of the image than this.
+	minColorShare = 0.03
+	// colorMergeDist is the RGB distance below which two colors count as
+	// the same.
+	colorMergeDist = 48
+	// maxColorSamples bounds the pixels DominantColors looks at.
+	maxColorSamples = 1 << 14
+)
+
+// DominantColors returns up to n main colors of img, largest share first.
+// Pixels are bucketed at 4 bits per channel and similar buckets merged
+// greedily, biggest first, so the result is deterministic. Fully
+// transparent pixels are ignored.
+func DominantColors(img image.Image, n int) []DominantColor {
+	b := img.Bounds()
+	step := 1
+	for b.Dx()*b.Dy()/(step*step) > maxColorSamples {
+		step++
+	}
+	type bucket struct{ r, g, b, n float64 }
+	var buckets [4096]bucket
+	total := 0.0
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			r, g, bl = r*0xffff/a>>8, g*0xffff/a>>8, bl*0xffff/a>>8
+			k := &buckets[(r>>4)<<8|(g>>4)<<4|bl>>4]
+			k.r, k.g, k.b, k.n = k.r+float64(r), k.g+float64(g), k.b+float64(bl), k.n+1
+			total++
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	order := make([]int, 0, 256)
+	for i, k := range buckets {
+		if k.n > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return buckets[order[i]].n > buckets[order[j]].n })
+
+	var clusters []bucket
+	for _, i := range order {
+		k := buckets[i]
+		best, bestD := -1, float64(colorMergeDist*colorMergeDist)
+		for c, cl := range clusters {
+			dr, dg, db := k.r/k.n-cl.r/cl.n, k.g/k.n-cl.g/cl.n, k.b/k.n-cl.b/cl.n
+			if d := dr*dr + dg*dg + db*db; d < bestD {
+				best, bestD = c, d
+			}
+		}
+		if best < 0 {
+			clusters = append(clusters, k)
+			continue
+		}
+		cl := &clusters[best]
+		cl.r, cl.g, cl.b, cl.n = cl.r+k.r, cl.g+k.g, cl.b+k.b, cl.n+k.n
+	}
+	sort.SliceStable(clusters, func(i, j int) bool { return clusters[i].n > clusters[j].n })
+
+	var out []DominantColor
+	for _, cl := range clusters {
+		share := cl.n / total
+		if len(out) == n || share < minColorShare {
+			break
+		}
+		out = append(out, DominantColor{
+			R: uint8(cl.r/cl.n + 0.5), G: uint8(cl.g/cl.n + 0.5), B: uint8(cl.b/cl.n + 0.5),
+			Share: share,
+		})
+	}
+	return out
+}
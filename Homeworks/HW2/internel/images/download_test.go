@@ -0,0 +1,77 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourname/go-image-crawler/internal/render"
+)
+
+func TestDownloader_RetriesTransientErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gradient(64, 48, false)); err != nil {
+		t.Fatal(err)
+	}
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		switch {
+		case r.URL.Path == "/gone.png":
+			http.NotFound(w, r)
+		case n == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(buf.Bytes())
+		}
+	}))
+	defer srv.Close()
+
+	d := NewDownloader("test", t.TempDir())
+	d.Retry = render.RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond}
+
+	p, err := d.DownloadAndThumbnail(context.Background(), srv.URL+"/a.png")
+	if err != nil || p.Attempts != 2 || p.Width != 64 {
+		t.Fatalf("flaky image: %+v %v", p, err)
+	}
+
+	hits.Store(0)
+	_, err = d.DownloadAndThumbnail(context.Background(), srv.URL+"/gone.png")
+	if render.Classify(err) != render.ClassHTTP4xx || render.Attempts(err) != 1 || hits.Load() != 1 {
+		t.Fatalf("404: err=%v attempts=%d hits=%d", err, render.Attempts(err), hits.Load())
+	}
+}
+
+func TestDownloader_Conditional(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gradient(16, 16, false)); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	d := NewDownloader("test", t.TempDir())
+	p, err := d.DownloadAndThumbnail(context.Background(), srv.URL+"/a.png")
+	if err != nil || p.NotModified || p.ETag != `"v1"` || p.Width != 16 {
+		t.Fatalf("first download: %+v %v", p, err)
+	}
+	ctx := render.WithValidators(context.Background(), render.Validators{ETag: p.ETag})
+	p, err = d.DownloadAndThumbnail(ctx, srv.URL+"/a.png")
+	if err != nil || !p.NotModified || p.ThumbPath != "" {
+		t.Fatalf("conditional download: %+v %v", p, err)
+	}
+}
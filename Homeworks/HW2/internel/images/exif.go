@@ -0,0 +1,218 @@
+package images
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// EXIF is the subset of Exif tags the index keeps.
+type EXIF struct {
+	Make  string
+	Model string
+	Lens  string
+	// Taken is DateTimeOriginal (or DateTime). Without an OffsetTimeOriginal
+	// tag the camera's local time is recorded as UTC.
+	Taken time.Time
+	// Orientation is the TIFF orientation 1..8 (1 is upright); 0 when absent.
+	Orientation int
+	// HasGPS is set when GPSLatitude and GPSLongitude are present; Lat and
+	// Lon are in decimal degrees, negative south and west.
+	HasGPS   bool
+	Lat, Lon float64
+}
+
+// Exif and TIFF tags read by parseEXIF.
+const (
+	tagBitsPerSample     = 0x0102
+	tagMake              = 0x010f
+	tagModel             = 0x0110
+	tagOrientation       = 0x0112
+	tagDateTime          = 0x0132
+	tagExifIFD           = 0x8769
+	tagICCProfile        = 0x8773
+	tagGPSIFD            = 0x8825
+	tagDateTimeOriginal  = 0x9003
+	tagOffsetTimeOrig    = 0x9011
+	tagLensMake          = 0xa433
+	tagLensModel         = 0xa434
+	tagGPSLatitudeRef    = 1
+	tagGPSLatitude       = 2
+	tagGPSLongitudeRef   = 3
+	tagGPSLongitude      = 4
+	maxTIFFEntries       = 1024
+	exifDateLayout       = "2006:01:02 15:04:05"
+	exifDateOffsetLayout = "2006:01:02 15:04:05-07:00"
+)
+
+// tiffTypeSize is the byte size of one value of each TIFF field type.
+var tiffTypeSize = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+type tiffField struct {
+	typ   uint16
+	count int
+	data  []byte
+}
+
+// tiffReader reads IFDs of a TIFF-structured block, as found in Exif
+// segments and TIFF files.
+type tiffReader struct {
+	b  []byte
+	bo binary.ByteOrder
+}
+
+func newTIFFReader(b []byte) (*tiffReader, uint32, error) {
+	if len(b) < 8 {
+		return nil, 0, errors.New("exif: short header")
+	}
+	var bo binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return nil, 0, errors.New("exif: bad byte order")
+	}
+	if bo.Uint16(b[2:]) != 42 {
+		return nil, 0, errors.New("exif: bad magic")
+	}
+	return &tiffReader{b: b, bo: bo}, bo.Uint32(b[4:]), nil
+}
+
+// ifd returns the fields of the IFD at off and the offset of the next one.
+func (t *tiffReader) ifd(off uint32) (map[uint16]tiffField, uint32, error) {
+	if off < 8 || int64(off)+2 > int64(len(t.b)) {
+		return nil, 0, errors.New("exif: IFD out of range")
+	}
+	n := int(t.bo.Uint16(t.b[off:]))
+	start := int(off) + 2
+	if n > maxTIFFEntries || start+12*n+4 > len(t.b) {
+		return nil, 0, errors.New("exif: IFD truncated")
+	}
+	fields := make(map[uint16]tiffField, n)
+	for i := range n {
+		e := t.b[start+12*i:]
+		tag, typ, count := t.bo.Uint16(e), t.bo.Uint16(e[2:]), t.bo.Uint32(e[4:])
+		size, ok := tiffTypeSize[typ]
+		if !ok || uint64(count)*uint64(size) > uint64(len(t.b)) {
+			continue
+		}
+		total := int(count) * size
+		data := e[8:12]
+		if total > 4 {
+			p := t.bo.Uint32(e[8:])
+			if int64(p)+int64(total) > int64(len(t.b)) {
+				continue
+			}
+			data = t.b[p : int(p)+total]
+		}
+		fields[tag] = tiffField{typ: typ, count: int(count), data: data[:total]}
+	}
+	return fields, t.bo.Uint32(t.b[start+12*n:]), nil
+}
+
+func (t *tiffReader) str(f tiffField) string {
+	if f.typ != 2 {
+		return ""
+	}
+	s, _, _ := strings.Cut(string(f.data), "\x00")
+	return strings.TrimSpace(s)
+}
+
+// uint returns value i of a BYTE, SHORT or LONG field.
+func (t *tiffReader) uint(f tiffField, i int) (uint32, bool) {
+	if i >= f.count {
+		return 0, false
+	}
+	switch f.typ {
+	case 1, 7:
+		return uint32(f.data[i]), true
+	case 3:
+		return uint32(t.bo.Uint16(f.data[2*i:])), true
+	case 4:
+		return t.bo.Uint32(f.data[4*i:]), true
+	}
+	return 0, false
+}
+
+// rational returns value i of a RATIONAL field.
+func (t *tiffReader) rational(f tiffField, i int) (float64, bool) {
+	if f.typ != 5 || i >= f.count {
+		return 0, false
+	}
+	num, den := t.bo.Uint32(f.data[8*i:]), t.bo.Uint32(f.data[8*i+4:])
+	if den == 0 {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// parseEXIF reads the tags of EXIF from a TIFF-structured Exif block (the
+// payload after "Exif\0\0" in JPEG, or a PNG eXIf / WebP EXIF chunk).
+func parseEXIF(b []byte) (EXIF, error) {
+	t, off, err := newTIFFReader(b)
+	if err != nil {
+		return EXIF{}, err
+	}
+	ifd0, _, err := t.ifd(off)
+	if err != nil {
+		return EXIF{}, err
+	}
+	var e EXIF
+	e.Make, e.Model = t.str(ifd0[tagMake]), t.str(ifd0[tagModel])
+	if o, ok := t.uint(ifd0[tagOrientation], 0); ok && o >= 1 && o <= 8 {
+		e.Orientation = int(o)
+	}
+	date, offset := t.str(ifd0[tagDateTime]), ""
+
+	if p, ok := t.uint(ifd0[tagExifIFD], 0); ok {
+		if sub, _, err := t.ifd(p); err == nil {
+			if d := t.str(sub[tagDateTimeOriginal]); d != "" {
+				date, offset = d, t.str(sub[tagOffsetTimeOrig])
+			}
+			e.Lens = t.str(sub[tagLensModel])
+			if mk := t.str(sub[tagLensMake]); mk != "" && e.Lens != "" && !strings.HasPrefix(e.Lens, mk) {
+				e.Lens = mk + " " + e.Lens
+			}
+		}
+	}
+	if date != "" {
+		if ts, err := time.Parse(exifDateOffsetLayout, date+offset); offset != "" && err == nil {
+			e.Taken = ts
+		} else if ts, err := time.Parse(exifDateLayout, date); err == nil {
+			e.Taken = ts
+		}
+	}
+
+	if p, ok := t.uint(ifd0[tagGPSIFD], 0); ok {
+		if gps, _, err := t.ifd(p); err == nil {
+			lat, latOK := t.degrees(gps[tagGPSLatitude])
+			lon, lonOK := t.degrees(gps[tagGPSLongitude])
+			if latOK && lonOK && lat <= 90 && lon <= 180 {
+				if strings.EqualFold(t.str(gps[tagGPSLatitudeRef]), "S") {
+					lat = -lat
+				}
+				if strings.EqualFold(t.str(gps[tagGPSLongitudeRef]), "W") {
+					lon = -lon
+				}
+				e.HasGPS, e.Lat, e.Lon = true, lat, lon
+			}
+		}
+	}
+	return e, nil
+}
+
+// degrees converts a GPS degrees/minutes/seconds triple.
+func (t *tiffReader) degrees(f tiffField) (float64, bool) {
+	if f.count < 3 {
+		return 0, false
+	}
+	d, ok1 := t.rational(f, 0)
+	m, ok2 := t.rational(f, 1)
+	s, ok3 := t.rational(f, 2)
+	v := d + m/60 + s/3600
+	return v, ok1 && ok2 && ok3 && !math.IsNaN(v)
+}
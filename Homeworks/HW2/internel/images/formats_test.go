@@ -0,0 +1,170 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/yourname/go-image-crawler/internal/render"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+type icoImage struct {
+	w, h, bpp int
+	data      []byte
+}
+
+func buildICO(images ...icoImage) []byte {
+	le := binary.LittleEndian
+	b := []byte{0, 0, 1, 0}
+	b = le.AppendUint16(b, uint16(len(images)))
+	off := 6 + 16*len(images)
+	for _, im := range images {
+		b = append(b, byte(im.w), byte(im.h), 0, 0)
+		b = le.AppendUint16(b, 1)
+		b = le.AppendUint16(b, uint16(im.bpp))
+		b = le.AppendUint32(b, uint32(len(im.data)))
+		b = le.AppendUint32(b, uint32(off))
+		off += len(im.data)
+	}
+	for _, im := range images {
+		b = append(b, im.data...)
+	}
+	return b
+}
+
+// dibHeader is a BITMAPINFOHEADER for an icon bitmap of w×h pixels.
+func dibHeader(w, h, bpp int) []byte {
+	le := binary.LittleEndian
+	b := le.AppendUint32(nil, 40)
+	b = le.AppendUint32(b, uint32(w))
+	b = le.AppendUint32(b, uint32(2*h))
+	b = le.AppendUint16(b, 1)
+	b = le.AppendUint16(b, uint16(bpp))
+	return append(b, make([]byte, 24)...)
+}
+
+func TestDecodeICO(t *testing.T) {
+	// 32-bit 4×2 DIB: left half opaque red, right half transparent.
+	dib32 := dibHeader(4, 2, 32)
+	for range 2 {
+		dib32 = append(dib32, 0, 0, 255, 255, 0, 0, 255, 255, 0, 0, 0, 0, 0, 0, 0, 0)
+	}
+	dib32 = append(dib32, make([]byte, 8)...) // AND mask, ignored
+
+	// 4-bit 2×2 DIB with a two-color palette; the AND mask hides the
+	// bottom-right pixel. Rows are stored bottom-up.
+	dib4 := dibHeader(2, 2, 4)
+	dib4[32] = 2                                    // colors used
+	dib4 = append(dib4, 255, 0, 0, 0, 0, 255, 0, 0) // blue, green
+	dib4 = append(dib4, 0x01, 0, 0, 0, 0x10, 0, 0, 0)
+	dib4 = append(dib4, 0x40, 0, 0, 0, 0, 0, 0, 0)
+
+	var pb bytes.Buffer
+	if err := png.Encode(&pb, image.NewNRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(buildICO(
+		icoImage{2, 2, 4, dib4}, icoImage{4, 2, 32, dib32}, icoImage{2, 2, 32, pb.Bytes()},
+	)))
+	if err != nil || format != "ico" {
+		t.Fatalf("decode: %v, format %q", err, format)
+	}
+	if img.Bounds() != image.Rect(0, 0, 4, 2) {
+		t.Fatalf("picked %v, want the 4×2 icon", img.Bounds())
+	}
+	if c := color.NRGBAModel.Convert(img.At(0, 1)); c != (color.NRGBA{255, 0, 0, 255}) {
+		t.Fatalf("(0,1) = %v", c)
+	}
+	if _, _, _, a := img.At(3, 0).RGBA(); a != 0 {
+		t.Fatalf("(3,0) alpha = %d", a)
+	}
+
+	img, _, err = image.Decode(bytes.NewReader(buildICO(icoImage{2, 2, 4, dib4})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [2][2]color.NRGBA{{{0, 255, 0, 255}, {0, 0, 255, 255}}, {{0, 0, 255, 255}, {0, 255, 0, 0}}}
+	for y := range 2 {
+		for x := range 2 {
+			if c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA); c.A != want[y][x].A || (c.A != 0 && c != want[y][x]) {
+				t.Errorf("4-bit (%d,%d) = %v, want %v", x, y, c, want[y][x])
+			}
+		}
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(buildICO(icoImage{0, 0, 32, pb.Bytes()})))
+	if err != nil || format != "ico" || cfg.Width != 2 {
+		t.Fatalf("config = %+v, %q, %v", cfg, format, err)
+	}
+	for _, bad := range [][]byte{{0, 0, 1, 0, 0, 0}, buildICO(icoImage{2, 2, 4, dib4[:50]})} {
+		if _, _, err := image.Decode(bytes.NewReader(bad)); err == nil {
+			t.Errorf("decoded %q", bad)
+		}
+	}
+	// Truncated files must not panic.
+	full := buildICO(icoImage{2, 2, 4, dib4}, icoImage{4, 2, 32, dib32})
+	for n := range full {
+		_, _, _ = image.Decode(bytes.NewReader(full[:n]))
+	}
+}
+
+func TestProcessRaster_BMPTIFF(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 30, 20))
+	for i := range src.Pix {
+		src.Pix[i] = 0xc0
+	}
+	var bb, tb bytes.Buffer
+	if err := bmp.Encode(&bb, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := tiff.Encode(&tb, src, &tiff.Options{Compression: tiff.Deflate}); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDownloader("test", t.TempDir())
+	for _, c := range []struct {
+		name, ct string
+		b        []byte
+	}{
+		{"a.bmp", "image/bmp", bb.Bytes()},
+		{"a.tiff", "image/tiff", tb.Bytes()},
+	} {
+		p, err := d.processBytes("https://x/"+c.name, c.b, c.ct)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if p.Format != c.ct[len("image/"):] || p.Width != 30 || p.Height != 20 || p.BitDepth != 8 || len(p.Thumbs) == 0 {
+			t.Fatalf("%s: format %q %dx%d depth %d", c.name, p.Format, p.Width, p.Height, p.BitDepth)
+		}
+	}
+}
+
+func TestProcessRaster_Unsupported(t *testing.T) {
+	// A 4-bit BMP: valid, but x/image/bmp only reads 8, 24 and 32 bits.
+	bmp4 := []byte("BM\x46\x00\x00\x00\x00\x00\x00\x00\x3e\x00\x00\x00")
+	bmp4 = append(bmp4, dibHeader(2, 1, 4)...)
+	bmp4 = append(bmp4, make([]byte, 16)...)
+
+	d := NewDownloader("test", t.TempDir())
+	for _, c := range []struct {
+		name, body, want string
+		class            render.ErrorClass
+	}{
+		{"a.avif", "\x00\x00\x00\x1cftypavif\x00\x00\x00\x00avifmif1miaf", "avif", render.ClassUnsupported},
+		{"a.heic", "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic", "heic", render.ClassUnsupported},
+		{"a.jxl", "\xff\x0a\xfa\x1f", "jxl", render.ClassUnsupported},
+		{"a.bmp", string(bmp4), "bmp", render.ClassUnsupported},
+		{"a.avif", "<html>denied</html>", "avif", render.ClassDecode},
+		{"b.ico", "\x00\x00\x01\x00\x00\x00", "ico", render.ClassDecode},
+	} {
+		p, err := d.processBytes("https://x/"+c.name, []byte(c.body), "")
+		if render.Classify(err) != c.class || p.Format != c.want {
+			t.Errorf("%s %q: class %q format %q (%v)", c.name, c.body[:min(len(c.body), 12)], render.Classify(err), p.Format, err)
+		}
+	}
+}
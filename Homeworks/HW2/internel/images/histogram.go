@@ -0,0 +1,35 @@
+package images
+
+import "image"
+
+// HistBins is the number of bins in a ColorHistogram: 4 levels per RGB channel.
+const HistBins = 64
+
+// ColorHistogram returns the color distribution of img as HistBins bytes,
+// each bin holding its share of the visible pixels scaled to 0..255. Fully
+// transparent pixels are ignored; nil means the image has no visible pixels.
+func ColorHistogram(img image.Image) []byte {
+	var counts [HistBins]int
+	total := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			// Un-premultiply so semi-transparent pixels keep their hue.
+			r, g, bl = r*0xffff/a, g*0xffff/a, bl*0xffff/a
+			counts[(r>>14)<<4|(g>>14)<<2|bl>>14]++
+			total++
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	hist := make([]byte, HistBins)
+	for i, c := range counts {
+		hist[i] = byte((c*255 + total/2) / total)
+	}
+	return hist
+}
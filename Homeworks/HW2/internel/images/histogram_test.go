@@ -0,0 +1,29 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestColorHistogram(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		img.Set(x, 0, color.NRGBA{255, 0, 0, 255}) // red
+		img.Set(x, 1, color.NRGBA{0, 0, 255, 0})   // invisible
+	}
+	img.Set(0, 0, color.NRGBA{0, 0, 255, 128}) // half-transparent blue
+
+	h := ColorHistogram(img)
+	if len(h) != HistBins {
+		t.Fatalf("len = %d", len(h))
+	}
+	red, blue := 3<<4, 3
+	if h[red] != 191 || h[blue] != 64 {
+		t.Fatalf("red=%d blue=%d, want 191 and 64", h[red], h[blue])
+	}
+
+	if ColorHistogram(image.NewNRGBA(image.Rect(0, 0, 2, 2))) != nil {
+		t.Fatalf("fully transparent image should have no histogram")
+	}
+}
@@ -0,0 +1,198 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// maxIconSide bounds the width and height a DIB icon entry may claim.
+const maxIconSide = 1024
+
+var (
+	errICO           = errors.New("ico: invalid format")
+	errICOCompressed = errors.New("ico: compressed bitmaps are not supported")
+)
+
+func init() {
+	image.RegisterFormat("ico", "\x00\x00\x01\x00", decodeICO, decodeICOConfig)
+	// Cursors are icons with a hotspot where icons keep planes and bpp.
+	image.RegisterFormat("cur", "\x00\x00\x02\x00", decodeICO, decodeICOConfig)
+}
+
+// icoEntry is one ICONDIRENTRY.
+type icoEntry struct {
+	w, h, bpp int
+	data      []byte
+}
+
+// largestIcon parses an ICO (or CUR) file and returns its biggest image,
+// preferring more bits per pixel between icon entries of the same size.
+func largestIcon(r io.Reader) (icoEntry, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return icoEntry{}, err
+	}
+	if len(b) < 6 {
+		return icoEntry{}, errICO
+	}
+	le := binary.LittleEndian
+	n := int(le.Uint16(b[4:]))
+	if n == 0 || 6+16*n > len(b) {
+		return icoEntry{}, errICO
+	}
+	var best icoEntry
+	for i := range n {
+		e := b[6+16*i:]
+		w, h := int(e[0]), int(e[1])
+		if w == 0 {
+			w = 256
+		}
+		if h == 0 {
+			h = 256
+		}
+		size, off := int64(le.Uint32(e[8:])), int64(le.Uint32(e[12:]))
+		if off+size > int64(len(b)) || size < 8 {
+			continue
+		}
+		ent := icoEntry{w: w, h: h, bpp: int(le.Uint16(e[6:])), data: b[off : off+size]}
+		if best.data == nil || ent.w*ent.h > best.w*best.h || (ent.w*ent.h == best.w*best.h && ent.bpp > best.bpp) {
+			best = ent
+		}
+	}
+	if best.data == nil {
+		return icoEntry{}, errICO
+	}
+	return best, nil
+}
+
+func isPNG(b []byte) bool { return bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")) }
+
+func decodeICO(r io.Reader) (image.Image, error) {
+	ent, err := largestIcon(r)
+	if err != nil {
+		return nil, err
+	}
+	if isPNG(ent.data) {
+		return png.Decode(bytes.NewReader(ent.data))
+	}
+	return decodeIconDIB(ent.data)
+}
+
+func decodeICOConfig(r io.Reader) (image.Config, error) {
+	ent, err := largestIcon(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	if isPNG(ent.data) {
+		return png.DecodeConfig(bytes.NewReader(ent.data))
+	}
+	w, h, _, err := iconDIBHeader(ent.data)
+	return image.Config{ColorModel: color.NRGBAModel, Width: w, Height: h}, err
+}
+
+// iconDIBHeader reads the BITMAPINFOHEADER of an icon bitmap. Its height
+// counts the color bitmap and the AND mask, so the icon is half as tall.
+func iconDIBHeader(b []byte) (w, h, bpp int, err error) {
+	le := binary.LittleEndian
+	if len(b) < 40 || le.Uint32(b) < 40 {
+		return 0, 0, 0, errICO
+	}
+	w, h = int(int32(le.Uint32(b[4:]))), int(int32(le.Uint32(b[8:])))/2
+	bpp = int(le.Uint16(b[14:]))
+	if w <= 0 || h <= 0 || w > maxIconSide || h > maxIconSide {
+		return 0, 0, 0, errICO
+	}
+	if c := le.Uint32(b[16:]); c != 0 && !(c == 3 && bpp == 32) { // BI_RGB, or BI_BITFIELDS at 32 bpp
+		return 0, 0, 0, errICOCompressed
+	}
+	switch bpp {
+	case 1, 4, 8, 24, 32:
+		return w, h, bpp, nil
+	}
+	return 0, 0, 0, errICO
+}
+
+// decodeIconDIB decodes a bottom-up icon bitmap and applies its AND mask.
+// 32-bit bitmaps carry their own alpha; the mask only counts when every
+// alpha byte is zero, as in icons made before Windows XP.
+func decodeIconDIB(b []byte) (image.Image, error) {
+	w, h, bpp, err := iconDIBHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	le := binary.LittleEndian
+	off := int(le.Uint32(b))
+	if le.Uint32(b[16:]) == 3 {
+		off += 12 // the three BI_BITFIELDS masks, assumed BGRA
+	}
+	var pal []color.NRGBA
+	if bpp <= 8 {
+		n := int(le.Uint32(b[32:]))
+		if n == 0 || n > 1<<bpp {
+			n = 1 << bpp
+		}
+		if off+4*n > len(b) {
+			return nil, errICO
+		}
+		for i := range n {
+			p := b[off+4*i:]
+			pal = append(pal, color.NRGBA{p[2], p[1], p[0], 0xff})
+		}
+		off += 4 * n
+	}
+	stride := (w*bpp + 31) / 32 * 4
+	if off+stride*h > len(b) {
+		return nil, errICO
+	}
+	pix, rest := b[off:off+stride*h], b[off+stride*h:]
+	maskStride := (w + 31) / 32 * 4
+	if len(rest) < maskStride*h {
+		rest = nil // no usable mask: opaque
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	anyAlpha := false
+	for y := range h {
+		row := pix[(h-1-y)*stride:]
+		for x := range w {
+			var c color.NRGBA
+			switch bpp {
+			case 24:
+				c = color.NRGBA{row[3*x+2], row[3*x+1], row[3*x], 0xff}
+			case 32:
+				c = color.NRGBA{row[4*x+2], row[4*x+1], row[4*x], row[4*x+3]}
+				anyAlpha = anyAlpha || c.A != 0
+			default:
+				bit := x * bpp
+				i := int(row[bit/8]>>(8-bpp-bit%8)) & (1<<bpp - 1)
+				if i < len(pal) {
+					c = pal[i]
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	if bpp == 32 && anyAlpha {
+		return img, nil
+	}
+	for y := range h {
+		mask := rest
+		if mask != nil {
+			mask = rest[(h-1-y)*maskStride:]
+		}
+		for x := range w {
+			i := img.PixOffset(x, y) + 3
+			if mask != nil && mask[x/8]&(0x80>>(x%8)) != 0 {
+				img.Pix[i] = 0
+			} else {
+				img.Pix[i] = 0xff
+			}
+		}
+	}
+	return img, nil
+}
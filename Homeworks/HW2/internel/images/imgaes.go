@@ -5,23 +5,26 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"image"
 	_ "image/gif"
-	"image/jpeg"
 	_ "image/png"
-	"io"
+	"math"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 
+	"github.com/yourname/go-image-crawler/internal/render"
+	"golang.org/x/image/bmp"
 	"golang.org/x/image/draw"
+	"golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
 )
 
@@ -29,11 +32,46 @@ type Processed struct {
 	OriginalURL string
 	Filename    string
 	Format      string
-	Width       int
-	Height      int
-	ThumbPath   string
-	ThumbMIME   string
-	ThumbBytes  []byte
+	// Width and Height are the displayed size, after EXIF orientation.
+	Width  int
+	Height int
+	// ThumbPath, ThumbMIME and ThumbBytes are the default thumbnail, the
+	// first of Thumbs.
+	ThumbPath  string
+	ThumbMIME  string
+	ThumbBytes []byte
+	// Thumbs holds one variant per Downloader.Profiles entry. SVGs are
+	// rasterized first.
+	Thumbs []Thumb
+	// PHash is the DHash of the decoded image; HasPHash is false for flat
+	// single-color images.
+	PHash    uint64
+	HasPHash bool
+	// ColorHist is the ColorHistogram of the default thumbnail.
+	ColorHist []byte
+	// Attempts is the number of download tries (0 for data: URLs).
+	Attempts int
+	// ETag and LastModified are the response's cache validators.
+	ETag         string
+	LastModified string
+	// NotModified is set when a conditional download (render.WithValidators)
+	// got 304; nothing else but OriginalURL and Attempts is filled in then.
+	NotModified bool
+	// Bytes is the size of the downloaded (or data: URL) image.
+	Bytes int64
+	// SVGPath is the sanitized copy of an SVG source (Downloader.KeepSVG).
+	SVGPath string
+
+	// EXIF holds the camera tags of JPEG, PNG and WebP files.
+	EXIF EXIF
+	// ICCProfile is the description of the embedded color profile.
+	ICCProfile string
+	// BitDepth is bits per sample (8 for most images, 16 for deep PNGs).
+	BitDepth int
+	// Frames is the number of animation frames (1 for still images).
+	Frames int
+	// Colors are the DominantColors of the default thumbnail.
+	Colors []DominantColor
 }
 
 type Downloader struct {
@@ -41,6 +79,13 @@ type Downloader struct {
 	UserAgent string
 	ThumbDir  string
 	MaxBytes  int64
+	// Retry governs repeated download attempts; decoding is never retried.
+	Retry render.RetryPolicy
+	// Profiles are the thumbnail variants made from each decoded image.
+	Profiles []ThumbProfile
+	// KeepSVG also writes a sanitized copy of SVG sources (no scripts, event
+	// handlers or external references) as <sha256>.svg in ThumbDir.
+	KeepSVG bool
 }
 
 func NewDownloader(userAgent, thumbDir string) *Downloader {
@@ -51,11 +96,13 @@ func NewDownloader(userAgent, thumbDir string) *Downloader {
 		UserAgent: userAgent,
 		ThumbDir:  thumbDir,
 		MaxBytes:  30 << 20, // 30MB
+		Retry:     render.DefaultRetryPolicy,
+		Profiles:  DefaultProfiles,
 	}
 }
 
 // DownloadAndThumbnail downloads the image (including SVG), detects resolution when possible,
-// generates a thumbnail with max width 200px, writes it to filesystem, and returns metadata.
+// generates a thumbnail per profile, writes them to filesystem, and returns metadata.
 func (d *Downloader) DownloadAndThumbnail(ctx context.Context, imgURL string) (Processed, error) {
 	if d == nil || d.Client == nil {
 		return Processed{}, errors.New("nil downloader")
@@ -68,35 +115,70 @@ func (d *Downloader) DownloadAndThumbnail(ctx context.Context, imgURL string) (P
 		return d.fromDataURL(imgURL)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imgURL, nil)
+	var dl download
+	attempts, err := d.Retry.Do(ctx, imgURL, func(ctx context.Context) error {
+		var err error
+		dl, err = d.download(ctx, imgURL)
+		return err
+	})
 	if err != nil {
 		return Processed{}, err
 	}
+	if dl.notModified {
+		return Processed{OriginalURL: imgURL, Attempts: attempts, NotModified: true}, nil
+	}
+	p, err := d.processBytes(imgURL, dl.body, dl.contentType)
+	p.Bytes = int64(len(dl.body))
+	p.Attempts = attempts
+	p.ETag, p.LastModified = dl.validators.ETag, dl.validators.LastModified
+	return p, err
+}
+
+type download struct {
+	body        []byte
+	contentType string
+	validators  render.Validators
+	notModified bool
+}
+
+// download makes one GET for imgURL, conditional when ctx carries validators.
+func (d *Downloader) download(ctx context.Context, imgURL string) (download, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imgURL, nil)
+	if err != nil {
+		return download{}, err
+	}
 	if d.UserAgent != "" {
 		req.Header.Set("User-Agent", d.UserAgent)
 	}
 	req.Header.Set("Accept", "image/*,*/*;q=0.8")
+	conditional := render.SetConditional(req)
 
 	resp, err := d.Client.Do(req)
 	if err != nil {
-		return Processed{}, err
+		return download{}, &render.Error{Class: render.Classify(err), Err: err}
 	}
 	defer resp.Body.Close()
+	dl := download{contentType: resp.Header.Get("Content-Type"), validators: render.ResponseValidators(resp.Header)}
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		dl.notModified = true
+		return dl, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return download{}, render.ResponseError(resp)
+	}
 
-	body, err := io.ReadAll(io.LimitReader(resp.Body, d.MaxBytes))
+	dl.body, err = render.ReadLimited(resp.Body, d.MaxBytes)
 	if err != nil {
-		return Processed{}, err
+		return download{}, err
 	}
-
-	ct := resp.Header.Get("Content-Type")
-	return d.processBytes(imgURL, body, ct)
+	return dl, nil
 }
 
 func (d *Downloader) fromDataURL(dataURL string) (Processed, error) {
 	// data:[<mediatype>][;base64],<data>
 	comma := strings.IndexByte(dataURL, ',')
 	if comma < 0 {
-		return Processed{}, fmt.Errorf("invalid data url")
+		return Processed{}, render.DecodeError(errors.New("invalid data url"))
 	}
 	meta := dataURL[:comma]
 	raw := dataURL[comma+1:]
@@ -125,7 +207,7 @@ func (d *Downloader) fromDataURL(dataURL string) (Processed, error) {
 		}
 	}
 	if err != nil {
-		return Processed{}, err
+		return Processed{}, render.DecodeError(err)
 	}
 
 	return d.processBytes(dataURL, b, mimeType)
@@ -144,6 +226,12 @@ func (d *Downloader) processBytes(srcURL string, b []byte, contentType string) (
 func (d *Downloader) processRaster(srcURL string, b []byte, contentType string) (Processed, error) {
 	img, format, err := image.Decode(bytes.NewReader(b))
 	if err != nil {
+		// Format is what the image claimed to be, for failure reports.
+		failed := Processed{OriginalURL: srcURL, Format: declaredFormat(contentType, srcURL)}
+		if f := unsupportedFormat(b, err); f != "" {
+			failed.Format = f
+			return failed, render.UnsupportedError(f, nil)
+		}
 		ct := strings.TrimSpace(contentType)
 		if ct == "" {
 			ct = "(unknown content-type)"
@@ -151,117 +239,205 @@ func (d *Downloader) processRaster(srcURL string, b []byte, contentType string)
 		// Helpful when a server returns HTML (e.g. 403 page) for an image URL.
 		sn := strings.TrimSpace(string(b[:min(len(b), 96)]))
 		if len(sn) > 0 {
-			return Processed{}, fmt.Errorf("image decode failed: %w (content-type=%s, sniff=%q)", err, ct, sn)
+			return failed, render.DecodeError(fmt.Errorf("image decode failed: %w (content-type=%s, sniff=%q)", err, ct, sn))
 		}
-		return Processed{}, fmt.Errorf("image decode failed: %w (content-type=%s)", err, ct)
+		return failed, render.DecodeError(fmt.Errorf("image decode failed: %w (content-type=%s)", err, ct))
+	}
+	format = strings.ToLower(format)
+	md := readMetadata(b, format)
+	var ex EXIF
+	if md.exif != nil {
+		ex, _ = parseEXIF(md.exif)
+	}
+	// Thumbnails, hashes and Width/Height follow the displayed orientation.
+	// Animated images are thumbnailed from their first frame; Frames tells
+	// them apart.
+	p, err := d.thumbnails(srcURL, applyOrientation(img, ex.Orientation))
+	if err != nil {
+		return Processed{}, err
+	}
+	p.Format = format
+	p.EXIF = ex
+	p.ICCProfile = md.iccName
+	if md.icc != nil {
+		p.ICCProfile = iccDescription(md.icc)
 	}
+	p.BitDepth = md.bitDepth
+	if p.BitDepth == 0 {
+		p.BitDepth = bitDepthOf(img)
+	}
+	p.Frames = max(md.frames, 1)
+	return p, nil
+}
+
+// thumbnails writes one thumbnail per profile for img and fills in
+// everything but Format derived from the decoded image.
+func (d *Downloader) thumbnails(srcURL string, img image.Image) (Processed, error) {
 	bounds := img.Bounds()
-	w := bounds.Dx()
-	h := bounds.Dy()
+	ph, phOK := DHash(img)
 
-	thumb := resizeMaxWidth(img, 200)
-	tb := new(bytes.Buffer)
-	if err := jpeg.Encode(tb, thumb, &jpeg.Options{Quality: 85}); err != nil {
-		return Processed{}, err
+	profiles := d.Profiles
+	if len(profiles) == 0 {
+		profiles = DefaultProfiles
 	}
-
+	alpha := hasAlpha(img)
 	hash := sha256.Sum256([]byte(srcURL))
-	name := hex.EncodeToString(hash[:]) + ".jpg"
-	path := filepath.Join(d.ThumbDir, name)
-	if err := os.WriteFile(path, tb.Bytes(), 0o644); err != nil {
-		return Processed{}, err
+	var (
+		thumbs []Thumb
+		hist   []byte
+		colors []DominantColor
+	)
+	for i, prof := range profiles {
+		ti := prof.thumbnail(img)
+		if i == 0 {
+			hist = ColorHistogram(ti)
+			colors = DominantColors(ti, MaxDominantColors)
+		}
+		data, mime, ext, err := encodeThumb(ti, alpha)
+		if err != nil {
+			return Processed{}, err
+		}
+		path := filepath.Join(d.ThumbDir, hex.EncodeToString(hash[:])+"-"+prof.Name+ext)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return Processed{}, err
+		}
+		tb := ti.Bounds()
+		thumbs = append(thumbs, Thumb{Profile: prof.Name, Width: tb.Dx(), Height: tb.Dy(), Path: path, MIME: mime, Bytes: data})
 	}
 
 	return Processed{
 		OriginalURL: srcURL,
 		Filename:    filenameFromURL(srcURL),
-		Format:      strings.ToLower(format),
-		Width:       w,
-		Height:      h,
-		ThumbPath:   path,
-		ThumbMIME:   "image/jpeg",
-		ThumbBytes:  tb.Bytes(),
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+		ThumbPath:   thumbs[0].Path,
+		ThumbMIME:   thumbs[0].MIME,
+		ThumbBytes:  thumbs[0].Bytes,
+		Thumbs:      thumbs,
+		PHash:       ph,
+		HasPHash:    phOK,
+		ColorHist:   hist,
+		Colors:      colors,
 	}, nil
 }
 
-var (
-	reSVGWidth  = regexp.MustCompile(`(?i)\bwidth\s*=\s*["']\s*([0-9]+(?:\.[0-9]+)?)`)
-	reSVGHeight = regexp.MustCompile(`(?i)\bheight\s*=\s*["']\s*([0-9]+(?:\.[0-9]+)?)`)
-	reViewBox   = regexp.MustCompile(`(?i)\bviewBox\s*=\s*["']\s*([0-9\s\.-]+)\s*["']`)
-)
-
-func (d *Downloader) processSVG(srcURL string, b []byte) (Processed, error) {
-	w, h := svgSize(b)
-
-	// Thumbnail for SVG: keep SVG bytes. Best-effort: if width missing, inject width="200" and keep viewBox.
-	tb := b
-	if w == 0 {
-		tb = injectSVGWidth(b, 200)
+// declaredFormat is the format an image claims by its Content-Type, or
+// else its file extension ("" when neither says).
+func declaredFormat(contentType, srcURL string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		if f, ok := strings.CutPrefix(mt, "image/"); ok {
+			switch f = strings.TrimPrefix(strings.TrimSuffix(f, "+xml"), "x-"); f {
+			case "icon", "vnd.microsoft.icon":
+				return "ico"
+			case "ms-bmp":
+				return "bmp"
+			}
+			return f
+		}
 	}
-
-	hash := sha256.Sum256([]byte(srcURL))
-	name := hex.EncodeToString(hash[:]) + ".svg"
-	path := filepath.Join(d.ThumbDir, name)
-	if err := os.WriteFile(path, tb, 0o644); err != nil {
-		return Processed{}, err
+	switch ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(stripQuery(srcURL))), "."); ext {
+	case "jpg", "jpeg":
+		return "jpeg"
+	case "tif", "tiff":
+		return "tiff"
+	case "png", "gif", "webp", "bmp", "ico", "cur", "avif", "heic", "jxl", "svg":
+		return ext
 	}
-
-	return Processed{
-		OriginalURL: srcURL,
-		Filename:    filenameFromURL(srcURL),
-		Format:      "svg",
-		Width:       w,
-		Height:      h,
-		ThumbPath:   path,
-		ThumbMIME:   "image/svg+xml",
-		ThumbBytes:  tb,
-	}, nil
+	return ""
 }
 
-func svgSize(b []byte) (w, h int) {
-	s := string(b)
-	if m := reSVGWidth.FindStringSubmatch(s); len(m) == 2 {
-		w = atoiSafe(m[1])
-	}
-	if m := reSVGHeight.FindStringSubmatch(s); len(m) == 2 {
-		h = atoiSafe(m[1])
-	}
-	if w == 0 || h == 0 {
-		if m := reViewBox.FindStringSubmatch(s); len(m) == 2 {
-			fields := strings.Fields(m[1])
-			if len(fields) == 4 {
-				vw := atoiSafe(fields[2])
-				vh := atoiSafe(fields[3])
-				if w == 0 {
-					w = vw
-				}
-				if h == 0 {
-					h = vh
-				}
+// unsupportedFormat names the format of an image that failed to decode
+// only because there is no decoder for it (or for its variant, such as
+// RLE-compressed BMP). It returns "" for broken or non-image bodies.
+func unsupportedFormat(b []byte, err error) string {
+	if errors.Is(err, bmp.ErrUnsupported) {
+		return "bmp"
+	}
+	if errors.Is(err, errICOCompressed) {
+		return "ico"
+	}
+	var te tiff.UnsupportedError
+	if errors.As(err, &te) {
+		return "tiff"
+	}
+	if !errors.Is(err, image.ErrFormat) {
+		return ""
+	}
+	switch {
+	case bytes.HasPrefix(b, []byte("\x00\x00\x00\x0cJXL \r\n\x87\n")), bytes.HasPrefix(b, []byte{0xff, 0x0a}):
+		return "jxl"
+	case bytes.HasPrefix(b, []byte("\x00\x00\x00\x0cjP  \r\n\x87\n")):
+		return "jp2"
+	case bytes.HasPrefix(b, []byte("8BPS")):
+		return "psd"
+	case len(b) >= 16 && string(b[4:8]) == "ftyp":
+		// ISO BMFF: the major brand, then compatible brands up to the box end.
+		end := min(int(binary.BigEndian.Uint32(b)), len(b))
+		format := ""
+		for i := 8; i+4 <= end; i += 4 {
+			if i == 12 {
+				continue // minor version
+			}
+			switch string(b[i : i+4]) {
+			case "avif", "avis":
+				return "avif"
+			case "heic", "heix", "hevc", "heim", "heis", "mif1", "msf1":
+				format = "heic"
 			}
 		}
+		return format
 	}
-	return
+	return ""
 }
 
-func injectSVGWidth(b []byte, width int) []byte {
-	s := string(b)
-	// insert width attr after "<svg"
-	i := strings.Index(strings.ToLower(s), "<svg")
-	if i < 0 {
-		return b
+// maxSVGRaster caps the longer side of the bitmap an SVG is rendered to.
+const maxSVGRaster = 2048
+
+// processSVG renders an SVG to a bitmap large enough for every profile and
+// makes the usual thumbnails from it. Width and Height are the document's
+// intrinsic size in CSS pixels.
+func (d *Downloader) processSVG(srcURL string, b []byte) (Processed, error) {
+	root, err := parseSVG(b)
+	if err != nil {
+		return Processed{OriginalURL: srcURL, Format: "svg"}, render.DecodeError(fmt.Errorf("svg parse failed: %w", err))
+	}
+	iw, ih := svgSize(root)
+
+	profiles := d.Profiles
+	if len(profiles) == 0 {
+		profiles = DefaultProfiles
+	}
+	scale := 0.0
+	for _, prof := range profiles {
+		side := iw
+		if prof.Square {
+			side = math.Min(iw, ih)
+		}
+		scale = math.Max(scale, float64(prof.Size)/side)
+	}
+	if long := math.Max(iw, ih) * scale; long > maxSVGRaster {
+		scale *= maxSVGRaster / long
 	}
-	j := strings.Index(s[i:], ">")
-	if j < 0 {
-		return b
+	rw := max(1, int(math.Round(iw*scale)))
+	rh := max(1, int(math.Round(ih*scale)))
+
+	p, err := d.thumbnails(srcURL, rasterizeSVG(root, rw, rh))
+	if err != nil {
+		return Processed{}, err
 	}
-	j = i + j
-	openTag := s[i:j]
-	if strings.Contains(strings.ToLower(openTag), "width=") {
-		return b
+	p.Format = "svg"
+	p.Frames = 1
+	p.Width = max(1, int(math.Round(iw)))
+	p.Height = max(1, int(math.Round(ih)))
+	if d.KeepSVG {
+		hash := sha256.Sum256([]byte(srcURL))
+		path := filepath.Join(d.ThumbDir, hex.EncodeToString(hash[:])+".svg")
+		if err := os.WriteFile(path, sanitizeSVG(root), 0o644); err != nil {
+			return Processed{}, err
+		}
+		p.SVGPath = path
 	}
-	openTagNew := openTag + fmt.Sprintf(` width="%d"`, width)
-	return []byte(s[:i] + openTagNew + s[j:])
+	return p, nil
 }
 
 func resizeMaxWidth(img image.Image, maxW int) image.Image {
@@ -309,21 +485,3 @@ func min(a, b int) int {
 	}
 	return b
 }
-
-func atoiSafe(s string) int {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return 0
-	}
-	// strip units (px, etc.)
-	s = strings.TrimRightFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != '-' })
-	var f float64
-	_, err := fmt.Sscanf(s, "%f", &f)
-	if err != nil {
-		return 0
-	}
-	if f < 0 {
-		f = 0
-	}
-	return int(f + 0.5)
-}
@@ -0,0 +1,304 @@
+package images
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// fileMetadata is what processRaster reads from the encoded file besides
+// the pixels.
+type fileMetadata struct {
+	exif []byte // TIFF-structured Exif block
+	icc  []byte // embedded ICC profile
+	// iccName names the color space when the file declares one without
+	// embedding a profile (PNG sRGB chunk).
+	iccName  string
+	bitDepth int // bits per sample; 0 when the header does not say
+	frames   int // 0 when the container does not say
+}
+
+// maxICCProfile bounds the size of a decompressed PNG iCCP profile.
+const maxICCProfile = 4 << 20
+
+// readMetadata extracts Exif, ICC, bit depth and frame count from an
+// encoded image of the given image.Decode format. Malformed metadata is
+// ignored: the pixels decoded fine, so the image is kept.
+func readMetadata(b []byte, format string) fileMetadata {
+	switch format {
+	case "jpeg":
+		return jpegMetadata(b)
+	case "png":
+		return pngMetadata(b)
+	case "gif":
+		return fileMetadata{bitDepth: 8, frames: gifFrames(b)}
+	case "webp":
+		return webpMetadata(b)
+	case "tiff":
+		return tiffMetadata(b)
+	}
+	return fileMetadata{}
+}
+
+func jpegMetadata(b []byte) fileMetadata {
+	md := fileMetadata{frames: 1}
+	icc := map[byte][]byte{}
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xff {
+			break
+		}
+		m := b[i+1]
+		switch {
+		case m == 0xff: // fill byte
+			i++
+			continue
+		case m == 0xd8 || m == 0x01 || (m >= 0xd0 && m <= 0xd7):
+			i += 2
+			continue
+		case m == 0xd9 || m == 0xda: // end of image, start of scan
+			i = len(b)
+			continue
+		}
+		n := int(binary.BigEndian.Uint16(b[i+2:]))
+		if n < 2 || i+2+n > len(b) {
+			break
+		}
+		seg := b[i+4 : i+2+n]
+		switch {
+		case m == 0xe1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) && md.exif == nil:
+			md.exif = seg[6:]
+		case m == 0xe2 && bytes.HasPrefix(seg, []byte("ICC_PROFILE\x00")) && len(seg) > 14:
+			icc[seg[12]] = seg[14:]
+		case m >= 0xc0 && m <= 0xcf && m != 0xc4 && m != 0xc8 && m != 0xcc && len(seg) > 0:
+			md.bitDepth = int(seg[0])
+		}
+		i += 2 + n
+	}
+	if len(icc) > 0 {
+		seqs := make([]int, 0, len(icc))
+		for s := range icc {
+			seqs = append(seqs, int(s))
+		}
+		sort.Ints(seqs)
+		for _, s := range seqs {
+			md.icc = append(md.icc, icc[byte(s)]...)
+		}
+	}
+	return md
+}
+
+func pngMetadata(b []byte) fileMetadata {
+	md := fileMetadata{frames: 1}
+	for i := 8; i+12 <= len(b); {
+		n := int(binary.BigEndian.Uint32(b[i:]))
+		typ := string(b[i+4 : i+8])
+		if n < 0 || i+12+n > len(b) {
+			break
+		}
+		data := b[i+8 : i+8+n]
+		switch typ {
+		case "IHDR":
+			if len(data) >= 9 {
+				md.bitDepth = int(data[8])
+			}
+		case "eXIf":
+			md.exif = data
+		case "iCCP":
+			// Profile name, NUL, compression method, zlib data.
+			if _, rest, ok := bytes.Cut(data, []byte{0}); ok && len(rest) > 1 {
+				if zr, err := zlib.NewReader(bytes.NewReader(rest[1:])); err == nil {
+					md.icc, _ = io.ReadAll(io.LimitReader(zr, maxICCProfile))
+				}
+			}
+		case "sRGB":
+			md.iccName = "sRGB IEC61966-2.1"
+		case "acTL":
+			if len(data) >= 4 {
+				md.frames = int(binary.BigEndian.Uint32(data))
+			}
+		case "IEND":
+			return md
+		}
+		i += 12 + n
+	}
+	return md
+}
+
+func webpMetadata(b []byte) fileMetadata {
+	md := fileMetadata{bitDepth: 8}
+	if len(b) < 12 || string(b[:4]) != "RIFF" || string(b[8:12]) != "WEBP" {
+		return md
+	}
+	for i := 12; i+8 <= len(b); {
+		n := int(binary.LittleEndian.Uint32(b[i+4:]))
+		if n < 0 || i+8+n > len(b) {
+			break
+		}
+		data := b[i+8 : i+8+n]
+		switch string(b[i : i+4]) {
+		case "EXIF":
+			md.exif = bytes.TrimPrefix(data, []byte("Exif\x00\x00"))
+		case "ICCP":
+			md.icc = data
+		case "ANMF":
+			md.frames++
+		}
+		i += 8 + n + n&1
+	}
+	md.frames = max(md.frames, 1)
+	return md
+}
+
+// tiffMetadata reads the first page of a TIFF file, whose IFD0 also holds
+// the Exif tags. Further pages are not animation frames and are ignored.
+func tiffMetadata(b []byte) fileMetadata {
+	md := fileMetadata{exif: b, frames: 1}
+	t, off, err := newTIFFReader(b)
+	if err != nil {
+		return md
+	}
+	ifd0, _, err := t.ifd(off)
+	if err != nil {
+		return md
+	}
+	if bits, ok := t.uint(ifd0[tagBitsPerSample], 0); ok {
+		md.bitDepth = int(bits)
+	}
+	if f, ok := ifd0[tagICCProfile]; ok && (f.typ == 1 || f.typ == 7) {
+		md.icc = f.data
+	}
+	return md
+}
+
+// gifFrames counts the image descriptors of a GIF without decoding them.
+func gifFrames(b []byte) int {
+	if len(b) < 13 {
+		return 0
+	}
+	i := 13
+	if b[10]&0x80 != 0 {
+		i += 3 << (b[10]&7 + 1)
+	}
+	// skipBlocks skips data sub-blocks up to and including the terminator.
+	skipBlocks := func() bool {
+		for i < len(b) {
+			n := int(b[i])
+			i += 1 + n
+			if n == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	frames := 0
+	for i < len(b) {
+		switch b[i] {
+		case 0x21: // extension
+			i += 2
+			if !skipBlocks() {
+				return frames
+			}
+		case 0x2c: // image descriptor
+			frames++
+			if i+10 > len(b) {
+				return frames
+			}
+			flags := b[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&7 + 1)
+			}
+			i++ // LZW minimum code size
+			if !skipBlocks() {
+				return frames
+			}
+		default: // 0x3b trailer or garbage
+			return frames
+		}
+	}
+	return frames
+}
+
+// bitDepthOf is the sample size of a decoded image, for formats whose
+// headers readMetadata does not parse.
+func bitDepthOf(img image.Image) int {
+	switch img.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model, color.Alpha16Model:
+		return 16
+	}
+	return 8
+}
+
+// iccDescription returns the description ("desc" tag) of an ICC profile,
+// such as "Display P3" or "sRGB IEC61966-2.1".
+func iccDescription(p []byte) string {
+	if len(p) < 132 {
+		return ""
+	}
+	be := binary.BigEndian
+	n := int(be.Uint32(p[128:]))
+	for i := 0; i < n && 132+12*i+12 <= len(p); i++ {
+		e := p[132+12*i:]
+		if string(e[:4]) != "desc" {
+			continue
+		}
+		off, size := int64(be.Uint32(e[4:])), int64(be.Uint32(e[8:]))
+		if off+size > int64(len(p)) || size < 12 {
+			return ""
+		}
+		t := p[off : off+size]
+		switch string(t[:4]) {
+		case "desc": // ICC v2: ASCII length and text
+			l := int64(be.Uint32(t[8:]))
+			if 12+l > size {
+				return ""
+			}
+			return cleanICCName(string(t[12 : 12+l]))
+		case "mluc": // ICC v4: UTF-16BE records per language
+			if size < 16 {
+				return ""
+			}
+			recs, recSize := int(be.Uint32(t[8:])), int64(be.Uint32(t[12:]))
+			if recSize < 12 {
+				return ""
+			}
+			name := ""
+			for r := 0; r < recs && 16+int64(r)*recSize+12 <= size; r++ {
+				rec := t[16+int64(r)*recSize:]
+				l, o := int64(be.Uint32(rec[4:])), int64(be.Uint32(rec[8:]))
+				if o+l > size || l%2 != 0 {
+					continue
+				}
+				u := make([]uint16, l/2)
+				for k := range u {
+					u[k] = be.Uint16(t[o+2*int64(k):])
+				}
+				s := cleanICCName(string(utf16.Decode(u)))
+				if name == "" || string(rec[:2]) == "en" {
+					name = s
+				}
+				if string(rec[:2]) == "en" {
+					break
+				}
+			}
+			return name
+		}
+		return ""
+	}
+	return ""
+}
+
+func cleanICCName(s string) string {
+	s, _, _ = strings.Cut(s, "\x00")
+	s = strings.TrimSpace(s)
+	if len(s) > 255 {
+		s = strings.ToValidUTF8(s[:255], "")
+	}
+	return s
+}
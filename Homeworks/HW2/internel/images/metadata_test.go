@@ -0,0 +1,247 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+	"time"
+)
+
+// tiffEntry is an IFD entry for buildTIFF; with sub >= 0 it is a LONG
+// pointing at IFD number sub.
+type tiffEntry struct {
+	tag, typ uint16
+	count    uint32
+	data     []byte
+	sub      int
+}
+
+func asciiEntry(tag uint16, s string) tiffEntry {
+	return tiffEntry{tag: tag, typ: 2, count: uint32(len(s) + 1), data: append([]byte(s), 0), sub: -1}
+}
+
+func shortEntry(tag, v uint16) tiffEntry {
+	return tiffEntry{tag: tag, typ: 3, count: 1, data: binary.LittleEndian.AppendUint16(nil, v), sub: -1}
+}
+
+func rationalsEntry(tag uint16, vs ...[2]uint32) tiffEntry {
+	var b []byte
+	for _, v := range vs {
+		b = binary.LittleEndian.AppendUint32(b, v[0])
+		b = binary.LittleEndian.AppendUint32(b, v[1])
+	}
+	return tiffEntry{tag: tag, typ: 5, count: uint32(len(vs)), data: b, sub: -1}
+}
+
+// buildTIFF lays out little-endian IFDs one after another; the first is IFD0.
+func buildTIFF(ifds ...[]tiffEntry) []byte {
+	le := binary.LittleEndian
+	offs := make([]uint32, len(ifds))
+	off := uint32(8)
+	for i, ifd := range ifds {
+		offs[i] = off
+		off += 2 + 12*uint32(len(ifd)) + 4
+		for _, e := range ifd {
+			if len(e.data) > 4 {
+				off += uint32(len(e.data))
+			}
+		}
+	}
+	b := []byte("II*\x00")
+	b = le.AppendUint32(b, 8)
+	for i, ifd := range ifds {
+		data := offs[i] + 2 + 12*uint32(len(ifd)) + 4
+		var extra []byte
+		b = le.AppendUint16(b, uint16(len(ifd)))
+		for _, e := range ifd {
+			b = le.AppendUint16(b, e.tag)
+			if e.sub >= 0 {
+				b = le.AppendUint16(b, 4)
+				b = le.AppendUint32(b, 1)
+				b = le.AppendUint32(b, offs[e.sub])
+				continue
+			}
+			b = le.AppendUint16(b, e.typ)
+			b = le.AppendUint32(b, e.count)
+			if len(e.data) > 4 {
+				b = le.AppendUint32(b, data+uint32(len(extra)))
+				extra = append(extra, e.data...)
+			} else {
+				b = append(b, e.data...)
+				b = append(b, make([]byte, 4-len(e.data))...)
+			}
+		}
+		b = le.AppendUint32(b, 0)
+		b = append(b, extra...)
+	}
+	return b
+}
+
+func testEXIF() []byte {
+	return buildTIFF(
+		[]tiffEntry{
+			asciiEntry(tagMake, "Canon"),
+			asciiEntry(tagModel, "Canon EOS R5"),
+			shortEntry(tagOrientation, 6),
+			{tag: tagExifIFD, sub: 1},
+			{tag: tagGPSIFD, sub: 2},
+		},
+		[]tiffEntry{
+			asciiEntry(tagDateTimeOriginal, "2024:05:06 07:08:09"),
+			asciiEntry(tagOffsetTimeOrig, "+02:00"),
+			asciiEntry(tagLensModel, "RF24-105mm F4 L IS USM"),
+		},
+		[]tiffEntry{
+			asciiEntry(tagGPSLatitudeRef, "N"),
+			rationalsEntry(tagGPSLatitude, [2]uint32{52, 1}, [2]uint32{30, 1}, [2]uint32{0, 1}),
+			asciiEntry(tagGPSLongitudeRef, "W"),
+			rationalsEntry(tagGPSLongitude, [2]uint32{13, 1}, [2]uint32{15, 1}, [2]uint32{360, 10}),
+		},
+	)
+}
+
+// testICC is a minimal ICC v2 profile with only a "desc" tag.
+func testICC(name string) []byte {
+	be := binary.BigEndian
+	p := make([]byte, 128)
+	p = be.AppendUint32(p, 1)
+	p = append(p, "desc"...)
+	p = be.AppendUint32(p, 144)
+	tag := append([]byte("desc\x00\x00\x00\x00"), be.AppendUint32(nil, uint32(len(name)+1))...)
+	tag = append(append(tag, name...), 0)
+	p = be.AppendUint32(p, uint32(len(tag)))
+	return append(p, tag...)
+}
+
+func TestParseEXIF(t *testing.T) {
+	e, err := parseEXIF(testEXIF())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Make != "Canon" || e.Model != "Canon EOS R5" || e.Lens != "RF24-105mm F4 L IS USM" || e.Orientation != 6 {
+		t.Fatalf("tags = %+v", e)
+	}
+	if want := time.Date(2024, 5, 6, 5, 8, 9, 0, time.UTC); !e.Taken.Equal(want) {
+		t.Fatalf("taken = %v, want %v", e.Taken, want)
+	}
+	if !e.HasGPS || math.Abs(e.Lat-52.5) > 1e-9 || math.Abs(e.Lon+13.26) > 1e-9 {
+		t.Fatalf("gps = %v %v %v", e.HasGPS, e.Lat, e.Lon)
+	}
+
+	for _, bad := range [][]byte{nil, []byte("II*\x00\xff\xff\xff\xff"), []byte("XX*\x00\x08\x00\x00\x00")} {
+		if _, err := parseEXIF(bad); err == nil {
+			t.Errorf("parseEXIF(%q) accepted", bad)
+		}
+	}
+	// Truncated blocks must not panic.
+	full := testEXIF()
+	for n := range full {
+		_, _ = parseEXIF(full[:n])
+	}
+}
+
+func TestProcessRaster_Metadata(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := range 20 {
+		for x := range 40 {
+			c := color.RGBA{230, 20, 20, 255}
+			if x >= 30 {
+				c = color.RGBA{20, 20, 230, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	var jb bytes.Buffer
+	if err := jpeg.Encode(&jb, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	// Insert APP1 Exif and APP2 ICC segments after SOI.
+	segment := func(marker byte, payload []byte) []byte {
+		return append([]byte{0xff, marker, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}, payload...)
+	}
+	icc := testICC("Display P3")
+	withMeta := append([]byte{0xff, 0xd8}, segment(0xe1, append([]byte("Exif\x00\x00"), testEXIF()...))...)
+	withMeta = append(withMeta, segment(0xe2, append([]byte("ICC_PROFILE\x00\x01\x02"), icc[:100]...))...)
+	withMeta = append(withMeta, segment(0xe2, append([]byte("ICC_PROFILE\x00\x02\x02"), icc[100:]...))...)
+	withMeta = append(withMeta, jb.Bytes()[2:]...)
+
+	var pb bytes.Buffer
+	deep := image.NewNRGBA64(image.Rect(0, 0, 8, 8))
+	deep.Set(1, 1, color.NRGBA64{0xffff, 0, 0, 0xffff})
+	if err := png.Encode(&pb, deep); err != nil {
+		t.Fatal(err)
+	}
+
+	var gb bytes.Buffer
+	anim := &gif.GIF{}
+	for i := range 3 {
+		fr := image.NewPaletted(image.Rect(0, 0, 4, 4), palette.Plan9)
+		fr.SetColorIndex(i, i, 200)
+		anim.Image = append(anim.Image, fr)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	if err := gif.EncodeAll(&gb, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDownloader("test", t.TempDir())
+	p, err := d.processBytes("https://x/photo.jpg", withMeta, "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.EXIF.Model != "Canon EOS R5" || !p.EXIF.HasGPS || p.ICCProfile != "Display P3" || p.BitDepth != 8 || p.Frames != 1 {
+		t.Fatalf("jpeg metadata: exif %+v, icc %q, depth %d, frames %d", p.EXIF, p.ICCProfile, p.BitDepth, p.Frames)
+	}
+	// Orientation 6: the 40×20 stored pixels are shown turned clockwise.
+	if p.Width != 20 || p.Height != 40 || p.Thumbs[0].Width != 20 {
+		t.Fatalf("oriented size = %dx%d, thumb %dx%d", p.Width, p.Height, p.Thumbs[0].Width, p.Thumbs[0].Height)
+	}
+	if len(p.Colors) != 2 || p.Colors[0].Share < 0.7 || p.Colors[0].R < 200 || p.Colors[1].B < 200 {
+		t.Fatalf("colors = %+v", p.Colors)
+	}
+
+	p, err = d.processBytes("https://x/deep.png", pb.Bytes(), "image/png")
+	if err != nil || p.BitDepth != 16 || p.Frames != 1 || p.EXIF != (EXIF{}) {
+		t.Fatalf("png: %v depth %d frames %d exif %+v", err, p.BitDepth, p.Frames, p.EXIF)
+	}
+	p, err = d.processBytes("https://x/anim.gif", gb.Bytes(), "image/gif")
+	if err != nil || p.Frames != 3 {
+		t.Fatalf("gif: %v frames %d", err, p.Frames)
+	}
+}
+
+func TestDominantColors(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	for y := range 100 {
+		for x := range 100 {
+			switch {
+			case x < 60:
+				img.Set(x, y, color.NRGBA{250, 250, 250, 255})
+			case x < 90:
+				// Two close greens merge into one color.
+				img.Set(x, y, color.NRGBA{0, uint8(150 + y%2*10), 0, 255})
+			case x < 99:
+				img.Set(x, y, color.NRGBA{0, 0, 0, 0}) // ignored
+			default:
+				img.Set(x, y, color.NRGBA{255, 0, 0, 255}) // 1%, dropped
+			}
+		}
+	}
+	got := DominantColors(img, 5)
+	if len(got) != 2 || got[0].Hex() != "#fafafa" || got[1].G < 150 || got[1].G > 160 {
+		t.Fatalf("colors = %+v", got)
+	}
+	if s := got[0].Share + got[1].Share; math.Abs(s-0.99) > 0.02 || math.Abs(got[0].Share-60.0/91) > 0.02 {
+		t.Fatalf("shares = %v, %v", got[0].Share, got[1].Share)
+	}
+	if DominantColors(image.NewNRGBA(image.Rect(0, 0, 4, 4)), 5) != nil {
+		t.Fatal("transparent image has colors")
+	}
+}
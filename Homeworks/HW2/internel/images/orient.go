@@ -0,0 +1,72 @@
+package images
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// applyOrientation returns img as it is meant to be displayed given its
+// EXIF orientation: 2 mirrors it, 3 turns it 180°, 4 flips it vertically,
+// 5 transposes it, 6 turns it 90° clockwise, 7 transverses it and 8 turns
+// it 90° counter-clockwise. Other values return img unchanged.
+//
+// NRGBA images keep their type so transparent pixels keep their color;
+// everything else comes back as RGBA.
+func applyOrientation(img image.Image, o int) image.Image {
+	if o < 2 || o > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+
+	var (
+		src, dst             []byte
+		srcStride, dstStride int
+		out                  image.Image
+	)
+	if n, ok := img.(*image.NRGBA); ok {
+		d := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+		src, srcStride = n.Pix[n.PixOffset(b.Min.X, b.Min.Y):], n.Stride
+		dst, dstStride, out = d.Pix, d.Stride, d
+	} else {
+		rgba, ok := img.(*image.RGBA)
+		if !ok {
+			rgba = image.NewRGBA(image.Rect(0, 0, w, h))
+			draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+		}
+		d := image.NewRGBA(image.Rect(0, 0, dw, dh))
+		src, srcStride = rgba.Pix[rgba.PixOffset(rgba.Bounds().Min.X, rgba.Bounds().Min.Y):], rgba.Stride
+		dst, dstStride, out = d.Pix, d.Stride, d
+	}
+
+	for y := range dh {
+		row := dst[y*dstStride:]
+		for x := range dw {
+			// (sx, sy) is the stored pixel shown at (x, y).
+			var sx, sy int
+			switch o {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			copy(row[4*x:4*x+4], src[sy*srcStride+4*sx:])
+		}
+	}
+	return out
+}
@@ -0,0 +1,190 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+const orientDir = "testdata/orientation"
+
+// uprightImage is what every orientation test file must display as: 12×8
+// pixels, all different, with one transparent corner so thumbnails are
+// lossless PNGs.
+func uprightImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 12, 8))
+	for y := range 8 {
+		for x := range 12 {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(20 * x), uint8(30 * y), 200, 255})
+		}
+	}
+	img.SetNRGBA(11, 7, color.NRGBA{})
+	return img
+}
+
+// storedImage lays out upright the way a camera stores it under EXIF
+// orientation o, straight from the TIFF definition of which side of the
+// picture the first row and first column are.
+func storedImage(upright *image.NRGBA, o int) *image.NRGBA {
+	w, h := upright.Bounds().Dx(), upright.Bounds().Dy()
+	sides := map[int][2]string{
+		1: {"top", "left"}, 2: {"top", "right"}, 3: {"bottom", "right"}, 4: {"bottom", "left"},
+		5: {"left", "top"}, 6: {"right", "top"}, 7: {"right", "bottom"}, 8: {"left", "bottom"},
+	}[o]
+	sw, sh := w, h
+	if o >= 5 {
+		sw, sh = h, w
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, sw, sh))
+	for sy := range sh {
+		for sx := range sw {
+			var x, y int
+			switch sides[0] { // row sy runs along this edge
+			case "top":
+				y = sy
+			case "bottom":
+				y = h - 1 - sy
+			case "left":
+				x = sy
+			case "right":
+				x = w - 1 - sy
+			}
+			switch sides[1] { // column sx runs along this edge
+			case "left":
+				x = sx
+			case "right":
+				x = w - 1 - sx
+			case "top":
+				y = sx
+			case "bottom":
+				y = h - 1 - sx
+			}
+			img.SetNRGBA(sx, sy, upright.NRGBAAt(x, y))
+		}
+	}
+	return img
+}
+
+// pngWithEXIF encodes img with an eXIf chunk after IHDR.
+func pngWithEXIF(t *testing.T, img image.Image, exif []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	ihdrEnd := 8 + 12 + int(binary.BigEndian.Uint32(b[8:]))
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(exif)))
+	chunk = append(append(chunk, "eXIf"...), exif...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	return append(append(append([]byte{}, b[:ihdrEnd]...), chunk...), b[ihdrEnd:]...)
+}
+
+func writeGolden(t *testing.T) {
+	t.Helper()
+	if err := os.MkdirAll(orientDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	up := uprightImage()
+	if err := png.Encode(&buf, up); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(orientDir, "upright.png"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for o := 1; o <= 8; o++ {
+		exif := buildTIFF([]tiffEntry{shortEntry(tagOrientation, uint16(o))})
+		b := pngWithEXIF(t, storedImage(up, o), exif)
+		if err := os.WriteFile(filepath.Join(orientDir, fmt.Sprintf("orientation-%d.png", o)), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readPNG(t *testing.T, b []byte) image.Image {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// Each testdata/orientation/orientation-N.png holds the upright picture
+// stored under EXIF orientation N; processed, all must match upright.png.
+func TestProcessRaster_EXIFOrientationGolden(t *testing.T) {
+	if *update {
+		writeGolden(t)
+	}
+	gb, err := os.ReadFile(filepath.Join(orientDir, "upright.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := readPNG(t, gb)
+	gbounds := golden.Bounds()
+
+	d := NewDownloader("test", t.TempDir())
+	d.Profiles = MustParseProfiles("200w")
+	for o := 1; o <= 8; o++ {
+		t.Run(fmt.Sprint(o), func(t *testing.T) {
+			name := fmt.Sprintf("orientation-%d.png", o)
+			b, err := os.ReadFile(filepath.Join(orientDir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := d.processBytes("https://x/"+name, b, "image/png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.EXIF.Orientation != o {
+				t.Fatalf("orientation = %d", p.EXIF.Orientation)
+			}
+			if p.Width != gbounds.Dx() || p.Height != gbounds.Dy() {
+				t.Fatalf("size = %dx%d, want %dx%d", p.Width, p.Height, gbounds.Dx(), gbounds.Dy())
+			}
+			got := readPNG(t, p.Thumbs[0].Bytes)
+			if got.Bounds() != gbounds {
+				t.Fatalf("thumb bounds = %v, want %v", got.Bounds(), gbounds)
+			}
+			for y := range gbounds.Dy() {
+				for x := range gbounds.Dx() {
+					g := color.NRGBAModel.Convert(got.At(x, y))
+					w := color.NRGBAModel.Convert(golden.At(x, y))
+					if g != w {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, g, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestApplyOrientation_RGBA(t *testing.T) {
+	// Non-NRGBA sources come back as RGBA with the same layout.
+	src := image.NewGray(image.Rect(10, 10, 13, 12))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(10 * i)
+	}
+	got := applyOrientation(src, 6)
+	if got.Bounds() != image.Rect(0, 0, 2, 3) {
+		t.Fatalf("bounds = %v", got.Bounds())
+	}
+	// Turned clockwise, the stored bottom-left pixel is shown top-left.
+	if r, _, _, _ := got.At(0, 0).RGBA(); r>>8 != 30 {
+		t.Fatalf("top-left = %d, want 30", r>>8)
+	}
+	if applyOrientation(src, 1) != image.Image(src) || applyOrientation(src, 9) != image.Image(src) {
+		t.Fatal("orientation 1 or invalid changed the image")
+	}
+}
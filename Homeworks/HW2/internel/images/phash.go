@@ -0,0 +1,48 @@
+package images
+
+import (
+	"image"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+)
+
+// DHash computes a 64-bit difference hash: the image is reduced to 9x8
+// grayscale and each bit records whether a pixel is darker than its right
+// neighbour. Resized, recompressed or slightly recolored copies of a picture
+// end up a few bits apart, so the Hamming distance works as a similarity measure.
+//
+// ok is false for (nearly) flat images: every solid color hashes to 0, so
+// such hashes say nothing about the picture.
+func DHash(img image.Image) (h uint64, ok bool) {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.BiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	lo, hi := small.Pix[0], small.Pix[0]
+	for _, v := range small.Pix {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	if hi-lo < 4 {
+		return 0, false
+	}
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if small.GrayAt(x, y).Y < small.GrayAt(x+1, y).Y {
+				h |= 1
+			}
+		}
+	}
+	return h, true
+}
+
+// HammingDistance is the number of differing bits between two hashes.
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,55 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+func gradient(w, h int, flip bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*255/w + y*64/h) % 256)
+			if flip {
+				v = 255 - v
+			}
+			// a dark blob in the upper-left quarter gives the hash some structure
+			if x < w/4 && y < h/4 {
+				v /= 3
+			}
+			img.Set(x, y, color.RGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func TestDHash_ResizedCopyIsNear(t *testing.T) {
+	orig := gradient(640, 480, false)
+	small := image.NewRGBA(image.Rect(0, 0, 160, 120))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), orig, orig.Bounds(), draw.Src, nil)
+
+	a, _ := DHash(orig)
+	b, _ := DHash(small)
+	if d := HammingDistance(a, b); d > 3 {
+		t.Fatalf("resized copy distance = %d, want <= 3", d)
+	}
+}
+
+func TestDHash_DifferentImageIsFar(t *testing.T) {
+	a, _ := DHash(gradient(320, 240, false))
+	b, _ := DHash(gradient(320, 240, true))
+	if d := HammingDistance(a, b); d < 20 {
+		t.Fatalf("inverted image distance = %d, want >= 20", d)
+	}
+}
+
+func TestDHash_FlatImageHasNoHash(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 50, 40))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0, 0, 255, 255}), image.Point{}, draw.Src)
+	if _, ok := DHash(img); ok {
+		t.Fatalf("flat image should not produce a hash")
+	}
+}
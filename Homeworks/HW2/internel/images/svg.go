@@ -0,0 +1,328 @@
+package images
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	svgNS   = "http://www.w3.org/2000/svg"
+	xlinkNS = "http://www.w3.org/1999/xlink"
+
+	// maxSVGNodes and maxSVGDepth bound the work a hostile document can cause.
+	maxSVGNodes = 20000
+	maxSVGDepth = 64
+)
+
+// svgNode is an element of a parsed SVG document. Elements and attributes
+// outside the SVG and XLink namespaces are dropped while parsing.
+type svgNode struct {
+	name     string
+	attrs    []xml.Attr // Name.Space is "" or xlinkNS
+	children []*svgNode
+	text     string // character data, kept for text-like elements only
+}
+
+// parseSVG parses an SVG document leniently (HTML entities, unknown
+// charsets, unclosed tags at EOF).
+func parseSVG(b []byte) (*svgNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+
+	var (
+		root  *svgNode
+		stack []*svgNode
+		nodes int
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if root != nil {
+				break // keep what was parsed of a truncated document
+			}
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if (t.Name.Space != "" && t.Name.Space != svgNS) || len(stack) >= maxSVGDepth || nodes >= maxSVGNodes {
+				if err := dec.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			nodes++
+			n := &svgNode{name: t.Name.Local}
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Space == "" || a.Name.Space == svgNS:
+					n.attrs = append(n.attrs, xml.Attr{Name: xml.Name{Local: a.Name.Local}, Value: a.Value})
+				case a.Name.Space == xlinkNS || a.Name.Space == "xlink":
+					n.attrs = append(n.attrs, xml.Attr{Name: xml.Name{Space: xlinkNS, Local: a.Name.Local}, Value: a.Value})
+				}
+			}
+			if root == nil {
+				if n.name != "svg" {
+					return nil, fmt.Errorf("root element is <%s>, not <svg>", n.name)
+				}
+				root = n
+			} else if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 && svgTextElements[stack[len(stack)-1].name] {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("no <svg> element")
+	}
+	return root, nil
+}
+
+// svgTextElements keep their character data.
+var svgTextElements = map[string]bool{"text": true, "tspan": true, "textPath": true, "title": true, "desc": true}
+
+// attr returns the attribute name ("" when missing). "href" also finds
+// xlink:href.
+func (n *svgNode) attr(name string) string {
+	for _, a := range n.attrs {
+		if a.Name.Local == name && (a.Name.Space == "" || name == "href") {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// svgSize returns the intrinsic size of an SVG document in CSS pixels from
+// its width, height and viewBox. A missing dimension follows the viewBox
+// aspect ratio; with neither, the CSS default of 300×150 applies.
+func svgSize(root *svgNode) (w, h float64) {
+	w, wok := svgAbsLength(root.attr("width"))
+	h, hok := svgAbsLength(root.attr("height"))
+	vb, vbok := parseViewBox(root.attr("viewBox"))
+	switch {
+	case wok && hok:
+	case wok && vbok:
+		h = w * vb[3] / vb[2]
+	case hok && vbok:
+		w = h * vb[2] / vb[3]
+	case vbok:
+		w, h = vb[2], vb[3]
+	default:
+		if !wok {
+			w = 300
+		}
+		if !hok {
+			h = 150
+		}
+	}
+	return w, h
+}
+
+// parseViewBox parses "min-x min-y width height"; ok is false unless the
+// width and height are positive.
+func parseViewBox(s string) (vb [4]float64, ok bool) {
+	f := svgNumbers(s)
+	if len(f) != 4 || f[2] <= 0 || f[3] <= 0 {
+		return vb, false
+	}
+	copy(vb[:], f)
+	return vb, true
+}
+
+// svgUnits converts absolute length units to CSS pixels (96 per inch); em
+// and ex assume a 16px font.
+var svgUnits = map[string]float64{
+	"": 1, "px": 1, "pt": 96.0 / 72, "pc": 16, "in": 96, "cm": 96 / 2.54, "mm": 96 / 25.4, "q": 96 / 101.6,
+	"em": 16, "ex": 8,
+}
+
+// svgAbsLength parses a length with an absolute unit; ok is false for
+// percentages, unknown units and values that are not positive.
+func svgAbsLength(s string) (float64, bool) {
+	v, unit, ok := splitLength(s)
+	if !ok {
+		return 0, false
+	}
+	k, known := svgUnits[unit]
+	if !known || v*k <= 0 || math.IsInf(v*k, 0) {
+		return 0, false
+	}
+	return v * k, true
+}
+
+// svgLength parses a coordinate or length; percentages are of ref.
+func svgLength(s string, ref float64) float64 {
+	v, unit, ok := splitLength(s)
+	if !ok {
+		return 0
+	}
+	if unit == "%" {
+		return v * ref / 100
+	}
+	if k, known := svgUnits[unit]; known {
+		return v * k
+	}
+	return v
+}
+
+func splitLength(s string) (v float64, unit string, ok bool) {
+	s = strings.TrimSpace(s)
+	i := numberPrefix(s)
+	if i == 0 {
+		return 0, "", false
+	}
+	v, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, "", false
+	}
+	return v, strings.ToLower(strings.TrimSpace(s[i:])), true
+}
+
+// numberPrefix returns the length of the SVG number at the start of s.
+func numberPrefix(s string) int {
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := false
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i, digits = i+1, true
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i, digits = i+1, true
+		}
+	}
+	if !digits {
+		return 0
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		k := j
+		for k < len(s) && s[k] >= '0' && s[k] <= '9' {
+			k++
+		}
+		if k > j {
+			i = k
+		}
+	}
+	return i
+}
+
+// svgNumbers parses a list of numbers separated by whitespace and/or
+// commas, as in viewBox, points and transform arguments. It stops at the
+// first malformed entry.
+func svgNumbers(s string) []float64 {
+	var out []float64
+	for {
+		s = strings.TrimLeft(s, " \t\r\n,")
+		n := numberPrefix(s)
+		if n == 0 {
+			return out
+		}
+		v, err := strconv.ParseFloat(s[:n], 64)
+		if err != nil {
+			return out
+		}
+		out = append(out, v)
+		s = s[n:]
+	}
+}
+
+// svgDropElements never reach the sanitized SVG.
+var svgDropElements = map[string]bool{
+	"script": true, "foreignObject": true, "iframe": true, "embed": true, "object": true,
+	"audio": true, "video": true, "handler": true, "listener": true, "set": true,
+	"animate": true, "animateMotion": true, "animateTransform": true, "style": true,
+}
+
+// sanitizeSVG re-serializes a parsed document without scripts, event
+// handler attributes, animations, <style> sheets and external references.
+// Only local ("#id") and raster data: references remain.
+func sanitizeSVG(root *svgNode) []byte {
+	var b bytes.Buffer
+	writeSanitized(&b, root, true)
+	return b.Bytes()
+}
+
+func writeSanitized(b *bytes.Buffer, n *svgNode, root bool) {
+	if svgDropElements[n.name] {
+		return
+	}
+	b.WriteString("<" + n.name)
+	if root {
+		b.WriteString(` xmlns="` + svgNS + `" xmlns:xlink="` + xlinkNS + `"`)
+	}
+	for _, a := range n.attrs {
+		name, v := a.Name.Local, a.Value
+		if !safeSVGAttr(name, v) {
+			continue
+		}
+		if a.Name.Space == xlinkNS {
+			name = "xlink:" + name
+		}
+		b.WriteString(" " + name + `="`)
+		_ = xml.EscapeText(b, []byte(v))
+		b.WriteString(`"`)
+	}
+	if len(n.children) == 0 && n.text == "" {
+		b.WriteString("/>")
+		return
+	}
+	b.WriteString(">")
+	_ = xml.EscapeText(b, []byte(n.text))
+	for _, c := range n.children {
+		writeSanitized(b, c, false)
+	}
+	b.WriteString("</" + n.name + ">")
+}
+
+func safeSVGAttr(name, v string) bool {
+	lname, lv := strings.ToLower(name), strings.ToLower(strings.Join(strings.Fields(v), ""))
+	switch {
+	case strings.HasPrefix(lname, "on"), lname == "xmlns":
+		return false
+	case lname == "href" || lname == "src":
+		return strings.HasPrefix(lv, "#") || strings.HasPrefix(lv, "data:image/png") ||
+			strings.HasPrefix(lv, "data:image/jpeg") || strings.HasPrefix(lv, "data:image/gif") ||
+			strings.HasPrefix(lv, "data:image/webp")
+	case strings.Contains(lv, "javascript:"), strings.Contains(lv, "expression("), strings.Contains(lv, "@import"):
+		return false
+	case strings.Contains(lv, "url("):
+		// Paint and filter references must stay in the document.
+		for rest := lv; ; {
+			i := strings.Index(rest, "url(")
+			if i < 0 {
+				return true
+			}
+			rest = strings.TrimLeft(rest[i+4:], `'"`)
+			if !strings.HasPrefix(rest, "#") {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSVGSize(t *testing.T) {
+	for _, tc := range []struct {
+		svg  string
+		w, h float64
+	}{
+		{`<svg width="120" height="80"/>`, 120, 80},
+		{`<svg width="120px" height=" 80.5px "/>`, 120, 80.5},
+		{`<svg width="1in" height="72pt"/>`, 96, 96},
+		{`<svg width="25.4mm" height="2.54cm"/>`, 96, 96},
+		{`<svg viewBox="0 0 64 32"/>`, 64, 32},
+		{`<svg viewBox="-10,-10,200,100" width="100"/>`, 100, 50},
+		{`<svg viewBox="0 0 200 100" height="10"/>`, 20, 10},
+		{`<svg width="100%" height="100%" viewBox="0 0 40 30"/>`, 40, 30},
+		{`<svg/>`, 300, 150},
+		{`<svg width="-5" height="abc"/>`, 300, 150},
+		{`<?xml version="1.0"?><!DOCTYPE svg><svg xmlns="http://www.w3.org/2000/svg" width="10" height="20"><g/></svg>`, 10, 20},
+	} {
+		root, err := parseSVG([]byte(tc.svg))
+		if err != nil {
+			t.Errorf("%s: %v", tc.svg, err)
+			continue
+		}
+		if w, h := svgSize(root); w != tc.w || h != tc.h {
+			t.Errorf("%s: size %gx%g, want %gx%g", tc.svg, w, h, tc.w, tc.h)
+		}
+	}
+	if _, err := parseSVG([]byte(`<html><svg/></html>`)); err == nil {
+		t.Error("parseSVG accepted an HTML document")
+	}
+}
+
+func TestRasterizeSVG(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	clear := color.RGBA{}
+	for _, tc := range []struct {
+		name string
+		svg  string
+		at   map[image.Point]color.RGBA
+	}{
+		{"rect", `<svg viewBox="0 0 10 10"><rect x="2" y="2" width="6" height="6" fill="red"/></svg>`,
+			map[image.Point]color.RGBA{{50, 50}: red, {5, 5}: clear, {95, 95}: clear}},
+		{"viewBox offset", `<svg viewBox="10 10 10 10"><rect x="10" y="10" width="5" height="10" fill="#00f"/></svg>`,
+			map[image.Point]color.RGBA{{25, 50}: blue, {75, 50}: clear}},
+		{"transform", `<svg viewBox="0 0 10 10"><g transform="translate(5 0)"><rect width="5" height="10" style="fill:rgb(255,0,0)"/></g></svg>`,
+			map[image.Point]color.RGBA{{25, 50}: clear, {75, 50}: red}},
+		{"circle", `<svg viewBox="0 0 10 10"><circle cx="5" cy="5" r="4" fill="blue"/></svg>`,
+			map[image.Point]color.RGBA{{50, 50}: blue, {15, 50}: blue, {5, 5}: clear, {92, 92}: clear}},
+		{"path with hole", `<svg viewBox="0 0 10 10"><path d="M0 0h10v10H0z M3 3v4h4V3z" fill="red"/></svg>`,
+			map[image.Point]color.RGBA{{10, 10}: red, {50, 50}: clear}},
+		{"stroke", `<svg viewBox="0 0 10 10"><line x1="0" y1="5" x2="10" y2="5" stroke="blue" stroke-width="2"/></svg>`,
+			map[image.Point]color.RGBA{{50, 50}: blue, {50, 20}: clear}},
+		{"use and currentColor", `<svg viewBox="0 0 10 10" color="red"><defs><rect id="r" width="5" height="5" fill="currentColor"/></defs><use href="#r" x="5" y="5"/></svg>`,
+			map[image.Point]color.RGBA{{75, 75}: red, {25, 25}: clear}},
+		{"hidden", `<svg viewBox="0 0 10 10"><rect width="10" height="10" fill="red" display="none"/></svg>`,
+			map[image.Point]color.RGBA{{50, 50}: clear}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root, err := parseSVG([]byte(tc.svg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			img := rasterizeSVG(root, 100, 100)
+			for pt, want := range tc.at {
+				if got := img.RGBAAt(pt.X, pt.Y); got != want {
+					t.Errorf("pixel %v = %v, want %v", pt, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRasterizeSVG_Hostile(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`<svg viewBox="0 0 10 10">`)
+	b.WriteString(`<path d="M-1e30 -1e30 L1e30 1e30 L-1e30 1e30 Z" fill="red"/>`)
+	b.WriteString(`<circle r="1e300" stroke="blue" stroke-width="1e300"/>`)
+	b.WriteString(`<polygon points="0,0 NaN,NaN 1e308,5"/>`)
+	b.WriteString(`<g id="a"><use href="#a"/></g>`)
+	b.WriteString(`</svg>`)
+	root, err := parseSVG([]byte(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	rasterizeSVG(root, 400, 400)
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("rasterizing took %v", d)
+	}
+}
+
+func TestSanitizeSVG(t *testing.T) {
+	root, err := parseSVG([]byte(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" onload="alert(1)" width="10">` +
+		`<script>alert(2)</script><style>@import url(//evil/x.css)</style>` +
+		`<a xlink:href="javascript:alert(3)"><rect width="5" height="5" fill="url(#g)" onclick="x()"/></a>` +
+		`<image href="https://evil/track.png"/><use xlink:href="#g"/>` +
+		`<rect style="fill:url(https://evil/x)"/><foreignObject><div xmlns="http://www.w3.org/1999/xhtml">hi</div></foreignObject>` +
+		`</svg>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(sanitizeSVG(root))
+	for _, bad := range []string{"onload", "script", "alert", "@import", "evil", "onclick", "foreignObject", "<div"} {
+		if strings.Contains(out, bad) {
+			t.Errorf("sanitized SVG still contains %q: %s", bad, out)
+		}
+	}
+	for _, keep := range []string{`width="10"`, `fill="url(#g)"`, `xlink:href="#g"`} {
+		if !strings.Contains(out, keep) {
+			t.Errorf("sanitized SVG lost %q: %s", keep, out)
+		}
+	}
+	if _, err := parseSVG([]byte(out)); err != nil {
+		t.Errorf("sanitized SVG does not parse: %v", err)
+	}
+}
+
+func TestProcessSVG(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="1in" height="0.5in" viewBox="0 0 2 1">` +
+		`<rect width="1" height="1" fill="red"/><script>alert(1)</script></svg>`
+	dir := t.TempDir()
+	d := NewDownloader("test", dir)
+	d.KeepSVG = true
+	p, err := d.processBytes("https://x/logo.svg", []byte(svg), "image/svg+xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Format != "svg" || p.Width != 96 || p.Height != 48 {
+		t.Fatalf("format %s, size %dx%d; want svg 96x48", p.Format, p.Width, p.Height)
+	}
+	var got []string
+	for _, th := range p.Thumbs {
+		got = append(got, fmt.Sprintf("%s:%dx%d:%s", th.Profile, th.Width, th.Height, th.MIME))
+		if _, _, err := image.Decode(bytes.NewReader(th.Bytes)); err != nil {
+			t.Errorf("%s: %v", th.Profile, err)
+		}
+	}
+	// The right half is transparent, so thumbnails are PNG.
+	if s := strings.Join(got, " "); s != "200w:200x100:image/png 400w:400x200:image/png sq200:200x200:image/png" {
+		t.Fatalf("thumbs = %s", s)
+	}
+	if p.ThumbMIME != "image/png" || p.ColorHist == nil {
+		t.Fatalf("default thumb %s, histogram %v", p.ThumbMIME, p.ColorHist)
+	}
+	kept, err := os.ReadFile(p.SVGPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(kept), "script") || !strings.Contains(string(kept), "<rect") {
+		t.Fatalf("kept SVG = %s", kept)
+	}
+
+	if _, err := d.processBytes("https://x/bad.svg", []byte("<svg"), "image/svg+xml"); err == nil {
+		t.Fatal("truncated SVG accepted")
+	}
+}
@@ -0,0 +1,904 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+	"golang.org/x/image/vector"
+)
+
+// rasterizeSVG renders root into a w×h bitmap. It covers what icons and
+// logos use: paths, basic shapes, groups, <use>, transforms, fill and stroke
+// colors with opacity, and viewBox with preserveAspectRatio. Gradients are
+// drawn in their average color; text, images, filters, clips, masks and
+// <style> sheets are skipped, and strokes always have round joins and caps.
+// Fills use the nonzero rule.
+func rasterizeSVG(root *svgNode, w, h int) *image.RGBA {
+	r := &svgRenderer{
+		dst:    image.NewRGBA(image.Rect(0, 0, w, h)),
+		ras:    vector.NewRasterizer(w, h),
+		ids:    make(map[string]*svgNode),
+		budget: 2_000_000,
+	}
+	r.index(root)
+
+	iw, ih := svgSize(root)
+	ctm := svgMatrix{float64(w) / iw, 0, 0, float64(h) / ih, 0, 0}
+	r.vw, r.vh = iw, ih
+	if vb, ok := parseViewBox(root.attr("viewBox")); ok {
+		ctm = viewBoxTransform(vb, float64(w), float64(h), root.attr("preserveAspectRatio"))
+		r.vw, r.vh = vb[2], vb[3]
+	}
+	st := svgStyle{
+		fill:          svgPaint{c: color.NRGBA{A: 255}},
+		stroke:        svgPaint{none: true},
+		fillOpacity:   1,
+		strokeOpacity: 1,
+		opacity:       1,
+		strokeWidth:   1,
+		color:         color.NRGBA{A: 255},
+	}
+	if st, ok := r.style(root, st); ok {
+		r.drawChildren(root, ctm, st)
+	}
+	return r.dst
+}
+
+// viewBoxTransform maps vb onto a w×h viewport.
+func viewBoxTransform(vb [4]float64, w, h float64, par string) svgMatrix {
+	sx, sy := w/vb[2], h/vb[3]
+	fields := strings.Fields(par)
+	align, slice := "xMidYMid", false
+	if len(fields) > 0 {
+		align = fields[0]
+	}
+	if len(fields) > 1 {
+		slice = fields[1] == "slice"
+	}
+	if align != "none" {
+		s := math.Min(sx, sy)
+		if slice {
+			s = math.Max(sx, sy)
+		}
+		sx, sy = s, s
+	}
+	tx, ty := -vb[0]*sx, -vb[1]*sy
+	switch {
+	case strings.Contains(align, "xMid"):
+		tx += (w - vb[2]*sx) / 2
+	case strings.Contains(align, "xMax"):
+		tx += w - vb[2]*sx
+	}
+	switch {
+	case strings.Contains(align, "YMid"):
+		ty += (h - vb[3]*sy) / 2
+	case strings.Contains(align, "YMax"):
+		ty += h - vb[3]*sy
+	}
+	return svgMatrix{sx, 0, 0, sy, tx, ty}
+}
+
+type svgPoint struct{ x, y float64 }
+
+// svgMatrix is the affine transform [a b c d e f]:
+// x' = a*x + c*y + e, y' = b*x + d*y + f.
+type svgMatrix [6]float64
+
+var svgIdentity = svgMatrix{1, 0, 0, 1, 0, 0}
+
+// mul returns the transform applying n, then m.
+func (m svgMatrix) mul(n svgMatrix) svgMatrix {
+	return svgMatrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+func (m svgMatrix) apply(p svgPoint) svgPoint {
+	return svgPoint{m[0]*p.x + m[2]*p.y + m[4], m[1]*p.x + m[3]*p.y + m[5]}
+}
+
+// scale is the mean factor by which m scales lengths.
+func (m svgMatrix) scale() float64 { return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2])) }
+
+// parseTransform parses a transform list such as
+// "translate(10,5) rotate(45 0 0) scale(2)". It stops at the first
+// malformed entry.
+func parseTransform(s string) svgMatrix {
+	m := svgIdentity
+	for {
+		open := strings.IndexByte(s, '(')
+		end := strings.IndexByte(s, ')')
+		if open < 0 || end < open {
+			return m
+		}
+		name := strings.Trim(s[:open], " \t\r\n,")
+		a := svgNumbers(s[open+1 : end])
+		s = s[end+1:]
+		var t svgMatrix
+		switch {
+		case name == "matrix" && len(a) == 6:
+			t = svgMatrix{a[0], a[1], a[2], a[3], a[4], a[5]}
+		case name == "translate" && len(a) == 1:
+			t = svgMatrix{1, 0, 0, 1, a[0], 0}
+		case name == "translate" && len(a) == 2:
+			t = svgMatrix{1, 0, 0, 1, a[0], a[1]}
+		case name == "scale" && len(a) == 1:
+			t = svgMatrix{a[0], 0, 0, a[0], 0, 0}
+		case name == "scale" && len(a) == 2:
+			t = svgMatrix{a[0], 0, 0, a[1], 0, 0}
+		case name == "rotate" && (len(a) == 1 || len(a) == 3):
+			sin, cos := math.Sincos(a[0] * math.Pi / 180)
+			t = svgMatrix{cos, sin, -sin, cos, 0, 0}
+			if len(a) == 3 {
+				t = svgMatrix{1, 0, 0, 1, a[1], a[2]}.mul(t).mul(svgMatrix{1, 0, 0, 1, -a[1], -a[2]})
+			}
+		case name == "skewX" && len(a) == 1:
+			t = svgMatrix{1, 0, math.Tan(a[0] * math.Pi / 180), 1, 0, 0}
+		case name == "skewY" && len(a) == 1:
+			t = svgMatrix{1, math.Tan(a[0] * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return m
+		}
+		m = m.mul(t)
+	}
+}
+
+type svgPaint struct {
+	none bool
+	c    color.NRGBA
+}
+
+// svgStyle holds the resolved painting properties of an element.
+type svgStyle struct {
+	fill, stroke                        svgPaint
+	fillOpacity, strokeOpacity, opacity float64
+	strokeWidth                         float64
+	color                               color.NRGBA // for currentColor
+}
+
+type svgRenderer struct {
+	dst    *image.RGBA
+	ras    *vector.Rasterizer
+	ids    map[string]*svgNode
+	vw, vh float64 // viewport size in user units, for percentages
+	// budget is the number of line segments left to draw.
+	budget int
+	uses   int // depth of nested <use> references
+}
+
+func (r *svgRenderer) index(n *svgNode) {
+	if id := n.attr("id"); id != "" {
+		if _, dup := r.ids[id]; !dup {
+			r.ids[id] = n
+		}
+	}
+	for _, c := range n.children {
+		r.index(c)
+	}
+}
+
+func (r *svgRenderer) drawChildren(n *svgNode, ctm svgMatrix, st svgStyle) {
+	for _, c := range n.children {
+		r.draw(c, ctm, st)
+		if n.name == "switch" {
+			return // only the first child renders
+		}
+	}
+}
+
+func (r *svgRenderer) draw(n *svgNode, ctm svgMatrix, parent svgStyle) {
+	if r.budget <= 0 {
+		return
+	}
+	st, visible := r.style(n, parent)
+	if !visible {
+		return
+	}
+	if t := n.attr("transform"); t != "" {
+		ctm = ctm.mul(parseTransform(t))
+	}
+	switch n.name {
+	case "g", "a", "switch":
+		r.drawChildren(n, ctm, st)
+	case "svg":
+		x, y := svgLength(n.attr("x"), r.vw), svgLength(n.attr("y"), r.vh)
+		r.drawChildren(n, ctm.mul(svgMatrix{1, 0, 0, 1, x, y}), st)
+	case "use":
+		ref := r.ids[strings.TrimPrefix(strings.TrimSpace(n.attr("href")), "#")]
+		if ref == nil || r.uses >= 8 {
+			return
+		}
+		x, y := svgLength(n.attr("x"), r.vw), svgLength(n.attr("y"), r.vh)
+		ctm = ctm.mul(svgMatrix{1, 0, 0, 1, x, y})
+		r.uses++
+		if ref.name == "symbol" {
+			r.drawChildren(ref, ctm, st)
+		} else {
+			r.draw(ref, ctm, st)
+		}
+		r.uses--
+	case "path", "rect", "circle", "ellipse", "line", "polyline", "polygon":
+		p := &svgPath{ctm: ctm, budget: &r.budget}
+		r.shape(p, n)
+		r.paint(p, ctm, st)
+	}
+}
+
+// shape adds the outline of a basic shape or path to p.
+func (r *svgRenderer) shape(p *svgPath, n *svgNode) {
+	num := func(name string, ref float64) float64 { return svgLength(n.attr(name), ref) }
+	diag := math.Sqrt((r.vw*r.vw + r.vh*r.vh) / 2)
+	switch n.name {
+	case "path":
+		p.parse(n.attr("d"))
+	case "rect":
+		x, y, w, h := num("x", r.vw), num("y", r.vh), num("width", r.vw), num("height", r.vh)
+		if w <= 0 || h <= 0 {
+			return
+		}
+		rx, ry := num("rx", r.vw), num("ry", r.vh)
+		if n.attr("rx") == "" {
+			rx = ry
+		}
+		if n.attr("ry") == "" {
+			ry = rx
+		}
+		rx, ry = math.Min(math.Max(rx, 0), w/2), math.Min(math.Max(ry, 0), h/2)
+		if rx == 0 || ry == 0 {
+			p.moveTo(svgPoint{x, y})
+			p.lineTo(svgPoint{x + w, y})
+			p.lineTo(svgPoint{x + w, y + h})
+			p.lineTo(svgPoint{x, y + h})
+			p.close()
+			return
+		}
+		p.moveTo(svgPoint{x + rx, y})
+		p.lineTo(svgPoint{x + w - rx, y})
+		p.arcTo(rx, ry, 0, false, true, svgPoint{x + w, y + ry})
+		p.lineTo(svgPoint{x + w, y + h - ry})
+		p.arcTo(rx, ry, 0, false, true, svgPoint{x + w - rx, y + h})
+		p.lineTo(svgPoint{x + rx, y + h})
+		p.arcTo(rx, ry, 0, false, true, svgPoint{x, y + h - ry})
+		p.lineTo(svgPoint{x, y + ry})
+		p.arcTo(rx, ry, 0, false, true, svgPoint{x + rx, y})
+		p.close()
+	case "circle", "ellipse":
+		cx, cy := num("cx", r.vw), num("cy", r.vh)
+		rx, ry := num("rx", r.vw), num("ry", r.vh)
+		if n.name == "circle" {
+			rx = num("r", diag)
+			ry = rx
+		}
+		if rx <= 0 || ry <= 0 {
+			return
+		}
+		p.moveTo(svgPoint{cx + rx, cy})
+		p.arcTo(rx, ry, 0, false, true, svgPoint{cx - rx, cy})
+		p.arcTo(rx, ry, 0, false, true, svgPoint{cx + rx, cy})
+		p.close()
+	case "line":
+		p.moveTo(svgPoint{num("x1", r.vw), num("y1", r.vh)})
+		p.lineTo(svgPoint{num("x2", r.vw), num("y2", r.vh)})
+	case "polyline", "polygon":
+		pts := svgNumbers(n.attr("points"))
+		for i := 0; i+1 < len(pts); i += 2 {
+			if i == 0 {
+				p.moveTo(svgPoint{pts[0], pts[1]})
+			} else {
+				p.lineTo(svgPoint{pts[i], pts[i+1]})
+			}
+		}
+		if n.name == "polygon" {
+			p.close()
+		}
+	}
+}
+
+// paint fills and strokes the outline in p.
+func (r *svgRenderer) paint(p *svgPath, ctm svgMatrix, st svgStyle) {
+	p.end()
+	if len(p.polys) == 0 {
+		return
+	}
+	if !st.fill.none {
+		r.fill(p.polys, withAlpha(st.fill.c, st.fillOpacity*st.opacity))
+	}
+	if !st.stroke.none && st.strokeWidth > 0 {
+		hw := math.Max(st.strokeWidth*ctm.scale()/2, 0.35) // keep hairlines visible
+		var outline [][]svgPoint
+		for i, poly := range p.polys {
+			if p.closed[i] {
+				poly = append(poly[:len(poly):len(poly)], poly[0])
+			}
+			outline = append(outline, strokeOutline(poly, hw)...)
+		}
+		r.fill(outline, withAlpha(st.stroke.c, st.strokeOpacity*st.opacity))
+	}
+}
+
+func withAlpha(c color.NRGBA, a float64) color.NRGBA {
+	c.A = uint8(math.Round(float64(c.A) * math.Max(0, math.Min(1, a))))
+	return c
+}
+
+// fill draws the union of polys (nonzero winding) in c.
+func (r *svgRenderer) fill(polys [][]svgPoint, c color.NRGBA) {
+	if c.A == 0 {
+		return
+	}
+	b := r.dst.Bounds()
+	r.ras.Reset(b.Dx(), b.Dy())
+	drawn := false
+	for _, poly := range polys {
+		poly = clipPolygon(poly, float64(b.Dx()), float64(b.Dy()))
+		if len(poly) < 3 {
+			continue
+		}
+		r.ras.MoveTo(float32(poly[0].x), float32(poly[0].y))
+		for _, q := range poly[1:] {
+			r.ras.LineTo(float32(q.x), float32(q.y))
+		}
+		r.ras.ClosePath()
+		drawn = true
+	}
+	if drawn {
+		r.ras.Draw(r.dst, b, image.NewUniform(c), image.Point{})
+	}
+}
+
+// strokeOutline returns polygons covering a polyline stroked with half-width
+// hw: one quad per segment and a disc at every vertex (round joins and
+// caps), all wound the same way so that they unite.
+func strokeOutline(poly []svgPoint, hw float64) [][]svgPoint {
+	var out [][]svgPoint
+	sides := int(math.Min(32, math.Max(8, hw*2)))
+	for i, p := range poly {
+		disc := make([]svgPoint, sides)
+		for k := range disc {
+			sin, cos := math.Sincos(2 * math.Pi * float64(k) / float64(sides))
+			disc[k] = svgPoint{p.x + hw*cos, p.y + hw*sin}
+		}
+		out = append(out, disc)
+		if i == 0 {
+			continue
+		}
+		q := poly[i-1]
+		dx, dy := p.x-q.x, p.y-q.y
+		l := math.Hypot(dx, dy)
+		if l == 0 {
+			continue
+		}
+		nx, ny := -dy/l*hw, dx/l*hw
+		quad := []svgPoint{{q.x + nx, q.y + ny}, {p.x + nx, p.y + ny}, {p.x - nx, p.y - ny}, {q.x - nx, q.y - ny}}
+		if signedArea(quad) < 0 {
+			quad[1], quad[3] = quad[3], quad[1]
+		}
+		out = append(out, quad)
+	}
+	return out
+}
+
+func signedArea(poly []svgPoint) float64 {
+	a := 0.0
+	for i, p := range poly {
+		q := poly[(i+1)%len(poly)]
+		a += p.x*q.y - q.x*p.y
+	}
+	return a / 2
+}
+
+// clipPolygon clips poly to a margin around the w×h canvas, so that the
+// rasterizer never walks far outside it. Non-finite points are dropped.
+func clipPolygon(poly []svgPoint, w, h float64) []svgPoint {
+	out := make([]svgPoint, 0, len(poly))
+	for _, p := range poly {
+		if !math.IsNaN(p.x) && !math.IsNaN(p.y) && !math.IsInf(p.x, 0) && !math.IsInf(p.y, 0) {
+			out = append(out, p)
+		}
+	}
+	edges := []struct {
+		inside func(svgPoint) bool
+		cross  func(a, b svgPoint) svgPoint
+	}{
+		{func(p svgPoint) bool { return p.x >= -2 }, func(a, b svgPoint) svgPoint { return lerpX(a, b, -2) }},
+		{func(p svgPoint) bool { return p.x <= w+2 }, func(a, b svgPoint) svgPoint { return lerpX(a, b, w+2) }},
+		{func(p svgPoint) bool { return p.y >= -2 }, func(a, b svgPoint) svgPoint { return lerpY(a, b, -2) }},
+		{func(p svgPoint) bool { return p.y <= h+2 }, func(a, b svgPoint) svgPoint { return lerpY(a, b, h+2) }},
+	}
+	for _, e := range edges {
+		if len(out) == 0 {
+			return nil
+		}
+		in := out
+		out = make([]svgPoint, 0, len(in)+4)
+		prev := in[len(in)-1]
+		for _, p := range in {
+			switch pin, prevIn := e.inside(p), e.inside(prev); {
+			case pin && prevIn:
+				out = append(out, p)
+			case pin:
+				out = append(out, e.cross(prev, p), p)
+			case prevIn:
+				out = append(out, e.cross(prev, p))
+			}
+			prev = p
+		}
+	}
+	return out
+}
+
+func lerpX(a, b svgPoint, x float64) svgPoint {
+	t := (x - a.x) / (b.x - a.x)
+	return svgPoint{x, a.y + t*(b.y-a.y)}
+}
+
+func lerpY(a, b svgPoint, y float64) svgPoint {
+	t := (y - a.y) / (b.y - a.y)
+	return svgPoint{a.x + t*(b.x-a.x), y}
+}
+
+// svgPath flattens an outline given in user space into device-space
+// polylines.
+type svgPath struct {
+	ctm    svgMatrix
+	budget *int
+
+	polys  [][]svgPoint
+	closed []bool
+	cur    []svgPoint // device space
+
+	pen, start svgPoint // user space
+}
+
+func (p *svgPath) moveTo(q svgPoint) {
+	p.end()
+	p.pen, p.start = q, q
+	p.cur = []svgPoint{p.ctm.apply(q)}
+}
+
+func (p *svgPath) lineTo(q svgPoint) {
+	if p.cur == nil {
+		p.moveTo(p.pen)
+	}
+	p.pen = q
+	p.add(p.ctm.apply(q))
+}
+
+func (p *svgPath) add(d svgPoint) {
+	if *p.budget <= 0 {
+		return
+	}
+	*p.budget--
+	p.cur = append(p.cur, d)
+}
+
+func (p *svgPath) cubeTo(c1, c2, q svgPoint) {
+	if p.cur == nil {
+		p.moveTo(p.pen)
+	}
+	d0, d1, d2, d3 := p.ctm.apply(p.pen), p.ctm.apply(c1), p.ctm.apply(c2), p.ctm.apply(q)
+	l := math.Hypot(d1.x-d0.x, d1.y-d0.y) + math.Hypot(d2.x-d1.x, d2.y-d1.y) + math.Hypot(d3.x-d2.x, d3.y-d2.y)
+	n := int(math.Min(64, math.Max(1, l/3)))
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		p.add(svgPoint{a*d0.x + b*d1.x + c*d2.x + d*d3.x, a*d0.y + b*d1.y + c*d2.y + d*d3.y})
+	}
+	p.pen = q
+}
+
+func (p *svgPath) quadTo(c, q svgPoint) {
+	// Degree elevation: a quadratic is the cubic with these control points.
+	c1 := svgPoint{p.pen.x + 2.0/3*(c.x-p.pen.x), p.pen.y + 2.0/3*(c.y-p.pen.y)}
+	c2 := svgPoint{q.x + 2.0/3*(c.x-q.x), q.y + 2.0/3*(c.y-q.y)}
+	p.cubeTo(c1, c2, q)
+}
+
+// arcTo draws an elliptical arc to q as in the SVG "A" command, split into
+// cubic curves of at most 90°.
+func (p *svgPath) arcTo(rx, ry, angle float64, large, sweep bool, q svgPoint) {
+	from := p.pen
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 || (from == q) {
+		p.lineTo(q)
+		return
+	}
+	sinPhi, cosPhi := math.Sincos(angle * math.Pi / 180)
+	// Endpoint to center parameterization (SVG 1.1, appendix F.6.5).
+	dx, dy := (from.x-q.x)/2, (from.y-q.y)/2
+	x1 := cosPhi*dx + sinPhi*dy
+	y1 := -sinPhi*dx + cosPhi*dy
+	if l := x1*x1/(rx*rx) + y1*y1/(ry*ry); l > 1 {
+		rx, ry = rx*math.Sqrt(l), ry*math.Sqrt(l)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	k := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		k = -k
+	}
+	cx1, cy1 := k*rx*y1/ry, -k*ry*x1/rx
+	cx := cosPhi*cx1 - sinPhi*cy1 + (from.x+q.x)/2
+	cy := sinPhi*cx1 + cosPhi*cy1 + (from.y+q.y)/2
+	theta := math.Atan2((y1-cy1)/ry, (x1-cx1)/rx)
+	delta := math.Atan2((-y1-cy1)/ry, (-x1-cx1)/rx) - theta
+	if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	} else if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	}
+
+	segs := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(segs)
+	t := 4.0 / 3 * math.Tan(step/4)
+	point := func(a float64) (svgPoint, svgPoint) { // point and derivative on the ellipse
+		sin, cos := math.Sincos(a)
+		x, y := rx*cos, ry*sin
+		dx, dy := -rx*sin, ry*cos
+		return svgPoint{cosPhi*x - sinPhi*y + cx, sinPhi*x + cosPhi*y + cy},
+			svgPoint{cosPhi*dx - sinPhi*dy, sinPhi*dx + cosPhi*dy}
+	}
+	a := theta
+	for i := 0; i < segs; i++ {
+		p0, d0 := point(a)
+		p1, d1 := point(a + step)
+		if i == segs-1 {
+			p1 = q
+		}
+		p.cubeTo(svgPoint{p0.x + t*d0.x, p0.y + t*d0.y}, svgPoint{p1.x - t*d1.x, p1.y - t*d1.y}, p1)
+		a += step
+	}
+}
+
+func (p *svgPath) close() {
+	if p.cur != nil {
+		p.polys = append(p.polys, p.cur)
+		p.closed = append(p.closed, true)
+		p.cur = nil
+	}
+	p.pen = p.start
+}
+
+// end finishes an open subpath.
+func (p *svgPath) end() {
+	if len(p.cur) > 1 {
+		p.polys = append(p.polys, p.cur)
+		p.closed = append(p.closed, false)
+	}
+	p.cur = nil
+}
+
+// parse follows path data ("M10 10 h20 a5 5 0 0 1 5 5 z"), stopping at the
+// first error as renderers do.
+func (p *svgPath) parse(d string) {
+	var (
+		i    int
+		cmd  byte
+		ctrl svgPoint // last control point, for S and T
+		prev byte     // previous command, lower-case
+	)
+	skip := func() {
+		for i < len(d) && strings.IndexByte(" \t\r\n,", d[i]) >= 0 {
+			i++
+		}
+	}
+	num := func(v *float64) bool {
+		skip()
+		n := numberPrefix(d[i:])
+		if n == 0 {
+			return false
+		}
+		f, err := strconv.ParseFloat(d[i:i+n], 64)
+		if err != nil {
+			return false
+		}
+		*v, i = f, i+n
+		return true
+	}
+	flag := func(v *bool) bool {
+		skip()
+		if i >= len(d) || (d[i] != '0' && d[i] != '1') {
+			return false
+		}
+		*v, i = d[i] == '1', i+1
+		return true
+	}
+	for *p.budget > 0 {
+		skip()
+		if i >= len(d) {
+			return
+		}
+		if c := d[i]; (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			cmd = c
+			i++
+		} else if cmd == 0 {
+			return
+		}
+		rel := cmd >= 'a'
+		lc := cmd | 0x20
+		base := svgPoint{}
+		if rel {
+			base = p.pen
+		}
+		pt := func(x, y float64) svgPoint { return svgPoint{base.x + x, base.y + y} }
+		var a [7]float64
+		switch lc {
+		case 'z':
+			p.close()
+			cmd = 0 // numbers after Z are an error
+		case 'm':
+			if !num(&a[0]) || !num(&a[1]) {
+				return
+			}
+			p.moveTo(pt(a[0], a[1]))
+			cmd = 'L' | (cmd & 0x20) // further pairs are line-tos
+		case 'l':
+			if !num(&a[0]) || !num(&a[1]) {
+				return
+			}
+			p.lineTo(pt(a[0], a[1]))
+		case 'h':
+			if !num(&a[0]) {
+				return
+			}
+			q := p.pen
+			q.x = base.x + a[0]
+			if !rel {
+				q.x = a[0]
+			}
+			p.lineTo(q)
+		case 'v':
+			if !num(&a[0]) {
+				return
+			}
+			q := p.pen
+			q.y = base.y + a[0]
+			if !rel {
+				q.y = a[0]
+			}
+			p.lineTo(q)
+		case 'c':
+			for k := range 6 {
+				if !num(&a[k]) {
+					return
+				}
+			}
+			ctrl = pt(a[2], a[3])
+			p.cubeTo(pt(a[0], a[1]), ctrl, pt(a[4], a[5]))
+		case 's':
+			for k := range 4 {
+				if !num(&a[k]) {
+					return
+				}
+			}
+			c1 := p.pen
+			if prev == 'c' || prev == 's' {
+				c1 = svgPoint{2*p.pen.x - ctrl.x, 2*p.pen.y - ctrl.y}
+			}
+			ctrl = pt(a[0], a[1])
+			p.cubeTo(c1, ctrl, pt(a[2], a[3]))
+		case 'q':
+			for k := range 4 {
+				if !num(&a[k]) {
+					return
+				}
+			}
+			ctrl = pt(a[0], a[1])
+			p.quadTo(ctrl, pt(a[2], a[3]))
+		case 't':
+			if !num(&a[0]) || !num(&a[1]) {
+				return
+			}
+			c := p.pen
+			if prev == 'q' || prev == 't' {
+				c = svgPoint{2*p.pen.x - ctrl.x, 2*p.pen.y - ctrl.y}
+			}
+			ctrl = c
+			p.quadTo(c, pt(a[0], a[1]))
+		case 'a':
+			var large, sweep bool
+			if !num(&a[0]) || !num(&a[1]) || !num(&a[2]) || !flag(&large) || !flag(&sweep) || !num(&a[3]) || !num(&a[4]) {
+				return
+			}
+			p.arcTo(a[0], a[1], a[2], large, sweep, pt(a[3], a[4]))
+		default:
+			return
+		}
+		prev = lc
+	}
+}
+
+// svgPresentation are the styling properties read from attributes and the
+// style attribute.
+var svgPresentation = []string{"display", "visibility", "color", "fill", "stroke", "fill-opacity", "stroke-opacity", "opacity", "stroke-width"}
+
+// style resolves n's painting properties; visible is false for display:none
+// and hidden elements.
+func (r *svgRenderer) style(n *svgNode, parent svgStyle) (st svgStyle, visible bool) {
+	props := make(map[string]string)
+	for _, name := range svgPresentation {
+		if v := n.attr(name); v != "" {
+			props[name] = v
+		}
+	}
+	for _, decl := range strings.Split(n.attr("style"), ";") {
+		if k, v, ok := strings.Cut(decl, ":"); ok {
+			k = strings.TrimSpace(k)
+			v = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), "!important"))
+			props[k] = v
+		}
+	}
+	for k, v := range props {
+		if strings.TrimSpace(v) == "inherit" {
+			delete(props, k)
+		}
+	}
+
+	st = parent
+	if props["display"] == "none" || props["visibility"] == "hidden" || props["visibility"] == "collapse" {
+		return st, false
+	}
+	if c, ok := parseSVGColor(props["color"]); ok {
+		st.color = c
+	}
+	if v, ok := props["fill"]; ok {
+		st.fill = r.parsePaint(v, st)
+	}
+	if v, ok := props["stroke"]; ok {
+		st.stroke = r.parsePaint(v, st)
+	}
+	opacity := func(name string, v *float64) {
+		s, ok := props[name]
+		if !ok {
+			return
+		}
+		f, unit, ok := splitLength(s)
+		if !ok {
+			return
+		}
+		if unit == "%" {
+			f /= 100
+		}
+		*v = math.Max(0, math.Min(1, f))
+	}
+	opacity("fill-opacity", &st.fillOpacity)
+	opacity("stroke-opacity", &st.strokeOpacity)
+	own := 1.0
+	opacity("opacity", &own)
+	st.opacity = parent.opacity * own
+	if v, ok := props["stroke-width"]; ok {
+		st.strokeWidth = svgLength(v, math.Sqrt((r.vw*r.vw+r.vh*r.vh)/2))
+	}
+	return st, true
+}
+
+// parsePaint resolves a fill or stroke value. Gradient and pattern
+// references paint in their average stop color, or their fallback.
+func (r *svgRenderer) parsePaint(v string, st svgStyle) svgPaint {
+	v = strings.TrimSpace(v)
+	switch strings.ToLower(v) {
+	case "none", "transparent":
+		return svgPaint{none: true}
+	case "currentcolor":
+		return svgPaint{c: st.color}
+	}
+	if rest, ok := strings.CutPrefix(v, "url("); ok {
+		ref, fallback, _ := strings.Cut(rest, ")")
+		ref = strings.TrimPrefix(strings.Trim(strings.TrimSpace(ref), `'"`), "#")
+		if c, ok := r.gradientColor(ref, 0); ok {
+			return svgPaint{c: c}
+		}
+		if fallback = strings.TrimSpace(fallback); fallback != "" {
+			return r.parsePaint(fallback, st)
+		}
+		return svgPaint{none: true}
+	}
+	if c, ok := parseSVGColor(v); ok {
+		return svgPaint{c: c}
+	}
+	return svgPaint{c: color.NRGBA{A: 255}}
+}
+
+// gradientColor averages the stops of gradient id, following href to the
+// gradient it inherits its stops from.
+func (r *svgRenderer) gradientColor(id string, depth int) (color.NRGBA, bool) {
+	g := r.ids[id]
+	if g == nil || depth > 4 || (g.name != "linearGradient" && g.name != "radialGradient") {
+		return color.NRGBA{}, false
+	}
+	var sr, sg, sb, sa, n float64
+	for _, s := range g.children {
+		if s.name != "stop" {
+			continue
+		}
+		c := color.NRGBA{A: 255}
+		op := 1.0
+		for _, decl := range append([]string{"stop-color:" + s.attr("stop-color"), "stop-opacity:" + s.attr("stop-opacity")}, strings.Split(s.attr("style"), ";")...) {
+			k, v, _ := strings.Cut(decl, ":")
+			switch strings.TrimSpace(k) {
+			case "stop-color":
+				if sc, ok := parseSVGColor(v); ok {
+					c = sc
+				}
+			case "stop-opacity":
+				if f, _, ok := splitLength(v); ok {
+					op = math.Max(0, math.Min(1, f))
+				}
+			}
+		}
+		a := float64(c.A) / 255 * op
+		sr, sg, sb, sa, n = sr+float64(c.R)*a, sg+float64(c.G)*a, sb+float64(c.B)*a, sa+a, n+1
+	}
+	if n == 0 {
+		return r.gradientColor(strings.TrimPrefix(strings.TrimSpace(g.attr("href")), "#"), depth+1)
+	}
+	if sa == 0 {
+		return color.NRGBA{}, true
+	}
+	return color.NRGBA{uint8(sr / sa), uint8(sg / sa), uint8(sb / sa), uint8(math.Round(sa / n * 255))}, true
+}
+
+// parseSVGColor parses #rgb, #rgba, #rrggbb, #rrggbbaa, rgb()/rgba() and
+// the SVG color keywords.
+func parseSVGColor(s string) (color.NRGBA, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return color.NRGBA{}, false
+	}
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return color.NRGBA{}, false
+		}
+		switch len(hex) {
+		case 3:
+			return color.NRGBA{uint8(v>>8) * 17, uint8(v>>4&0xf) * 17, uint8(v&0xf) * 17, 255}, true
+		case 4:
+			return color.NRGBA{uint8(v>>12) * 17, uint8(v>>8&0xf) * 17, uint8(v>>4&0xf) * 17, uint8(v&0xf) * 17}, true
+		case 6:
+			return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, true
+		case 8:
+			return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, true
+		}
+		return color.NRGBA{}, false
+	}
+	if args, ok := strings.CutPrefix(s, "rgb"); ok {
+		args = strings.TrimPrefix(args, "a")
+		args = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(args), "("), ")")
+		parts := strings.FieldsFunc(args, func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+		if len(parts) != 3 && len(parts) != 4 {
+			return color.NRGBA{}, false
+		}
+		var ch [4]uint8
+		ch[3] = 255
+		for i, p := range parts {
+			f, unit, ok := splitLength(p)
+			if !ok {
+				return color.NRGBA{}, false
+			}
+			scale := 1.0
+			switch {
+			case unit == "%":
+				scale = 255.0 / 100
+			case i == 3:
+				scale = 255
+			}
+			ch[i] = uint8(math.Round(math.Max(0, math.Min(255, f*scale))))
+		}
+		return color.NRGBA{ch[0], ch[1], ch[2], ch[3]}, true
+	}
+	if c, ok := colornames.Map[s]; ok {
+		return color.NRGBA{c.R, c.G, c.B, c.A}, true
+	}
+	return color.NRGBA{}, false
+}
@@ -0,0 +1,130 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// ThumbProfile is one thumbnail variant made for every raster image.
+type ThumbProfile struct {
+	// Name identifies the variant: "<N>w" for at most N pixels wide,
+	// "sq<N>" for an N×N center crop.
+	Name string
+	Size int
+	// Square crops the center square before scaling.
+	Square bool
+}
+
+// DefaultProfiles is a 200px thumbnail, a 400px one for HiDPI screens and a
+// 200px square. The first profile is the image's default thumbnail.
+var DefaultProfiles = MustParseProfiles("200w,400w,sq200")
+
+// ParseProfiles parses a comma-separated list of profile names such as
+// "200w,400w,sq150".
+func ParseProfiles(spec string) ([]ThumbProfile, error) {
+	var out []ThumbProfile
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		p := ThumbProfile{Name: name}
+		num := name
+		switch {
+		case strings.HasPrefix(name, "sq"):
+			p.Square, num = true, name[2:]
+		case strings.HasSuffix(name, "w"):
+			num = name[:len(name)-1]
+		default:
+			return nil, fmt.Errorf("thumbnail profile %q: want <N>w or sq<N>", name)
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil || n < 1 || n > 4096 {
+			return nil, fmt.Errorf("thumbnail profile %q: size must be 1..4096", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("thumbnail profile %q listed twice", name)
+		}
+		seen[name] = true
+		p.Size = n
+		out = append(out, p)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no thumbnail profiles in %q", spec)
+	}
+	return out, nil
+}
+
+// MustParseProfiles is ParseProfiles for constant specs.
+func MustParseProfiles(spec string) []ThumbProfile {
+	p, err := ParseProfiles(spec)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Thumb is one encoded thumbnail variant.
+type Thumb struct {
+	Profile string
+	Width   int
+	Height  int
+	Path    string
+	MIME    string
+	Bytes   []byte
+}
+
+// thumbnail scales img for p; images already small enough are not enlarged.
+func (p ThumbProfile) thumbnail(img image.Image) image.Image {
+	if !p.Square {
+		return resizeMaxWidth(img, p.Size)
+	}
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	if side <= 0 {
+		return img
+	}
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	src := image.Rect(x0, y0, x0+side, y0+side)
+	n := min(side, p.Size)
+	dst := image.NewRGBA(image.Rect(0, 0, n, n))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), img, src, draw.Over, nil)
+	return dst
+}
+
+// encodeThumb writes img as PNG when the source has transparent pixels, so
+// logos keep their alpha, and as JPEG otherwise.
+func encodeThumb(img image.Image, alpha bool) (data []byte, mime, ext string, err error) {
+	var buf bytes.Buffer
+	if alpha {
+		err = (&png.Encoder{CompressionLevel: png.BestSpeed}).Encode(&buf, img)
+		return buf.Bytes(), "image/png", ".png", err
+	}
+	err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	return buf.Bytes(), "image/jpeg", ".jpg", err
+}
+
+// hasAlpha reports whether any pixel of img is not fully opaque.
+func hasAlpha(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return !o.Opaque()
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return true
+			}
+		}
+	}
+	return false
+}
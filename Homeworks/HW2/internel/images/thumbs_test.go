@@ -0,0 +1,75 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseProfiles(t *testing.T) {
+	p, err := ParseProfiles(" 200w, sq64 ,1024w")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(p); got != "[{200w 200 false} {sq64 64 true} {1024w 1024 false}]" {
+		t.Fatalf("profiles = %s", got)
+	}
+	for _, bad := range []string{"", "200", "w", "0w", "sqx", "200w,200w", "99999w"} {
+		if _, err := ParseProfiles(bad); err == nil {
+			t.Errorf("ParseProfiles(%q) accepted", bad)
+		}
+	}
+}
+
+func TestProcessRaster_Variants(t *testing.T) {
+	opaque := gradient(800, 400, false)
+	logo := image.NewNRGBA(image.Rect(0, 0, 300, 600))
+	logo.Set(10, 10, color.NRGBA{255, 0, 0, 255}) // the rest is transparent
+
+	for _, tc := range []struct {
+		name string
+		img  image.Image
+		mime string
+		want string // profile:WxH, in profile order
+	}{
+		{"opaque", opaque, "image/jpeg", "200w:200x100 400w:400x200 sq200:200x200"},
+		{"alpha", logo, "image/png", "200w:200x400 400w:300x600 sq200:200x200"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var src bytes.Buffer
+			if err := png.Encode(&src, tc.img); err != nil {
+				t.Fatal(err)
+			}
+			d := NewDownloader("test", t.TempDir())
+			p, err := d.processBytes("https://x/"+tc.name+".png", src.Bytes(), "image/png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, th := range p.Thumbs {
+				got = append(got, fmt.Sprintf("%s:%dx%d", th.Profile, th.Width, th.Height))
+				if th.MIME != tc.mime {
+					t.Errorf("%s: MIME %s, want %s", th.Profile, th.MIME, tc.mime)
+				}
+				cfg, format, err := image.DecodeConfig(bytes.NewReader(th.Bytes))
+				if err != nil || "image/"+format != tc.mime || cfg.Width != th.Width || cfg.Height != th.Height {
+					t.Errorf("%s: encoded %s %dx%d (%v)", th.Profile, format, cfg.Width, cfg.Height, err)
+				}
+				if b, err := os.ReadFile(th.Path); err != nil || !bytes.Equal(b, th.Bytes) {
+					t.Errorf("%s: file %s: %v", th.Profile, th.Path, err)
+				}
+			}
+			if strings.Join(got, " ") != tc.want {
+				t.Fatalf("variants %v, want %s", got, tc.want)
+			}
+			if p.ThumbPath != p.Thumbs[0].Path || p.ThumbMIME != tc.mime {
+				t.Fatalf("default thumbnail %s %s is not the first variant", p.ThumbPath, p.ThumbMIME)
+			}
+		})
+	}
+}
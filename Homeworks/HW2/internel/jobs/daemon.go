@@ -0,0 +1,268 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/yourname/go-image-crawler/internal/crawl"
+	"github.com/yourname/go-image-crawler/internal/storage"
+)
+
+// runsFile records, in the daemon's state directory, the crawl each job has
+// in flight; a crawl still listed at startup was interrupted and is resumed
+// from its checkpoint.
+const runsFile = "jobs-runs.json"
+
+// Daemon runs each job at its scheduled times until its context ends. A job
+// never overlaps itself: scheduled times that pass while its previous run is
+// still going are skipped and counted, not queued.
+type Daemon struct {
+	Repo storage.Repository
+	Jobs []Job
+	Logf func(format string, args ...any)
+	// Crawl runs one crawl; crawl.Run by default.
+	Crawl func(ctx context.Context, seeds []string, repo storage.Repository, cfg crawl.Config) error
+
+	stateDir string
+
+	mu       sync.Mutex
+	status   map[string]*Status
+	inFlight map[string]string // job name -> crawl id
+}
+
+// Status is a job's state as served by the status endpoint.
+type Status struct {
+	Name     string   `json:"name"`
+	Schedule string   `json:"schedule"`
+	Seeds    []string `json:"seeds"`
+	Running  bool     `json:"running"`
+	// CrawlID is the running crawl, or else the last one.
+	CrawlID string `json:"crawl_id,omitempty"`
+	Runs    int    `json:"runs"`
+	// Skipped counts scheduled times missed because a run was still going.
+	Skipped   int        `json:"skipped"`
+	LastStart *time.Time `json:"last_start,omitempty"`
+	LastEnd   *time.Time `json:"last_end,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	NextRun   *time.Time `json:"next_run,omitempty"`
+}
+
+// NewDaemon returns a daemon for jobs. stateDir holds the record of
+// in-flight crawls that lets an interrupted run resume on the next start;
+// empty disables resuming.
+func NewDaemon(repo storage.Repository, jobs []Job, stateDir string) (*Daemon, error) {
+	d := &Daemon{
+		Repo:     repo,
+		Jobs:     jobs,
+		Logf:     log.Printf,
+		Crawl:    crawl.Run,
+		stateDir: stateDir,
+		status:   make(map[string]*Status),
+		inFlight: make(map[string]string),
+	}
+	for _, j := range jobs {
+		d.status[j.Name] = &Status{Name: j.Name, Schedule: j.Spec, Seeds: j.Seeds}
+	}
+	if stateDir != "" {
+		b, err := os.ReadFile(filepath.Join(stateDir, runsFile))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(b, &d.inFlight); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+// Run schedules every job and blocks until ctx ends and the running crawls
+// have stopped. Canceled crawls checkpoint themselves and are resumed by the
+// next Run.
+func (d *Daemon) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, j := range d.Jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.loop(ctx, j)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Daemon) loop(ctx context.Context, j Job) {
+	d.mu.Lock()
+	resumeID := d.inFlight[j.Name]
+	d.mu.Unlock()
+	if resumeID != "" {
+		if _, err := crawl.LoadState(j.Config.StateDir, resumeID); err != nil {
+			d.Logf("[%s] cannot resume crawl %s: %v", j.Name, resumeID, err)
+			d.setInFlight(j.Name, "")
+		} else {
+			d.Logf("[%s] resuming interrupted crawl %s", j.Name, resumeID)
+			d.runOnce(ctx, j, resumeID)
+		}
+	}
+
+	next := j.Schedule.Next(time.Now())
+	for ctx.Err() == nil {
+		if next.IsZero() {
+			d.Logf("[%s] schedule %q never fires", j.Name, j.Spec)
+			return
+		}
+		d.update(j.Name, func(s *Status) { s.NextRun = &next })
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		d.runOnce(ctx, j, "")
+
+		skipped := 0
+		now := time.Now()
+		for next = j.Schedule.Next(next); !next.IsZero() && !next.After(now); next = j.Schedule.Next(next) {
+			skipped++
+		}
+		if skipped > 0 {
+			d.Logf("[%s] skipped %d scheduled run(s) while the previous one was running", j.Name, skipped)
+			d.update(j.Name, func(s *Status) { s.Skipped += skipped })
+		}
+	}
+}
+
+// runOnce runs one crawl of j, resuming crawl resumeID when it is set.
+func (d *Daemon) runOnce(ctx context.Context, j Job, resumeID string) {
+	cfg := j.Config
+	cfg.Logf = func(format string, args ...any) {
+		d.Logf("[%s] "+format, append([]any{j.Name}, args...)...)
+	}
+	seeds := j.Seeds
+	if resumeID != "" {
+		cfg.CrawlID, cfg.Resume = resumeID, true
+		seeds = nil // the checkpoint has them
+	} else {
+		cfg.CrawlID = crawl.NewCrawlID()
+	}
+	if cfg.StateDir != "" {
+		d.setInFlight(j.Name, cfg.CrawlID)
+	}
+
+	start := time.Now()
+	d.update(j.Name, func(s *Status) {
+		s.Running, s.CrawlID, s.LastStart, s.NextRun = true, cfg.CrawlID, &start, nil
+		s.Runs++
+	})
+	err := d.Crawl(ctx, seeds, d.Repo, cfg)
+	end := time.Now()
+	d.update(j.Name, func(s *Status) {
+		s.Running, s.LastEnd, s.LastError = false, &end, ""
+		if err != nil {
+			s.LastError = err.Error()
+		}
+	})
+	if err != nil {
+		d.Logf("[%s] crawl %s: %v", j.Name, cfg.CrawlID, err)
+	}
+	if ctx.Err() == nil || err != nil {
+		d.setInFlight(j.Name, "") // finished, or failed before it could checkpoint
+	}
+}
+
+func (d *Daemon) update(name string, fn func(*Status)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(d.status[name])
+}
+
+// setInFlight records (or, with an empty id, clears) the crawl job name has
+// running, and persists the record.
+func (d *Daemon) setInFlight(name, id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if id == "" {
+		delete(d.inFlight, name)
+	} else {
+		d.inFlight[name] = id
+	}
+	if d.stateDir == "" {
+		return
+	}
+	if err := writeFileAtomic(filepath.Join(d.stateDir, runsFile), d.inFlight); err != nil {
+		d.Logf("[%s] record in-flight crawl: %v", name, err)
+	}
+}
+
+// Statuses returns a snapshot of every job's status, in jobs file order.
+func (d *Daemon) Statuses() []Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]Status, 0, len(d.Jobs))
+	for _, j := range d.Jobs {
+		out = append(out, *d.status[j.Name])
+	}
+	return out
+}
+
+// Handler serves job status as JSON: GET /jobs lists every job and
+// GET /jobs/{name} returns one.
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, d.Statuses())
+	})
+	mux.HandleFunc("GET /jobs/{name}", func(w http.ResponseWriter, r *http.Request) {
+		for _, s := range d.Statuses() {
+			if s.Name == r.PathValue("name") {
+				writeJSON(w, http.StatusOK, s)
+				return
+			}
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such job"})
+	})
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// writeFileAtomic writes v as JSON to path via a temp file and rename.
+func writeFileAtomic(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
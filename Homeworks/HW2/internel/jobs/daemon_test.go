@@ -0,0 +1,163 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourname/go-image-crawler/internal/crawl"
+	"github.com/yourname/go-image-crawler/internal/storage"
+)
+
+func TestParse_Overrides(t *testing.T) {
+	base := crawl.Config{MaxPages: 1000, Timeout: 2 * time.Minute, Render: true}
+	js, err := Parse([]byte(`{"jobs": [
+		{"name": "a", "seeds": ["https://a.example/"], "schedule": "@daily",
+		 "config": {"MaxPages": 5, "Timeout": "90s", "Render": false, "Sitemaps": ["https://a.example/s.xml"]}},
+		{"name": "b", "seeds": ["https://b.example/"], "schedule": "*/5 * * * *"}
+	]}`), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := js[0].Config, js[1].Config
+	if a.MaxPages != 5 || a.Timeout != 90*time.Second || a.Render || len(a.Sitemaps) != 1 {
+		t.Errorf("job a config = %+v", a)
+	}
+	if b.MaxPages != 1000 || b.Timeout != 2*time.Minute || !b.Render {
+		t.Errorf("job b config = %+v", b)
+	}
+
+	for _, bad := range []string{
+		`{"jobs": []}`,
+		`{"jobs": [{"name": "a b", "seeds": ["x"], "schedule": "@daily"}]}`,
+		`{"jobs": [{"name": "a", "seeds": ["x"], "schedule": "@daily"}, {"name": "a", "seeds": ["y"], "schedule": "@daily"}]}`,
+		`{"jobs": [{"name": "a", "schedule": "@daily"}]}`,
+		`{"jobs": [{"name": "a", "seeds": ["x"], "schedule": "soon"}]}`,
+		`{"jobs": [{"name": "a", "seeds": ["x"], "schedule": "@daily", "config": {"Bogus": 1}}]}`,
+		`{"jobs": [{"name": "a", "seeds": ["x"], "schedule": "@daily", "config": {"CrawlID": "x"}}]}`,
+		`{"jobs": [{"name": "a", "seeds": ["x"], "schedule": "@daily", "config": {"Timeout": 90}}]}`,
+		`{"jobs": [{"name": "a", "seeds": ["x"], "schedule": "@daily", "extra": true}]}`,
+	} {
+		if _, err := Parse([]byte(bad), base); err == nil {
+			t.Errorf("Parse(%s) succeeded", bad)
+		}
+	}
+}
+
+// tick fires every 10ms; Schedule only needs Next.
+type tick struct{}
+
+func (tick) Next(t time.Time) time.Time { return t.Add(10 * time.Millisecond) }
+
+func TestDaemon_NoOverlap(t *testing.T) {
+	var running, maxRunning, runs atomic.Int32
+	d, err := NewDaemon(nil, []Job{{Name: "slow", Seeds: []string{"https://a.example/"}, Spec: "tick", Schedule: tick{}}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Logf = t.Logf
+	d.Crawl = func(ctx context.Context, _ []string, _ storage.Repository, _ crawl.Config) error {
+		n := running.Add(1)
+		if n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		runs.Add(1)
+		time.Sleep(35 * time.Millisecond) // spans several ticks
+		running.Add(-1)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	d.Run(ctx)
+
+	st := d.Statuses()[0]
+	if maxRunning.Load() != 1 || runs.Load() < 2 || st.Runs != int(runs.Load()) || st.Skipped == 0 || st.Running {
+		t.Fatalf("max concurrent=%d runs=%d status=%+v", maxRunning.Load(), runs.Load(), st)
+	}
+}
+
+func TestDaemon_ResumesInterruptedRun(t *testing.T) {
+	dir := t.TempDir()
+	job := Job{Name: "j", Seeds: []string{"https://a.example/"}, Spec: "@daily", Schedule: tick{}, Config: crawl.Config{StateDir: dir}}
+
+	// First daemon: the crawl checkpoints and is canceled by shutdown.
+	ctx, cancel := context.WithCancel(context.Background())
+	var interrupted string
+	d, err := NewDaemon(nil, []Job{job}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Logf = t.Logf
+	d.Crawl = func(ctx context.Context, _ []string, _ storage.Repository, cfg crawl.Config) error {
+		interrupted = cfg.CrawlID
+		if err := (&crawl.State{ID: cfg.CrawlID}).Save(dir); err != nil {
+			t.Error(err)
+		}
+		cancel()
+		<-ctx.Done()
+		return nil
+	}
+	d.Run(ctx)
+
+	// Second daemon: resumes that crawl first, without seeds.
+	var mu sync.Mutex
+	var resumed []crawl.Config
+	d, err = NewDaemon(nil, []Job{job}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Logf = t.Logf
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	d.Crawl = func(_ context.Context, seeds []string, _ storage.Repository, cfg crawl.Config) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if seeds == nil {
+			resumed = append(resumed, cfg)
+		}
+		return nil
+	}
+	d.Run(ctx)
+	if len(resumed) != 1 || !resumed[0].Resume || resumed[0].CrawlID != interrupted {
+		t.Fatalf("resumed %+v, want crawl %s", resumed, interrupted)
+	}
+	if len(d.inFlight) != 0 {
+		t.Fatalf("in-flight record not cleared: %v", d.inFlight)
+	}
+}
+
+func TestDaemon_Handler(t *testing.T) {
+	d, err := NewDaemon(nil, []Job{{Name: "a", Seeds: []string{"https://a.example/"}, Spec: "@daily", Schedule: tick{}}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []Status
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if err != nil || len(list) != 1 || list[0].Name != "a" || list[0].Schedule != "@daily" {
+		t.Fatalf("GET /jobs = %+v %v", list, err)
+	}
+
+	for path, want := range map[string]int{"/jobs/a": 200, "/jobs/nope": 404} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package jobs runs crawls on a schedule ("crawler serve").
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yourname/go-image-crawler/internal/crawl"
+)
+
+// Job is one scheduled crawl from the jobs file.
+type Job struct {
+	Name     string
+	Seeds    []string
+	Spec     string // schedule as written
+	Schedule Schedule
+	// Config is the daemon's base config with the job's overrides applied.
+	Config crawl.Config
+}
+
+// jobFile is the jobs file layout:
+//
+//	{"jobs": [{
+//	  "name": "go-dev",
+//	  "seeds": ["https://go.dev/"],
+//	  "schedule": "0 */6 * * *",
+//	  "config": {"MaxPages": 500, "Timeout": "10m", "RecrawlAfter": "24h"}
+//	}]}
+//
+// config keys are crawl.Config field names, as in the crawl history.
+type jobFile struct {
+	Jobs []struct {
+		Name     string                     `json:"name"`
+		Seeds    []string                   `json:"seeds"`
+		Schedule string                     `json:"schedule"`
+		Config   map[string]json.RawMessage `json:"config"`
+	} `json:"jobs"`
+}
+
+// Load reads the jobs file at path; each job's config starts from base.
+func Load(path string, base crawl.Config) ([]Job, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b, base)
+}
+
+// Parse is Load for a jobs file already in memory.
+func Parse(b []byte, base crawl.Config) ([]Job, error) {
+	var f jobFile
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("jobs file: %w", err)
+	}
+	if len(f.Jobs) == 0 {
+		return nil, errors.New("jobs file: no jobs")
+	}
+	seen := make(map[string]bool)
+	var out []Job
+	for i, j := range f.Jobs {
+		if !validName(j.Name) {
+			return nil, fmt.Errorf("job %d: name %q must be non-empty letters, digits, '-' or '_'", i+1, j.Name)
+		}
+		if seen[j.Name] {
+			return nil, fmt.Errorf("job %s: duplicate name", j.Name)
+		}
+		seen[j.Name] = true
+
+		sched, err := ParseSchedule(j.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", j.Name, err)
+		}
+		cfg := base
+		if err := crawl.ApplyOverrides(&cfg, j.Config); err != nil {
+			return nil, fmt.Errorf("job %s: %w", j.Name, err)
+		}
+		if len(j.Seeds) == 0 && len(cfg.Sitemaps) == 0 {
+			return nil, fmt.Errorf("job %s: no seeds or Sitemaps", j.Name)
+		}
+		out = append(out, Job{Name: j.Name, Seeds: j.Seeds, Spec: j.Schedule, Schedule: sched, Config: cfg})
+	}
+	return out, nil
+}
+
+func validName(s string) bool {
+	return s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	}) < 0
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule_Next(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	from := at("2024-01-31 10:17") // a Wednesday
+	cases := []struct{ spec, want string }{
+		{"* * * * *", "2024-01-31 10:18"},
+		{"*/15 * * * *", "2024-01-31 10:30"},
+		{"0 */6 * * *", "2024-01-31 12:00"},
+		{"30 9 * * *", "2024-02-01 09:30"},
+		{"0 0 * * 1-5", "2024-02-01 00:00"},
+		{"0 0 * * 0", "2024-02-04 00:00"},
+		{"0 0 * * 7", "2024-02-04 00:00"},
+		{"0 0 29 2 *", "2024-02-29 00:00"},
+		{"0 0 31 * *", "2024-03-31 00:00"},
+		{"0 0 1 * 6", "2024-02-01 00:00"}, // day-of-month or Saturday
+		{"5,10 10-11 * * *", "2024-01-31 11:05"},
+		{"@hourly", "2024-01-31 11:00"},
+		{"@daily", "2024-02-01 00:00"},
+		{"@monthly", "2024-02-01 00:00"},
+		{"@every 90m", "2024-01-31 11:47"},
+	}
+	for _, c := range cases {
+		s, err := ParseSchedule(c.spec)
+		if err != nil {
+			t.Errorf("%q: %v", c.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(at(c.want)) {
+			t.Errorf("%q: Next = %s, want %s", c.spec, got.Format("2006-01-02 15:04"), c.want)
+		}
+	}
+}
+
+func TestParseSchedule_Errors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "x * * * *", "@every", "@every 10ms", "@yearly"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded", spec)
+		}
+	}
+	s, err := ParseSchedule("0 0 30 2 *") // never fires
+	if err != nil || !s.Next(time.Now()).IsZero() {
+		t.Errorf("Feb 30: %v %v", s, err)
+	}
+}
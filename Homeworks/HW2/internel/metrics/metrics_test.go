@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("pages_total", "Pages fetched.", "kind", "status")
+	g := r.NewGauge("queue_depth", "Queued items.")
+	h := r.NewHistogram("latency_seconds", "Fetch latency.\nIn seconds.", []float64{0.1, 1}, "fetcher")
+
+	c.Inc("page", "200")
+	c.Add(2, "page", "200")
+	c.Inc("image", `a"b\c`)
+	g.Add(5)
+	g.Dec()
+	h.Observe(0.05, "http")
+	h.Observe(0.1, "http") // bounds are inclusive
+	h.Observe(3, "http")
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP pages_total Pages fetched.
+# TYPE pages_total counter
+pages_total{kind="image",status="a\"b\\c"} 1
+pages_total{kind="page",status="200"} 3
+# HELP queue_depth Queued items.
+# TYPE queue_depth gauge
+queue_depth 4
+# HELP latency_seconds Fetch latency.\nIn seconds.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{fetcher="http",le="0.1"} 2
+latency_seconds_bucket{fetcher="http",le="1"} 2
+latency_seconds_bucket{fetcher="http",le="+Inf"} 3
+latency_seconds_sum{fetcher="http"} 3.15
+latency_seconds_count{fetcher="http"} 3
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+	if c.Value("page", "200") != 3 || g.Value() != 4 || h.Count("http") != 3 {
+		t.Errorf("values: %v %v %v", c.Value("page", "200"), g.Value(), h.Count("http"))
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("up_total", "Scrapes.").Inc()
+	rr := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rr.Body.String(), "up_total 1\n") {
+		t.Errorf("body = %q", rr.Body.String())
+	}
+}
+
+func TestRegistry_Misuse(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("a_total", "A.", "x")
+	for name, fn := range map[string]func(){
+		"duplicate":    func() { r.NewGauge("a_total", "again") },
+		"label count":  func() { c.Inc() },
+		"negative add": func() { c.Add(-1, "x") },
+		"bucket order": func() { r.NewHistogram("h", "H.", []float64{2, 1}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
 )
 
@@ -64,32 +67,73 @@ func (f *ChromedpFetcher) Fetch(ctx context.Context, url string) (FetchedPage, e
 		}()
 	}
 
-	var html string
-	var finalURL string
+	if f.UserAgent != "" {
+		// Must be set early, before navigation, but chromedp doesn't provide a simple per-nav UA setter.
+		// For many assignments it's acceptable to rely on default UA; if needed, set global UA via flags.
+	}
+
+	// The main document's response carries the status; error pages are
+	// not rendered.
+	resp, err := chromedp.RunResponse(tabCtx, chromedp.Navigate(url))
+	if err != nil {
+		return FetchedPage{}, classifyChrome(err)
+	}
+	fp, err := renderedPage(url, resp)
+	if err != nil {
+		return fp, err
+	}
+
+	var html, finalURL string
 	tasks := chromedp.Tasks{
-		chromedp.Navigate(url),
 		chromedp.WaitReady("body", chromedp.ByQuery),
 		chromedp.Sleep(f.SettleDelay),
 		chromedp.Location(&finalURL),
 		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
 	}
-	if f.UserAgent != "" {
-		// Must be set early, before navigation, but chromedp doesn't provide a simple per-nav UA setter.
-		// For many assignments it's acceptable to rely on default UA; if needed, set global UA via flags.
-	}
-
 	if err := chromedp.Run(tabCtx, tasks); err != nil {
-		return FetchedPage{}, err
+		return FetchedPage{}, classifyChrome(err)
 	}
-	if finalURL == "" {
-		finalURL = url
+	if finalURL != "" {
+		fp.FinalURL = finalURL
 	}
-	return FetchedPage{
-		FinalURL:    finalURL,
+	fp.Body = []byte(html)
+	return fp, nil
+}
+
+// renderedPage fills in what the main document's response says about a
+// rendered page, and returns a StatusError for a non-2xx status as
+// HTTPFetcher does. resp is nil for navigations without a network
+// response (about:blank).
+func renderedPage(url string, resp *network.Response) (FetchedPage, error) {
+	fp := FetchedPage{
+		FinalURL:    url,
 		ContentType: "text/html; rendered=chromedp",
-		Body:        []byte(html),
 		Rendered:    true,
-	}, nil
+	}
+	if resp == nil {
+		return fp, nil
+	}
+	if resp.URL != "" {
+		fp.FinalURL = resp.URL
+	}
+	fp.StatusCode = int(resp.Status)
+	fp.Header = make(http.Header, len(resp.Headers))
+	for k, v := range resp.Headers {
+		// Chrome joins repeated headers with newlines.
+		for _, line := range strings.Split(fmt.Sprint(v), "\n") {
+			fp.Header.Add(k, line)
+		}
+	}
+	if fp.StatusCode < 200 || fp.StatusCode > 299 {
+		status := ""
+		if resp.StatusText != "" {
+			status = fmt.Sprintf("%d %s", fp.StatusCode, resp.StatusText)
+		}
+		e := StatusError(fp.StatusCode, status)
+		e.RetryAfter = ParseRetryAfter(fp.Header.Get("Retry-After"), time.Now())
+		return fp, e
+	}
+	return fp, nil
 }
 
 func (f *ChromedpFetcher) Close() error {
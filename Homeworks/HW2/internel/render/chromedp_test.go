@@ -0,0 +1,76 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestRenderedPage_Status(t *testing.T) {
+	fp, err := renderedPage("http://x/gone", &network.Response{
+		URL:        "http://x/gone",
+		Status:     404,
+		StatusText: "Not Found",
+		Headers:    network.Headers{"Content-Type": "text/html", "Set-Cookie": "a=1\nb=2"},
+	})
+	var fe *Error
+	if !errors.As(err, &fe) || fe.Class != ClassHTTP4xx || fe.StatusCode != 404 || err.Error() != "http status 404 Not Found" {
+		t.Fatalf("err = %v", err)
+	}
+	if fp.StatusCode != 404 || !fp.Rendered || len(fp.Header.Values("Set-Cookie")) != 2 || fp.Body != nil {
+		t.Fatalf("page = %+v", fp)
+	}
+
+	_, err = renderedPage("http://x/busy", &network.Response{Status: 503, Headers: network.Headers{"Retry-After": "7"}})
+	if !errors.As(err, &fe) || fe.Class != ClassHTTP5xx || fe.RetryAfter != 7*time.Second {
+		t.Fatalf("503: %v", err)
+	}
+
+	fp, err = renderedPage("http://x/a", &network.Response{URL: "http://x/b", Status: 200, Headers: network.Headers{"Etag": `"v1"`}})
+	if err != nil || fp.StatusCode != 200 || fp.FinalURL != "http://x/b" || fp.Header.Get("ETag") != `"v1"` {
+		t.Fatalf("200: %+v, %v", fp, err)
+	}
+	if fp, err := renderedPage("about:blank", nil); err != nil || fp.StatusCode != 0 {
+		t.Fatalf("no response: %+v, %v", fp, err)
+	}
+}
+
+// TestChromedpFetcher_Status needs a local Chrome; it is skipped without one.
+func TestChromedpFetcher_Status(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a browser")
+	}
+	f, err := NewChromedpFetcher("test")
+	if err != nil {
+		t.Skipf("chromedp unavailable: %v", err)
+	}
+	defer f.Close()
+	f.SettleDelay = 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<html><body><a href="/secret">x</a></body></html>`))
+			return
+		}
+		_, _ = w.Write([]byte(`<html><body><a href="/next">next</a></body></html>`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	fp, err := f.Fetch(ctx, srv.URL+"/missing")
+	if Classify(err) != ClassHTTP4xx || fp.StatusCode != 404 || len(fp.Body) != 0 {
+		t.Fatalf("rendered 404: status %d, %d body bytes, err %v", fp.StatusCode, len(fp.Body), err)
+	}
+	fp, err = f.Fetch(ctx, srv.URL+"/")
+	if err != nil || fp.StatusCode != 200 || fp.Header.Get("Content-Type") != "text/html" || len(fp.Body) == 0 {
+		t.Fatalf("rendered 200: status %d, err %v", fp.StatusCode, err)
+	}
+}
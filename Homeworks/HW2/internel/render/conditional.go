@@ -0,0 +1,51 @@
+package render
+
+import (
+	"context"
+	"net/http"
+)
+
+// Validators are the ETag and Last-Modified of an earlier response. Sent
+// back as If-None-Match / If-Modified-Since they let the server answer
+// 304 Not Modified instead of the full body.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+func (v Validators) IsZero() bool { return v.ETag == "" && v.LastModified == "" }
+
+// ResponseValidators reads the validators of h.
+func ResponseValidators(h http.Header) Validators {
+	return Validators{ETag: h.Get("ETag"), LastModified: h.Get("Last-Modified")}
+}
+
+type validatorsKey struct{}
+
+// WithValidators makes fetches under ctx conditional on v. Fetchers that
+// cannot send conditional requests (chromedp) ignore it.
+func WithValidators(ctx context.Context, v Validators) context.Context {
+	if v.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, validatorsKey{}, v)
+}
+
+// ValidatorsFrom returns the validators set by WithValidators.
+func ValidatorsFrom(ctx context.Context) Validators {
+	v, _ := ctx.Value(validatorsKey{}).(Validators)
+	return v
+}
+
+// SetConditional adds If-None-Match / If-Modified-Since to req from the
+// validators in its context and reports whether it did.
+func SetConditional(req *http.Request) bool {
+	v := ValidatorsFrom(req.Context())
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+	return !v.IsZero()
+}
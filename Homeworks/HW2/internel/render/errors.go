@@ -0,0 +1,172 @@
+package render
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrorClass buckets fetch failures so a crawl report can tell the site's
+// problems (DNS, TLS, HTTP status, bad bytes) from ours (timeouts, limits).
+type ErrorClass string
+
+const (
+	ClassDNS         ErrorClass = "dns"         // host does not resolve
+	ClassTLS         ErrorClass = "tls"         // handshake or certificate failure
+	ClassTimeout     ErrorClass = "timeout"     // deadline hit while connecting or reading
+	ClassNetwork     ErrorClass = "network"     // refused, reset or otherwise dropped connection
+	ClassHTTP4xx     ErrorClass = "http_4xx"    // client error status
+	ClassHTTP5xx     ErrorClass = "http_5xx"    // server error status
+	ClassDecode      ErrorClass = "decode"      // body could not be parsed as HTML, XML or an image
+	ClassUnsupported ErrorClass = "unsupported" // image in a format the crawler cannot decode
+	ClassOversized   ErrorClass = "oversized"   // body larger than the configured cap
+	ClassRobots      ErrorClass = "robots"      // blocked by robots.txt
+	ClassOther       ErrorClass = "other"
+)
+
+// ErrorClasses lists every class in report order.
+var ErrorClasses = []ErrorClass{
+	ClassDNS, ClassTLS, ClassTimeout, ClassNetwork, ClassHTTP4xx, ClassHTTP5xx,
+	ClassDecode, ClassUnsupported, ClassOversized, ClassRobots, ClassOther,
+}
+
+// Error is a classified fetch failure.
+type Error struct {
+	Class ErrorClass
+	// StatusCode is set for ClassHTTP4xx and ClassHTTP5xx.
+	StatusCode int
+	// RetryAfter is the server's Retry-After, if it sent one.
+	RetryAfter time.Duration
+	// Attempts is set by RetryPolicy.Do (see Attempts).
+	Attempts int
+	Err      error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return string(e.Class)
+	}
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// StatusError returns the classified error for a non-2xx response.
+func StatusError(code int, status string) *Error {
+	if status == "" {
+		status = fmt.Sprintf("%d %s", code, http.StatusText(code))
+	}
+	class := ClassHTTP4xx
+	if code >= 500 {
+		class = ClassHTTP5xx
+	}
+	return &Error{Class: class, StatusCode: code, Err: fmt.Errorf("http status %s", status)}
+}
+
+// TooLarge reports a body that exceeded limit bytes.
+func TooLarge(limit int64) *Error {
+	return &Error{Class: ClassOversized, Err: fmt.Errorf("body exceeds %d bytes", limit)}
+}
+
+// DecodeError marks err as a failure to parse a fetched body.
+func DecodeError(err error) *Error {
+	return &Error{Class: ClassDecode, Err: err}
+}
+
+// UnsupportedError reports an image in a recognized format (such as AVIF)
+// that has no decoder.
+func UnsupportedError(format string, err error) *Error {
+	if err == nil {
+		err = fmt.Errorf("unsupported image format %s", format)
+	}
+	return &Error{Class: ClassUnsupported, Err: err}
+}
+
+// Classify returns the class of err: the Class of a wrapped *Error, or a
+// guess from the transport errors of net/http.
+func Classify(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+	var fe *Error
+	if errors.As(err, &fe) {
+		return fe.Class
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return ClassTimeout
+		}
+		return ClassDNS
+	}
+	if isTLSError(err) {
+		return ClassTLS
+	}
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) ||
+		(errors.As(err, &ne) && ne.Timeout()) {
+		return ClassTimeout
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ClassNetwork
+	}
+	return ClassOther
+}
+
+// classify wraps a transport error from http.Client.Do in an *Error.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var fe *Error
+	if errors.As(err, &fe) {
+		return err
+	}
+	return &Error{Class: Classify(err), Err: err}
+}
+
+func isTLSError(err error) bool {
+	var (
+		rh   tls.RecordHeaderError
+		ae   tls.AlertError
+		cv   *tls.CertificateVerificationError
+		ua   x509.UnknownAuthorityError
+		he   x509.HostnameError
+		ci   x509.CertificateInvalidError
+		errs = []any{&rh, &ae, &cv, &ua, &he, &ci}
+	)
+	for _, target := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyChrome maps the net::ERR_* codes chromedp reports on navigation.
+func classifyChrome(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	class := Classify(err)
+	switch {
+	case strings.Contains(msg, "ERR_NAME_NOT_RESOLVED"), strings.Contains(msg, "ERR_NAME_RESOLUTION_FAILED"):
+		class = ClassDNS
+	case strings.Contains(msg, "ERR_CERT_"), strings.Contains(msg, "ERR_SSL_"):
+		class = ClassTLS
+	case strings.Contains(msg, "ERR_TIMED_OUT"), strings.Contains(msg, "ERR_CONNECTION_TIMED_OUT"):
+		class = ClassTimeout
+	case strings.Contains(msg, "ERR_CONNECTION_"), strings.Contains(msg, "ERR_ADDRESS_UNREACHABLE"):
+		class = ClassNetwork
+	}
+	return &Error{Class: class, Err: err}
+}
@@ -0,0 +1,61 @@
+package render
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{&net.DNSError{Err: "no such host", Name: "nope.invalid", IsNotFound: true}, ClassDNS},
+		{&net.DNSError{Err: "i/o timeout", Name: "slow.example", IsTimeout: true}, ClassTimeout},
+		{fmt.Errorf("get: %w", x509.UnknownAuthorityError{}), ClassTLS},
+		{context.DeadlineExceeded, ClassTimeout},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ClassNetwork},
+		{StatusError(404, ""), ClassHTTP4xx},
+		{StatusError(503, "503 Service Unavailable"), ClassHTTP5xx},
+		{fmt.Errorf("wrapped: %w", TooLarge(10)), ClassOversized},
+		{DecodeError(errors.New("bad gif")), ClassDecode},
+		{UnsupportedError("avif", nil), ClassUnsupported},
+		{errors.New("something else"), ClassOther},
+		{classifyChrome(errors.New("page load error net::ERR_NAME_NOT_RESOLVED")), ClassDNS},
+	}
+	for _, c := range cases {
+		if got := Classify(c.err); got != c.want {
+			t.Errorf("Classify(%v) = %q, want %q", c.err, got, c.want)
+		}
+	}
+}
+
+func TestHTTPFetcher_ClassifiedErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			_, _ = w.Write([]byte(strings.Repeat("x", maxPageBytes+1)))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	f := NewHTTPFetcher("test")
+	if _, err := f.Fetch(context.Background(), srv.URL+"/big"); Classify(err) != ClassOversized {
+		t.Fatalf("big body: err = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := f.Fetch(ctx, srv.URL+"/slow"); Classify(err) != ClassTimeout {
+		t.Fatalf("slow: err = %v (%s)", err, Classify(err))
+	}
+}
@@ -1,15 +1,41 @@
 package render
 
-import "context"
+import (
+	"context"
+	"net/http"
+)
 
 type FetchedPage struct {
-	FinalURL    string
+	FinalURL string
+	// Redirects are the hops before FinalURL, in order (nil when the first
+	// response was final or the fetcher cannot tell).
+	Redirects []Redirect
+	// StatusCode is the HTTP status of the final response (0 when the
+	// fetcher cannot tell).
+	StatusCode int
+	// Header holds the final response headers.
+	Header      http.Header
 	ContentType string
 	Body        []byte
 	// Rendered indicates the HTML came from a DOM-rendered fetcher (chromedp).
 	Rendered bool
+	// Attempts is the number of tries a RetryFetcher needed (0 without one).
+	Attempts int
+	// NotModified is set when a conditional fetch (see WithValidators) got
+	// 304 Not Modified; Body is empty then.
+	NotModified bool
 }
 
+// Redirect is one hop of a redirect chain: URL answered with StatusCode.
+type Redirect struct {
+	URL        string
+	StatusCode int
+}
+
+// Fetcher fetches one URL. A response with a non-2xx status is reported as
+// an *Error (class ClassHTTP4xx or ClassHTTP5xx) unless the fetcher was
+// told to accept it; the returned FetchedPage still carries the status,
+// headers, final URL and redirects, but no body.
 type Fetcher interface {
 	Fetch(ctx context.Context, url string) (FetchedPage, error)
 	Close() error
@@ -5,12 +5,35 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"slices"
 	"time"
 )
 
+// maxPageBytes caps page and stylesheet bodies unless ctx says otherwise
+// (see WithMaxBytes).
+const maxPageBytes = 10 << 20
+
+type maxBytesKey struct{}
+
+// WithMaxBytes sets the body limit of HTTP fetches under ctx, e.g. the
+// larger limit sitemaps are allowed.
+func WithMaxBytes(ctx context.Context, n int64) context.Context {
+	return context.WithValue(ctx, maxBytesKey{}, n)
+}
+
+func maxBytesFrom(ctx context.Context) int64 {
+	if n, ok := ctx.Value(maxBytesKey{}).(int64); ok && n > 0 {
+		return n
+	}
+	return maxPageBytes
+}
+
 type HTTPFetcher struct {
 	Client    *http.Client
 	UserAgent string
+	// AcceptAnyStatus returns error pages (4xx, 5xx) as successful fetches
+	// instead of StatusError.
+	AcceptAnyStatus bool
 }
 
 func NewHTTPFetcher(userAgent string) *HTTPFetcher {
@@ -35,30 +58,67 @@ func (f *HTTPFetcher) Fetch(ctx context.Context, url string) (FetchedPage, error
 		req.Header.Set("User-Agent", f.UserAgent)
 	}
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	conditional := SetConditional(req)
 
 	resp, err := f.Client.Do(req)
 	if err != nil {
-		return FetchedPage{}, err
+		return FetchedPage{}, classify(err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20)) // 10MB cap for pages
-	if err != nil {
-		return FetchedPage{}, err
-	}
-
-	ct := resp.Header.Get("Content-Type")
 	finalURL := url
 	if resp.Request != nil && resp.Request.URL != nil {
 		finalURL = resp.Request.URL.String()
 	}
-
-	return FetchedPage{
+	fp := FetchedPage{
 		FinalURL:    finalURL,
-		ContentType: ct,
-		Body:        body,
+		Redirects:   redirectChain(resp),
+		StatusCode:  resp.StatusCode,
+		Header:      resp.Header,
+		ContentType: resp.Header.Get("Content-Type"),
 		Rendered:    false,
-	}, nil
+	}
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		fp.NotModified = true
+		return fp, nil
+	}
+	if (resp.StatusCode < 200 || resp.StatusCode > 299) && !f.AcceptAnyStatus {
+		return fp, ResponseError(resp)
+	}
+
+	fp.Body, err = ReadLimited(resp.Body, maxBytesFrom(ctx))
+	if err != nil {
+		return FetchedPage{}, err
+	}
+	return fp, nil
+}
+
+// redirectChain walks back from the final response through the redirect
+// responses net/http followed to reach it.
+func redirectChain(resp *http.Response) []Redirect {
+	var chain []Redirect
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		prev := req.Response
+		if prev.Request == nil || prev.Request.URL == nil {
+			break
+		}
+		chain = append(chain, Redirect{URL: prev.Request.URL.String(), StatusCode: prev.StatusCode})
+	}
+	slices.Reverse(chain)
+	return chain
 }
 
 func (f *HTTPFetcher) Close() error { return nil }
+
+// ReadLimited reads all of r, failing with a ClassOversized error when it
+// holds more than limit bytes and a classified error when the read breaks.
+func ReadLimited(r io.Reader, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, classify(err)
+	}
+	if int64(len(body)) > limit {
+		return nil, TooLarge(limit)
+	}
+	return body, nil
+}
@@ -0,0 +1,181 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy retries transient fetch failures (timeouts, dropped
+// connections, 5xx and 429) with exponential backoff and jitter. A
+// Retry-After from the server replaces the backoff when it is longer.
+type RetryPolicy struct {
+	// Attempts is the total number of tries; below 2 disables retries.
+	Attempts int
+	// BaseDelay is the backoff before the first retry; it doubles for each
+	// further retry up to MaxDelay. A Retry-After longer than MaxDelay is
+	// not waited for: the fetch fails instead.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// OnRetry, when set, is called before waiting for attempt+1.
+	OnRetry func(url string, attempt int, err error, wait time.Duration)
+}
+
+// DefaultRetryPolicy makes three attempts, waiting about 0.5s and 1s.
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second}
+
+// Retryable reports whether another attempt could succeed where err failed.
+func Retryable(err error) bool {
+	var fe *Error
+	if errors.As(err, &fe) && fe.StatusCode != 0 {
+		return retryableStatus(fe.StatusCode)
+	}
+	switch Classify(err) {
+	case ClassTimeout, ClassNetwork, ClassHTTP5xx:
+		return true
+	}
+	return false
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// Do calls fn until it succeeds, fails permanently, runs out of attempts or
+// ctx ends, and returns the number of attempts made. A failure is returned
+// as an *Error carrying that count.
+func (p RetryPolicy) Do(ctx context.Context, url string, fn func(ctx context.Context) error) (int, error) {
+	attempts := max(p.Attempts, 1)
+	for n := 1; ; n++ {
+		err := fn(ctx)
+		if err == nil {
+			return n, nil
+		}
+		if n >= attempts || !Retryable(err) || ctx.Err() != nil {
+			return n, withAttempts(err, n)
+		}
+		wait, ok := p.wait(n, err)
+		if !ok {
+			return n, withAttempts(err, n)
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(url, n, err, wait)
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return n, withAttempts(err, n)
+		case <-t.C:
+		}
+	}
+}
+
+// wait is the pause after failed attempt n: BaseDelay*2^(n-1) capped at
+// MaxDelay, jittered into its upper half, or the server's Retry-After when
+// that is longer. ok is false when Retry-After exceeds MaxDelay.
+func (p RetryPolicy) wait(n int, err error) (time.Duration, bool) {
+	base, maxDelay := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = DefaultRetryPolicy.BaseDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	d := base
+	for i := 1; i < n && d < maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, maxDelay)
+	d = d/2 + rand.N(d/2+1)
+
+	var fe *Error
+	if errors.As(err, &fe) && fe.RetryAfter > 0 {
+		if fe.RetryAfter > maxDelay {
+			return 0, false
+		}
+		d = max(d, fe.RetryAfter)
+	}
+	return d, true
+}
+
+func withAttempts(err error, n int) error {
+	var fe *Error
+	if !errors.As(err, &fe) {
+		fe = &Error{Class: Classify(err), Err: err}
+		err = fe
+	}
+	fe.Attempts = n
+	return err
+}
+
+// Attempts returns the number of tries recorded on err by RetryPolicy.Do
+// (1 when err was not retried).
+func Attempts(err error) int {
+	var fe *Error
+	if errors.As(err, &fe) && fe.Attempts > 0 {
+		return fe.Attempts
+	}
+	return 1
+}
+
+// ParseRetryAfter reads a Retry-After header: delay-seconds or an HTTP date.
+func ParseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// ResponseError is StatusError for resp, with its Retry-After.
+func ResponseError(resp *http.Response) *Error {
+	e := StatusError(resp.StatusCode, resp.Status)
+	e.RetryAfter = ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	return e
+}
+
+// RetryFetcher retries a Fetcher under Policy. Retryable status errors
+// (429, 5xx) are retried, including replies the inner fetcher accepted
+// (AcceptAnyStatus); when attempts run out the last reply is returned as
+// the inner fetcher gave it.
+type RetryFetcher struct {
+	Fetcher
+	Policy RetryPolicy
+}
+
+func (f *RetryFetcher) Fetch(ctx context.Context, url string) (FetchedPage, error) {
+	var (
+		fp       FetchedPage
+		gotReply bool
+	)
+	n, err := f.Policy.Do(ctx, url, func(ctx context.Context) error {
+		var err error
+		fp, err = f.Fetcher.Fetch(ctx, url)
+		gotReply = err == nil
+		if err == nil && retryableStatus(fp.StatusCode) {
+			e := StatusError(fp.StatusCode, "")
+			e.RetryAfter = ParseRetryAfter(fp.Header.Get("Retry-After"), time.Now())
+			return e
+		}
+		return err
+	})
+	fp.Attempts = n
+	if gotReply {
+		return fp, nil
+	}
+	return fp, err
+}
@@ -0,0 +1,187 @@
+package render
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastRetry = RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+func TestRetryFetcher(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		switch r.URL.Path {
+		case "/flaky": // 503, 429, then OK
+			switch n {
+			case 1:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			case 2:
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			default:
+				_, _ = w.Write([]byte("<html>ok</html>"))
+			}
+		case "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/later":
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		path     string
+		status   int
+		attempts int
+	}{
+		{"/flaky", 200, 3},
+		{"/down", 502, 3},    // attempts exhausted
+		{"/later", 503, 1},   // Retry-After beyond MaxDelay: no wait
+		{"/missing", 404, 1}, // never retried
+	}
+	for _, accept := range []bool{false, true} {
+		inner := NewHTTPFetcher("test")
+		inner.AcceptAnyStatus = accept
+		f := &RetryFetcher{Fetcher: inner, Policy: fastRetry}
+		for _, c := range cases {
+			hits.Store(0)
+			fp, err := f.Fetch(context.Background(), srv.URL+c.path)
+			// Error statuses come back as errors unless the fetcher accepts them;
+			// either way the final status and attempt count are reported.
+			wantErr := c.status != 200 && !accept
+			if (err != nil) != wantErr || fp.StatusCode != c.status || fp.Attempts != c.attempts || int(hits.Load()) != c.attempts {
+				t.Errorf("accept=%v %s: status=%d attempts=%d hits=%d err=%v; want %d after %d",
+					accept, c.path, fp.StatusCode, fp.Attempts, hits.Load(), err, c.status, c.attempts)
+			}
+			if wantErr && (Attempts(err) != c.attempts || StatusError(c.status, "").Class != Classify(err)) {
+				t.Errorf("%s: err=%v class=%s attempts=%d", c.path, err, Classify(err), Attempts(err))
+			}
+		}
+	}
+}
+
+func TestHTTPFetcher_Redirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		case "/new":
+			_, _ = w.Write([]byte("<html></html>"))
+		case "/dead":
+			http.Redirect(w, r, "/gone", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	f := NewHTTPFetcher("test")
+	fp, err := f.Fetch(context.Background(), srv.URL+"/old")
+	want := []Redirect{{srv.URL + "/old", 301}, {srv.URL + "/moved", 302}}
+	if err != nil || fp.FinalURL != srv.URL+"/new" || fp.StatusCode != 200 || !slices.Equal(fp.Redirects, want) {
+		t.Fatalf("fetch /old = %+v %v", fp, err)
+	}
+
+	fp, err = f.Fetch(context.Background(), srv.URL+"/dead")
+	if Classify(err) != ClassHTTP4xx || fp.StatusCode != 404 || fp.FinalURL != srv.URL+"/gone" || len(fp.Redirects) != 1 || fp.Body != nil {
+		t.Fatalf("fetch /dead = %+v %v", fp, err)
+	}
+}
+
+func TestHTTPFetcher_Conditional(t *testing.T) {
+	const lastMod = "Mon, 01 Jan 2024 12:00:00 GMT"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Modified-Since") == lastMod {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lastMod)
+		_, _ = w.Write([]byte("<html></html>"))
+	}))
+	defer srv.Close()
+
+	f := NewHTTPFetcher("test")
+	fp, err := f.Fetch(context.Background(), srv.URL)
+	v := ResponseValidators(fp.Header)
+	if err != nil || fp.NotModified || v.LastModified != lastMod {
+		t.Fatalf("first fetch = %+v %v", fp, err)
+	}
+	fp, err = f.Fetch(WithValidators(context.Background(), v), srv.URL)
+	if err != nil || !fp.NotModified || fp.StatusCode != http.StatusNotModified || fp.Body != nil {
+		t.Fatalf("conditional fetch = %+v %v", fp, err)
+	}
+}
+
+func TestHTTPFetcher_MaxBytes(t *testing.T) {
+	body := strings.Repeat("x", maxPageBytes+1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	f := NewHTTPFetcher("test")
+	if _, err := f.Fetch(context.Background(), srv.URL); Classify(err) != ClassOversized {
+		t.Fatalf("default limit: %v", err)
+	}
+	fp, err := f.Fetch(WithMaxBytes(context.Background(), 50<<20), srv.URL)
+	if err != nil || len(fp.Body) != len(body) {
+		t.Fatalf("raised limit: %d bytes, %v", len(fp.Body), err)
+	}
+}
+
+func TestRetryPolicy_TransportErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close() // connection refused from now on
+
+	var retries int
+	p := fastRetry
+	p.OnRetry = func(string, int, error, time.Duration) { retries++ }
+	f := &RetryFetcher{Fetcher: NewHTTPFetcher("test"), Policy: p}
+	_, err := f.Fetch(context.Background(), url)
+	if Classify(err) != ClassNetwork || Attempts(err) != 3 || retries != 2 {
+		t.Fatalf("err=%v class=%s attempts=%d retries=%d", err, Classify(err), Attempts(err), retries)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for n, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 6: time.Second} {
+		d, ok := p.wait(n, StatusError(503, ""))
+		if !ok || d < want/2 || d > want {
+			t.Errorf("wait(%d) = %s, want within [%s, %s]", n, d, want/2, want)
+		}
+	}
+	e := StatusError(429, "")
+	e.RetryAfter = 700 * time.Millisecond
+	if d, ok := p.wait(1, e); !ok || d != 700*time.Millisecond {
+		t.Errorf("Retry-After wait = %s %v", d, ok)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for in, want := range map[string]time.Duration{
+		"120":                           2 * time.Minute,
+		"Mon, 01 Jan 2024 12:00:30 GMT": 30 * time.Second,
+		"Mon, 01 Jan 2024 11:00:00 GMT": 0,
+		"soon":                          0,
+		"":                              0,
+	} {
+		if got := ParseRetryAfter(in, now); got != want {
+			t.Errorf("ParseRetryAfter(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
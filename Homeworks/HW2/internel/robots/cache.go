@@ -0,0 +1,189 @@
+package robots
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrDisallowed is reported for URLs skipped because of robots.txt rules.
+var ErrDisallowed = errors.New("blocked by robots.txt")
+
+// Cache fetches /robots.txt once per scheme+host and answers Allowed/CrawlDelay queries.
+// It is safe for concurrent use by the crawler workers.
+type Cache struct {
+	Client    *http.Client
+	UserAgent string
+	// TTL is how long a fetched robots.txt stays valid.
+	TTL time.Duration
+	// MaxBytes caps the robots.txt body (RFC 9309 asks for at least 500 KiB).
+	MaxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	ready   chan struct{} // closed once robots is set
+	robots  *Robots
+	fetched time.Time
+	// err is the transport error when robots.txt could not be fetched at
+	// all; robots is DisallowAll then.
+	err error
+}
+
+func NewCache(userAgent string) *Cache {
+	return &Cache{
+		Client:    &http.Client{Timeout: 15 * time.Second},
+		UserAgent: userAgent,
+		TTL:       24 * time.Hour,
+		MaxBytes:  512 << 10,
+		entries:   make(map[string]*entry),
+	}
+}
+
+// Get returns the robots.txt rules for the host of rawURL, fetching them on first use.
+// Concurrent callers for the same host wait for a single fetch.
+func (c *Cache) Get(ctx context.Context, rawURL string) (*Robots, error) {
+	e, err := c.lookup(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return e.robots, nil
+}
+
+func (c *Cache) lookup(ctx context.Context, rawURL string) (*entry, error) {
+	key, err := hostKey(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && e.robots != nil && c.TTL > 0 && time.Since(e.fetched) > c.TTL {
+		ok = false
+	}
+	if !ok {
+		e = &entry{ready: make(chan struct{})}
+		c.entries[key] = e
+		c.mu.Unlock()
+
+		rb, ferr := c.fetch(ctx, key)
+		c.mu.Lock()
+		if ctx.Err() != nil {
+			// Don't cache a "disallow all" caused by our own cancellation.
+			delete(c.entries, key)
+			c.mu.Unlock()
+			e.robots = DisallowAll
+			close(e.ready)
+			return nil, ctx.Err()
+		}
+		e.robots = rb
+		e.err = ferr
+		e.fetched = time.Now()
+		c.mu.Unlock()
+		close(e.ready)
+		return e, nil
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Allowed reports whether the cache's UserAgent may fetch rawURL. When the
+// host's robots.txt could not be reached it returns false and an error
+// wrapping the transport failure, so callers can tell a dead host from a
+// Disallow rule.
+func (c *Cache) Allowed(ctx context.Context, rawURL string) (bool, error) {
+	e, err := c.lookup(ctx, rawURL)
+	if err != nil {
+		return false, err
+	}
+	if e.err != nil {
+		return false, fmt.Errorf("robots.txt unreachable: %w", e.err)
+	}
+	rb := e.robots
+	pu, err := url.Parse(rawURL)
+	if err != nil {
+		return false, err
+	}
+	p := pu.EscapedPath()
+	if pu.RawQuery != "" {
+		p += "?" + pu.RawQuery
+	}
+	return rb.Allowed(c.UserAgent, p), nil
+}
+
+// CrawlDelay returns the Crawl-delay for the host of rawURL if its robots.txt
+// has already been fetched, and 0 otherwise. It never blocks on the network.
+func (c *Cache) CrawlDelay(rawURL string) time.Duration {
+	key, err := hostKey(rawURL)
+	if err != nil {
+		return 0
+	}
+	c.mu.Lock()
+	e := c.entries[key]
+	c.mu.Unlock()
+	if e == nil {
+		return 0
+	}
+	select {
+	case <-e.ready:
+		return e.robots.CrawlDelay(c.UserAgent)
+	default:
+		return 0
+	}
+}
+
+// fetch downloads and parses base/robots.txt. The error is only set when
+// the request itself failed (DNS, TLS, connection); the rules are
+// DisallowAll then.
+func (c *Cache) fetch(ctx context.Context, base string) (*Robots, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/robots.txt", nil)
+	if err != nil {
+		return DisallowAll, nil
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return DisallowAll, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		body, err := io.ReadAll(io.LimitReader(resp.Body, c.MaxBytes))
+		if err != nil {
+			return DisallowAll, nil
+		}
+		return Parse(body), nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		// No robots.txt (or not accessible to anyone): everything is allowed.
+		return AllowAll, nil
+	default:
+		return DisallowAll, nil
+	}
+}
+
+func hostKey(rawURL string) (string, error) {
+	pu, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if pu.Scheme == "" || pu.Host == "" {
+		return "", errors.New("robots: url without scheme/host: " + rawURL)
+	}
+	return pu.Scheme + "://" + pu.Host, nil
+}
@@ -0,0 +1,195 @@
+package robots
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Robots is a parsed robots.txt (RFC 9309 plus the common Crawl-delay and Sitemap extensions).
+type Robots struct {
+	groups   []group
+	Sitemaps []string
+}
+
+type group struct {
+	agents     []string // lower-cased product tokens, "*" for the default group
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// AllowAll is used when a host has no robots.txt (4xx).
+var AllowAll = &Robots{}
+
+// DisallowAll is used when robots.txt is unreachable (5xx, network errors), as RFC 9309 requires.
+var DisallowAll = &Robots{groups: []group{{agents: []string{"*"}, rules: []rule{{allow: false, pattern: "/"}}}}}
+
+// Parse parses a robots.txt body. It never fails: unknown lines are ignored.
+func Parse(body []byte) *Robots {
+	r := &Robots{}
+	var cur *group
+	lastWasAgent := false
+
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		v = strings.TrimSpace(v)
+
+		switch k {
+		case "user-agent":
+			// Consecutive user-agent lines share one group.
+			if cur == nil || !lastWasAgent {
+				r.groups = append(r.groups, group{})
+				cur = &r.groups[len(r.groups)-1]
+			}
+			cur.agents = append(cur.agents, strings.ToLower(v))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			if cur != nil && (v != "" || k == "allow") {
+				cur.rules = append(cur.rules, rule{allow: k == "allow", pattern: v})
+			}
+		case "crawl-delay":
+			if cur != nil {
+				if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+					cur.crawlDelay = time.Duration(f * float64(time.Second))
+				}
+			}
+		case "sitemap":
+			if v != "" {
+				r.Sitemaps = append(r.Sitemaps, v)
+			}
+		}
+		lastWasAgent = false
+	}
+	return r
+}
+
+// Allowed reports whether userAgent may fetch urlPath (path plus optional "?query").
+func (r *Robots) Allowed(userAgent, urlPath string) bool {
+	if urlPath == "" {
+		urlPath = "/"
+	}
+	if urlPath == "/robots.txt" {
+		return true
+	}
+	rules := r.rulesFor(userAgent)
+
+	best := -1
+	allow := true
+	for _, ru := range rules {
+		if ru.pattern == "" {
+			continue
+		}
+		if !match(ru.pattern, urlPath) {
+			continue
+		}
+		// Longest match wins; on a tie the least restrictive (allow) rule wins.
+		n := len(ru.pattern)
+		if n > best || (n == best && ru.allow) {
+			best = n
+			allow = ru.allow
+		}
+	}
+	return allow
+}
+
+// CrawlDelay returns the Crawl-delay that applies to userAgent (0 if none).
+func (r *Robots) CrawlDelay(userAgent string) time.Duration {
+	var d time.Duration
+	for _, g := range r.groupsFor(userAgent) {
+		if g.crawlDelay > d {
+			d = g.crawlDelay
+		}
+	}
+	return d
+}
+
+func (r *Robots) rulesFor(userAgent string) []rule {
+	var out []rule
+	for _, g := range r.groupsFor(userAgent) {
+		out = append(out, g.rules...)
+	}
+	return out
+}
+
+// groupsFor picks the groups naming userAgent's product token, falling back
+// to "*". Names are compared case-insensitively but otherwise exactly, so
+// "crawler" does not match "goimagecrawler". Groups naming the same agent
+// are merged, as RFC 9309 requires.
+func (r *Robots) groupsFor(userAgent string) []*group {
+	token := productToken(userAgent)
+
+	var specific, star []*group
+	for i := range r.groups {
+		g := &r.groups[i]
+		for _, a := range g.agents {
+			if a == "*" {
+				star = append(star, g)
+			} else if token != "" && a == token {
+				specific = append(specific, g)
+			}
+		}
+	}
+	if len(specific) > 0 {
+		return specific
+	}
+	return star
+}
+
+// productToken turns "GoImageCrawler/1.0 (+https://example.local)" into "goimagecrawler".
+func productToken(userAgent string) string {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if i := strings.IndexAny(ua, "/ ("); i >= 0 {
+		ua = ua[:i]
+	}
+	return ua
+}
+
+// match implements robots.txt path patterns: '*' matches any sequence and a
+// trailing '$' anchors the pattern at the end of the path.
+func match(pattern, p string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+	parts := strings.Split(pattern, "*")
+
+	// First part must be a prefix.
+	if !strings.HasPrefix(p, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i := 1; i < len(parts); i++ {
+		part := parts[i]
+		if i == len(parts)-1 && anchored {
+			// Last literal must sit at the very end.
+			return len(p)-len(part) >= pos && strings.HasSuffix(p, part)
+		}
+		j := strings.Index(p[pos:], part)
+		if j < 0 {
+			return false
+		}
+		pos += j + len(part)
+	}
+	if anchored {
+		return pos == len(p)
+	}
+	return true
+}
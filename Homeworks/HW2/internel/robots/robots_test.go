@@ -0,0 +1,118 @@
+package robots
+
+import (
+	"testing"
+	"time"
+)
+
+const sample = `
+# comment
+User-agent: *
+Disallow: /private/
+Allow: /private/public-*.html$
+Disallow: /*.gif$
+Crawl-delay: 2
+
+User-agent: GoImageCrawler
+User-agent: OtherBot
+Disallow: /no-crawler
+Crawl-delay: 0.5
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func TestParse_DefaultGroup(t *testing.T) {
+	r := Parse([]byte(sample))
+	ua := "SomeBot/2.0"
+
+	cases := map[string]bool{
+		"/":                         true,
+		"/private/":                 false,
+		"/private/x.html":           false,
+		"/private/public-a.html":    true,
+		"/private/public-a.html?x":  false,
+		"/img/cat.gif":              false,
+		"/img/cat.gif?size=2":       true,
+		"/robots.txt":               true,
+		"/no-crawler":               true,
+		"/img/cat.gifs-are-not-gif": true,
+	}
+	for p, want := range cases {
+		if got := r.Allowed(ua, p); got != want {
+			t.Errorf("Allowed(%q) = %v, want %v", p, got, want)
+		}
+	}
+	if d := r.CrawlDelay(ua); d != 2*time.Second {
+		t.Fatalf("CrawlDelay = %v, want 2s", d)
+	}
+}
+
+func TestParse_SpecificGroupWins(t *testing.T) {
+	r := Parse([]byte(sample))
+	ua := "GoImageCrawler/1.0 (+https://example.local)"
+
+	if r.Allowed(ua, "/no-crawler") {
+		t.Fatalf("expected /no-crawler to be disallowed for the specific agent")
+	}
+	// The specific group replaces "*", so /private/ is allowed for it.
+	if !r.Allowed(ua, "/private/") {
+		t.Fatalf("expected /private/ to be allowed for the specific agent")
+	}
+	if d := r.CrawlDelay(ua); d != 500*time.Millisecond {
+		t.Fatalf("CrawlDelay = %v, want 500ms", d)
+	}
+	if len(r.Sitemaps) != 1 || r.Sitemaps[0] != "https://example.com/sitemap.xml" {
+		t.Fatalf("Sitemaps = %v", r.Sitemaps)
+	}
+}
+
+func TestParse_AgentNearMiss(t *testing.T) {
+	r := Parse([]byte(`
+User-agent: crawler
+User-agent: go
+Disallow: /
+
+User-agent: *
+Disallow: /private/
+`))
+	ua := "GoImageCrawler/1.0"
+	if !r.Allowed(ua, "/") || r.Allowed(ua, "/private/") {
+		t.Fatalf("partial agent names must not match %q", ua)
+	}
+	if r.Allowed("Crawler/2.0", "/") {
+		t.Fatalf("exact agent name did not match")
+	}
+}
+
+func TestMatch_Patterns(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/", "/anything", true},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish", false},
+		{"/fish*", "/fishheads/yummy", true},
+		{"/*.php", "/folder/index.php?x=1", true},
+		{"/*.php$", "/folder/index.php?x=1", false},
+		{"/*.php$", "/index.php", true},
+		{"/fish$", "/fish", true},
+		{"/fish$", "/fish/", false},
+		{"/a*b*c", "/a-x-b-y-c-z", true},
+		{"/a*b*c", "/a-x-c-y-b", false},
+	}
+	for _, c := range cases {
+		if got := match(c.pattern, c.path); got != c.want {
+			t.Errorf("match(%q, %q) = %v, want %v", c.pattern, c.path, got, c.want)
+		}
+	}
+}
+
+func TestDisallowAll(t *testing.T) {
+	if DisallowAll.Allowed("x", "/page") {
+		t.Fatalf("DisallowAll should block /page")
+	}
+	if !AllowAll.Allowed("x", "/page") {
+		t.Fatalf("AllowAll should allow /page")
+	}
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+)
+
+var colorColumns = []string{"image_id", "seq", "r", "g", "b", "share"}
+
+// saveColors stores the dominant colors of image id. Like the image row,
+// colors already stored are kept, unless replace drops them first.
+func (r *sqlStore) saveColors(ctx context.Context, tx *sql.Tx, id uint64, colors []ImageColor, replace bool) error {
+	if replace {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM image_colors WHERE image_id = ?`, id); err != nil {
+			return err
+		}
+	}
+	q := r.dialect.upsert("image_colors", colorColumns, []string{"image_id", "seq"}, true)
+	for i, c := range colors {
+		if _, err := tx.ExecContext(ctx, q, id, i, int(c.R), int(c.G), int(c.B), c.Share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadDetails fills in the thumbnail variants and colors of recs.
+func (r *sqlStore) loadDetails(ctx context.Context, recs []ImageRecord) error {
+	if err := r.loadThumbs(ctx, recs); err != nil {
+		return err
+	}
+	return r.loadColors(ctx, recs)
+}
+
+// loadColors fills in the Colors of recs with one query.
+func (r *sqlStore) loadColors(ctx context.Context, recs []ImageRecord) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	ids := make([]any, len(recs))
+	at := make(map[uint64]int, len(recs))
+	for i, rec := range recs {
+		ids[i] = rec.ID
+		at[rec.ID] = i
+	}
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT image_id, r, g, b, share FROM image_colors WHERE image_id IN (`+placeholders(len(ids))+`)
+ORDER BY image_id, seq`, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uint64
+		var c ImageColor
+		if err := rows.Scan(&id, &c.R, &c.G, &c.B, &c.Share); err != nil {
+			return err
+		}
+		i := at[id]
+		recs[i].Colors = append(recs[i].Colors, c)
+	}
+	return rows.Err()
+}
+
+// colorFilter renders a WHERE condition keeping images with a dominant
+// color within dist (DefaultColorDistance when 0) of c. The per-channel
+// ranges let the index on r narrow the scan before the exact distance test.
+func colorFilter(c ImageColor, dist int) (string, []any) {
+	if dist <= 0 {
+		dist = DefaultColorDistance
+	}
+	r, g, b := int(c.R), int(c.G), int(c.B)
+	return `EXISTS (SELECT 1 FROM image_colors ic WHERE ic.image_id = images.id
+  AND ic.r BETWEEN ? AND ? AND ic.g BETWEEN ? AND ? AND ic.b BETWEEN ? AND ?
+  AND (ic.r - ?) * (ic.r - ?) + (ic.g - ?) * (ic.g - ?) + (ic.b - ?) * (ic.b - ?) <= ?)`,
+		[]any{r - dist, r + dist, g - dist, g + dist, b - dist, b + dist, r, r, g, g, b, b, dist * dist}
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Crawl session statuses.
+const (
+	CrawlRunning  = "running"
+	CrawlFinished = "finished"
+)
+
+// CrawlStart opens (or, for a resumed crawl, reopens) a crawl session.
+type CrawlStart struct {
+	ID        string
+	Seeds     []string
+	Config    string // JSON of the effective crawl.Config
+	StartedAt time.Time
+}
+
+// CrawlStats are the running totals of a crawl session.
+type CrawlStats struct {
+	PagesProcessed int
+	ImagesStored   int
+	FetchErrors    int
+	ImageErrors    int
+	DBErrors       int
+	RobotsSkipped  int
+	// Retries counts extra fetch attempts beyond the first.
+	Retries int
+	// Unchanged counts URLs skipped as fresh or answered 304 Not Modified.
+	Unchanged int
+}
+
+type CrawlRecord struct {
+	ID         string
+	Seeds      []string
+	Config     string
+	StartedAt  time.Time
+	FinishedAt sql.NullTime
+	Status     string
+	StopReason sql.NullString
+	// Runs counts the times the crawl was started (1 + resumes).
+	Runs int
+	CrawlStats
+}
+
+// Duration is the wall time from the first start to the finish (or now,
+// while running).
+func (c CrawlRecord) Duration() time.Duration {
+	end := time.Now()
+	if c.FinishedAt.Valid {
+		end = c.FinishedAt.Time
+	}
+	return end.Sub(c.StartedAt).Round(time.Second)
+}
+
+const crawlColumns = `id, seeds, config, started_at, finished_at, status, stop_reason, runs,
+  pages_processed, images_stored, fetch_errors, image_errors, db_errors, robots_skipped, retries, unchanged`
+
+func (r *sqlStore) StartCrawl(ctx context.Context, c CrawlStart) error {
+	seeds := strings.Join(c.Seeds, "\n")
+	res, err := r.db.ExecContext(ctx, `
+UPDATE crawls SET seeds = ?, config = ?, status = ?, finished_at = NULL, stop_reason = NULL, runs = runs + 1
+WHERE id = ?`, seeds, c.Config, CrawlRunning, c.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		return nil
+	}
+	_, err = r.db.ExecContext(ctx, `
+INSERT INTO crawls (id, seeds, config, started_at, status, runs) VALUES (?, ?, ?, ?, ?, 1)`,
+		c.ID, seeds, c.Config, c.StartedAt.UTC(), CrawlRunning)
+	return err
+}
+
+func (r *sqlStore) FinishCrawl(ctx context.Context, id, stopReason string, st CrawlStats) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE crawls SET status = ?, finished_at = ?, stop_reason = ?,
+  pages_processed = ?, images_stored = ?, fetch_errors = ?, image_errors = ?, db_errors = ?, robots_skipped = ?, retries = ?, unchanged = ?
+WHERE id = ?`,
+		CrawlFinished, time.Now().UTC(), stopReason,
+		st.PagesProcessed, st.ImagesStored, st.FetchErrors, st.ImageErrors, st.DBErrors, st.RobotsSkipped, st.Retries, st.Unchanged,
+		id)
+	return err
+}
+
+func (r *sqlStore) GetCrawl(ctx context.Context, id string) (CrawlRecord, error) {
+	return scanCrawl(r.db.QueryRowContext(ctx, `SELECT `+crawlColumns+` FROM crawls WHERE id = ?`, id))
+}
+
+// ListCrawls returns the most recently started crawls first.
+func (r *sqlStore) ListCrawls(ctx context.Context, limit int) ([]CrawlRecord, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT `+crawlColumns+` FROM crawls ORDER BY started_at DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []CrawlRecord
+	for rows.Next() {
+		c, err := scanCrawl(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func scanCrawl(row rowScanner) (CrawlRecord, error) {
+	var (
+		c     CrawlRecord
+		seeds string
+	)
+	err := row.Scan(&c.ID, &seeds, &c.Config, &c.StartedAt, &c.FinishedAt, &c.Status, &c.StopReason, &c.Runs,
+		&c.PagesProcessed, &c.ImagesStored, &c.FetchErrors, &c.ImageErrors, &c.DBErrors, &c.RobotsSkipped, &c.Retries, &c.Unchanged)
+	if seeds != "" {
+		c.Seeds = strings.Split(seeds, "\n")
+	}
+	return c, err
+}
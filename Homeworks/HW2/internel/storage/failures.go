@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FailureInsert is a failed fetch of one URL during a crawl. Class is one of
+// the render.ErrorClass values; a later failure of the same URL, kind and
+// crawl replaces the earlier one.
+type FailureInsert struct {
+	CrawlID    string
+	URL        string
+	Kind       string // "page", "resource", "sitemap" or "image"
+	Class      string
+	StatusCode int
+	Message    string
+	At         time.Time
+}
+
+type FailureRecord struct {
+	ID         uint64
+	CrawlID    string
+	URL        string
+	Host       string
+	Kind       string
+	Class      string
+	StatusCode sql.NullInt64
+	Message    sql.NullString
+	OccurredAt time.Time
+}
+
+// FailureFilter narrows failure queries; empty fields match everything.
+type FailureFilter struct {
+	CrawlID string
+	Class   string
+	Host    string
+	Limit   int
+}
+
+// FailureCount is the number of failures of one class on one host.
+type FailureCount struct {
+	Class string
+	Host  string
+	Count int
+}
+
+var failureColumns = []string{"crawl_id", "url", "url_hash", "host", "kind", "class", "status", "message", "occurred_at"}
+
+func (r *sqlStore) RecordFailure(ctx context.Context, f FailureInsert) error {
+	at := f.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, r.dialect.upsert("fetch_failures", failureColumns, []string{"crawl_id", "url_hash", "kind"}, false),
+		f.CrawlID, f.URL, urlHash(f.URL), failureHost(f.URL), f.Kind, f.Class,
+		nullIntIfZero(f.StatusCode), nullIfEmpty(f.Message), at.UTC())
+	return err
+}
+
+// FailureCounts groups failures by class and host, largest groups first.
+func (r *sqlStore) FailureCounts(ctx context.Context, f FailureFilter) ([]FailureCount, error) {
+	where, args := f.where()
+	rows, err := r.db.QueryContext(ctx, `SELECT class, host, COUNT(*) FROM fetch_failures`+where+`
+GROUP BY class, host ORDER BY COUNT(*) DESC, class, host`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []FailureCount
+	for rows.Next() {
+		var c FailureCount
+		if err := rows.Scan(&c.Class, &c.Host, &c.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ListFailures returns the most recent failures matching f.
+func (r *sqlStore) ListFailures(ctx context.Context, f FailureFilter) ([]FailureRecord, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	where, args := f.where()
+	rows, err := r.db.QueryContext(ctx, `SELECT id, crawl_id, url, host, kind, class, status, message, occurred_at
+FROM fetch_failures`+where+` ORDER BY occurred_at DESC, id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []FailureRecord
+	for rows.Next() {
+		var rec FailureRecord
+		if err := rows.Scan(&rec.ID, &rec.CrawlID, &rec.URL, &rec.Host, &rec.Kind, &rec.Class,
+			&rec.StatusCode, &rec.Message, &rec.OccurredAt); err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+func (f FailureFilter) where() (string, []any) {
+	var conds []string
+	var args []any
+	if f.CrawlID != "" {
+		conds = append(conds, "crawl_id = ?")
+		args = append(args, f.CrawlID)
+	}
+	if f.Class != "" {
+		conds = append(conds, "class = ?")
+		args = append(args, f.Class)
+	}
+	if f.Host != "" {
+		conds = append(conds, "host = ?")
+		args = append(args, f.Host)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// failureHost is the lower-cased host of u ("" for data: and unparsable URLs).
+func failureHost(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(pu.Hostname())
+}
@@ -0,0 +1,362 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations live in migrations/<dialect>/NNNN_name.up.sql (+ optional .down.sql)
+// and are compiled into the binary.
+//
+//go:embed migrations
+var migrationFS embed.FS
+
+// ErrSchemaAhead means the database has migrations this binary doesn't know about.
+// Running an older binary against a newer schema is refused.
+var ErrSchemaAhead = errors.New("database schema is newer than this binary")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Unknown marks versions recorded in the database but missing from this binary.
+	Unknown bool
+}
+
+var reMigrationFile = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_]+)\.(up|down)\.sql$`)
+
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := reMigrationFile.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		v, _ := strconv.Atoi(m[1])
+		b, err := fs.ReadFile(migrationFS, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[v]
+		if mig == nil {
+			mig = &Migration{Version: v, Name: m[2]}
+			byVersion[v] = mig
+		}
+		if m[3] == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no .up.sql", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+const createMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+  version BIGINT NOT NULL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// MigrateUp applies all pending migrations in order and returns their versions.
+func (r *sqlStore) MigrateUp(ctx context.Context) ([]int, error) {
+	migs, err := loadMigrations(r.dialect.name)
+	if err != nil {
+		return nil, err
+	}
+	var done []int
+	err = r.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := checkAhead(migs, applied); err != nil {
+			return err
+		}
+		for _, m := range migs {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err := r.applyMigration(ctx, conn, m.Up, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name)
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m.Version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown reverts the last steps applied migrations (newest first).
+func (r *sqlStore) MigrateDown(ctx context.Context, steps int) ([]int, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	migs, err := loadMigrations(r.dialect.name)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]Migration{}
+	for _, m := range migs {
+		byVersion[m.Version] = m
+	}
+	var done []int
+	err = r.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := checkAhead(migs, applied); err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+		for i := 0; i < steps && i < len(versions); i++ {
+			m := byVersion[versions[i]]
+			if m.Down == "" {
+				return fmt.Errorf("migration %04d_%s has no .down.sql", m.Version, m.Name)
+			}
+			err := r.applyMigration(ctx, conn, m.Down, `DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+			if err != nil {
+				return fmt.Errorf("revert %04d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m.Version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrationStatus lists known migrations and whether they are applied,
+// plus any versions present only in the database.
+func (r *sqlStore) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migs, err := loadMigrations(r.dialect.name)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	var out []MigrationStatus
+	known := map[int]bool{}
+	for _, m := range migs {
+		known[m.Version] = true
+		at, ok := applied[m.Version]
+		out = append(out, MigrationStatus{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: at})
+	}
+	for v, at := range applied {
+		if !known[v] {
+			out = append(out, MigrationStatus{Version: v, Applied: true, AppliedAt: at, Unknown: true})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// checkSchema refuses databases migrated by a newer binary.
+func (r *sqlStore) checkSchema(ctx context.Context) error {
+	migs, err := loadMigrations(r.dialect.name)
+	if err != nil {
+		return err
+	}
+	var exists int
+	q := r.dialect.tableExists
+	if err := r.db.QueryRowContext(ctx, q, "schema_migrations").Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return nil
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	applied, err := scanApplied(rows)
+	if err != nil {
+		return err
+	}
+	return checkAhead(migs, applied)
+}
+
+func checkAhead(migs []Migration, applied map[int]time.Time) error {
+	known := map[int]bool{}
+	for _, m := range migs {
+		known[m.Version] = true
+	}
+	for v := range applied {
+		if !known[v] {
+			return fmt.Errorf("%w (database has migration %04d)", ErrSchemaAhead, v)
+		}
+	}
+	return nil
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	return scanApplied(rows)
+}
+
+func scanApplied(rows *sql.Rows) (map[int]time.Time, error) {
+	defer rows.Close()
+	out := map[int]time.Time{}
+	for rows.Next() {
+		var v int
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		out[v] = at
+	}
+	return out, rows.Err()
+}
+
+// withMigrationLock runs fn on a single connection holding the dialect's
+// migration lock, so a crawler and a web server starting together don't race.
+func (r *sqlStore) withMigrationLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if r.dialect.lock != "" {
+		var got sql.NullInt64
+		if err := conn.QueryRowContext(ctx, r.dialect.lock).Scan(&got); err != nil {
+			return err
+		}
+		if !got.Valid || got.Int64 != 1 {
+			return errors.New("could not acquire migration lock")
+		}
+		defer func() {
+			_, _ = conn.ExecContext(context.Background(), r.dialect.unlock)
+		}()
+	}
+	if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// applyMigration runs script, then record (the schema_migrations insert or
+// delete) with args. Where DDL is transactional both happen in one
+// transaction, so a failing migration leaves neither the schema nor
+// schema_migrations changed. MySQL commits every DDL statement on its own;
+// a failure there leaves the statements before it applied and the version
+// unrecorded, so MySQL migrations must be safe to re-run statement by
+// statement (IF NOT EXISTS, or errors tolerated by ignoreMigrationError).
+func (r *sqlStore) applyMigration(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	if !r.dialect.transactionalDDL {
+		if err := r.runMigration(ctx, conn, script); err != nil {
+			return err
+		}
+		_, err := conn.ExecContext(ctx, record, args...)
+		return err
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := r.runMigration(ctx, tx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execer is a *sql.Conn or a *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (r *sqlStore) runMigration(ctx context.Context, db execer, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			if r.dialect.ignoreMigrationError != nil && r.dialect.ignoreMigrationError(err) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements splits a script on semicolons that end a line.
+// Migrations keep one statement per ';'-terminated line group; a CREATE
+// TRIGGER body runs on until a line reading "END;".
+func splitStatements(script string) []string {
+	var out []string
+	var cur strings.Builder
+	trigger := false
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if cur.Len() == 0 {
+			if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+				continue
+			}
+			trigger = strings.HasPrefix(strings.ToUpper(trimmed), "CREATE TRIGGER")
+		}
+		cur.WriteString(line)
+		cur.WriteByte('\n')
+		if trigger && !strings.EqualFold(trimmed, "END;") {
+			continue
+		}
+		if strings.HasSuffix(trimmed, ";") {
+			out = append(out, strings.TrimSuffix(strings.TrimSpace(cur.String()), ";"))
+			cur.Reset()
+		}
+	}
+	if s := strings.TrimSpace(cur.String()); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrate_UpStatusDown(t *testing.T) {
+	ctx := context.Background()
+	repo, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer repo.Close()
+
+	migs, err := loadMigrations("sqlite")
+	if err != nil || len(migs) == 0 {
+		t.Fatalf("loadMigrations: %v %v", migs, err)
+	}
+
+	applied, err := repo.MigrateUp(ctx)
+	if err != nil || len(applied) != len(migs) {
+		t.Fatalf("MigrateUp = %v %v, want %d migrations", applied, err, len(migs))
+	}
+	// Second run is a no-op.
+	if again, err := repo.MigrateUp(ctx); err != nil || len(again) != 0 {
+		t.Fatalf("second MigrateUp = %v %v", again, err)
+	}
+
+	st, err := repo.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	for _, s := range st {
+		if !s.Applied || s.AppliedAt.IsZero() {
+			t.Fatalf("migration not applied: %+v", s)
+		}
+	}
+
+	last := migs[len(migs)-1].Version
+	reverted, err := repo.MigrateDown(ctx, 1)
+	if err != nil || len(reverted) != 1 || reverted[0] != last {
+		t.Fatalf("MigrateDown = %v %v, want [%d]", reverted, err, last)
+	}
+	if applied, err := repo.MigrateUp(ctx); err != nil || len(applied) != 1 {
+		t.Fatalf("re-apply = %v %v", applied, err)
+	}
+}
+
+func TestMigrate_FailureRollsBack(t *testing.T) {
+	ctx := context.Background()
+	repo, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer repo.Close()
+	if _, err := repo.MigrateUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := repo.db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.applyMigration(ctx, conn, "CREATE TABLE half_done (x INT);\nCREATE TABLE broken (;\n",
+		`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, 9999, "broken")
+	conn.Close()
+	if err == nil {
+		t.Fatal("broken migration applied")
+	}
+	var n int
+	if err := repo.db.QueryRowContext(ctx, sqliteDialect.tableExists, "half_done").Scan(&n); err != nil || n != 0 {
+		t.Fatalf("half_done tables = %d (%v), want the first statement rolled back", n, err)
+	}
+	if err := repo.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = 9999`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("version recorded %d times (%v)", n, err)
+	}
+}
+
+func TestMigrate_ColorShareBackfill(t *testing.T) {
+	ctx := context.Background()
+	repo, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer repo.Close()
+	if _, err := repo.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	migs, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	steps := 0
+	for _, m := range migs {
+		if m.Version >= 15 {
+			steps++
+		}
+	}
+	if _, err := repo.MigrateDown(ctx, steps); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+
+	hist := make([]byte, histBins)
+	hist[48], hist[0] = 0xd2, 0x2d
+	if _, err := repo.db.ExecContext(ctx, `INSERT INTO images (url, page_url, color_hist, color_key) VALUES (?, ?, ?, ?)`,
+		"https://x/a.jpg", "https://x/", hist, 48); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := repo.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	var share int
+	if err := repo.db.QueryRowContext(ctx, `SELECT color_share FROM images`).Scan(&share); err != nil || share != 0xd2 {
+		t.Fatalf("color_share = %d %v, want %d", share, err, 0xd2)
+	}
+}
+
+func TestMigrate_RefusesNewerSchema(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "images.db")
+
+	repo, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	if _, err := repo.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := repo.db.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES (9999, 'from_the_future')`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	_ = repo.Close()
+
+	if _, err := Open("sqlite://" + path); !errors.Is(err, ErrSchemaAhead) {
+		t.Fatalf("Open err = %v, want ErrSchemaAhead", err)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	got := splitStatements("-- comment\nCREATE TABLE a (\n  x INT\n);\n\nCREATE INDEX i ON a(x);\n")
+	if len(got) != 2 || got[1] != "CREATE INDEX i ON a(x)" {
+		t.Fatalf("splitStatements = %q", got)
+	}
+
+	got = splitStatements("CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  INSERT INTO b VALUES (new.x);\n  INSERT INTO c VALUES (new.x);\nEND;\nDROP TABLE d;\n")
+	if len(got) != 2 || !strings.HasSuffix(got[0], "END") || got[1] != "DROP TABLE d" {
+		t.Fatalf("splitStatements(trigger) = %q", got)
+	}
+}
@@ -0,0 +1 @@
+DROP INDEX idx_format_created ON images;
@@ -0,0 +1 @@
+CREATE INDEX idx_format_created ON images(format, created_at);
@@ -0,0 +1,12 @@
+ALTER TABLE images
+  DROP INDEX idx_phash_b0,
+  DROP INDEX idx_phash_b1,
+  DROP INDEX idx_phash_b2,
+  DROP INDEX idx_phash_b3,
+  DROP INDEX idx_dup_group,
+  DROP COLUMN phash,
+  DROP COLUMN phash_b0,
+  DROP COLUMN phash_b1,
+  DROP COLUMN phash_b2,
+  DROP COLUMN phash_b3,
+  DROP COLUMN dup_group;
@@ -0,0 +1,12 @@
+ALTER TABLE images
+  ADD COLUMN phash BIGINT NULL,
+  ADD COLUMN phash_b0 SMALLINT UNSIGNED NULL,
+  ADD COLUMN phash_b1 SMALLINT UNSIGNED NULL,
+  ADD COLUMN phash_b2 SMALLINT UNSIGNED NULL,
+  ADD COLUMN phash_b3 SMALLINT UNSIGNED NULL,
+  ADD COLUMN dup_group BIGINT UNSIGNED NULL;
+CREATE INDEX idx_phash_b0 ON images(phash_b0);
+CREATE INDEX idx_phash_b1 ON images(phash_b1);
+CREATE INDEX idx_phash_b2 ON images(phash_b2);
+CREATE INDEX idx_phash_b3 ON images(phash_b3);
+CREATE INDEX idx_dup_group ON images(dup_group);
@@ -0,0 +1,4 @@
+ALTER TABLE images
+  DROP INDEX idx_color_key,
+  DROP COLUMN color_hist,
+  DROP COLUMN color_key;
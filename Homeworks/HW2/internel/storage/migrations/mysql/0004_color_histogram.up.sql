@@ -0,0 +1,4 @@
+ALTER TABLE images
+  ADD COLUMN color_hist VARBINARY(64) NULL,
+  ADD COLUMN color_key TINYINT UNSIGNED NULL;
+CREATE INDEX idx_color_key ON images(color_key);
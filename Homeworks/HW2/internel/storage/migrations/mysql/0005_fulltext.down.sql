@@ -0,0 +1,4 @@
+ALTER TABLE images
+  DROP INDEX ftx_images_text,
+  DROP COLUMN caption,
+  DROP COLUMN page_title;
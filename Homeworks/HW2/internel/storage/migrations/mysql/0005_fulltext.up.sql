@@ -0,0 +1,4 @@
+ALTER TABLE images
+  ADD COLUMN caption TEXT NULL,
+  ADD COLUMN page_title TEXT NULL;
+CREATE FULLTEXT INDEX ftx_images_text ON images(alt, title, filename, page_url, caption, page_title);
@@ -0,0 +1,4 @@
+ALTER TABLE images
+  DROP INDEX idx_crawl_created,
+  DROP COLUMN crawl_id;
+DROP TABLE IF EXISTS crawls;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS crawls (
+  id VARCHAR(64) NOT NULL,
+  seeds TEXT NOT NULL,
+  config TEXT NOT NULL,
+  started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  finished_at TIMESTAMP NULL,
+  status VARCHAR(16) NOT NULL,
+  stop_reason VARCHAR(32) NULL,
+  runs INT NOT NULL DEFAULT 1,
+  pages_processed INT NOT NULL DEFAULT 0,
+  images_stored INT NOT NULL DEFAULT 0,
+  fetch_errors INT NOT NULL DEFAULT 0,
+  image_errors INT NOT NULL DEFAULT 0,
+  db_errors INT NOT NULL DEFAULT 0,
+  robots_skipped INT NOT NULL DEFAULT 0,
+  PRIMARY KEY (id),
+  KEY idx_crawls_started (started_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+ALTER TABLE images ADD COLUMN crawl_id VARCHAR(64) NULL;
+CREATE INDEX idx_crawl_created ON images(crawl_id, created_at);
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS pages (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  url TEXT NOT NULL,
+  url_hash CHAR(64) NOT NULL,
+  final_url TEXT NULL,
+  status SMALLINT NULL,
+  content_type VARCHAR(255) NULL,
+  depth INT NOT NULL DEFAULT 0,
+  kind VARCHAR(16) NOT NULL,
+  rendered BOOLEAN NOT NULL DEFAULT FALSE,
+  fetched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  error TEXT NULL,
+  crawl_id VARCHAR(64) NULL,
+  out_links INT NOT NULL DEFAULT 0,
+  PRIMARY KEY (id),
+  UNIQUE KEY uniq_page_url_hash (url_hash),
+  KEY idx_pages_crawl (crawl_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE IF NOT EXISTS links (
+  from_page BIGINT UNSIGNED NOT NULL,
+  to_url TEXT NOT NULL,
+  to_hash CHAR(64) NOT NULL,
+  kind VARCHAR(16) NOT NULL,
+  PRIMARY KEY (from_page, to_hash, kind),
+  KEY idx_links_to (to_hash, kind),
+  CONSTRAINT fk_links_page FOREIGN KEY (from_page) REFERENCES pages(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
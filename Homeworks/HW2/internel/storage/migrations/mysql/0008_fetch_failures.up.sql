@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS fetch_failures (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  crawl_id VARCHAR(64) NOT NULL,
+  url TEXT NOT NULL,
+  url_hash CHAR(64) NOT NULL,
+  host VARCHAR(255) NOT NULL DEFAULT '',
+  kind VARCHAR(16) NOT NULL,
+  class VARCHAR(16) NOT NULL,
+  status SMALLINT NULL,
+  message TEXT NULL,
+  occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY uniq_failure (crawl_id, url_hash, kind),
+  KEY idx_failures_class_host (class, host)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
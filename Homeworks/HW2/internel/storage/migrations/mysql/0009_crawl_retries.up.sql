@@ -0,0 +1 @@
+ALTER TABLE crawls ADD COLUMN retries INT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE crawls DROP COLUMN unchanged;
+DROP TABLE IF EXISTS http_validators;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS http_validators (
+  url_hash CHAR(64) NOT NULL,
+  url TEXT NOT NULL,
+  etag VARCHAR(255) NULL,
+  last_modified VARCHAR(64) NULL,
+  checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (url_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+ALTER TABLE crawls ADD COLUMN unchanged INT NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS image_thumbs (
+  image_id BIGINT UNSIGNED NOT NULL,
+  profile VARCHAR(32) NOT NULL,
+  width INT NOT NULL,
+  height INT NOT NULL,
+  mime VARCHAR(64) NOT NULL,
+  path VARCHAR(1024) NULL,
+  data LONGBLOB NULL,
+  PRIMARY KEY (image_id, profile),
+  CONSTRAINT fk_image_thumbs_image FOREIGN KEY (image_id) REFERENCES images(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS image_colors;
+ALTER TABLE images
+  DROP INDEX idx_frames,
+  DROP INDEX idx_gps_lat,
+  DROP INDEX idx_taken_at,
+  DROP COLUMN bytes,
+  DROP COLUMN bit_depth,
+  DROP COLUMN frames,
+  DROP COLUMN icc_profile,
+  DROP COLUMN camera_make,
+  DROP COLUMN camera_model,
+  DROP COLUMN lens,
+  DROP COLUMN taken_at,
+  DROP COLUMN orientation,
+  DROP COLUMN gps_lat,
+  DROP COLUMN gps_lon;
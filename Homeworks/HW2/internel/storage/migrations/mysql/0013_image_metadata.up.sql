@@ -0,0 +1,26 @@
+ALTER TABLE images
+  ADD COLUMN bytes BIGINT UNSIGNED NULL,
+  ADD COLUMN bit_depth TINYINT UNSIGNED NULL,
+  ADD COLUMN frames INT UNSIGNED NULL,
+  ADD COLUMN icc_profile VARCHAR(255) NULL,
+  ADD COLUMN camera_make VARCHAR(128) NULL,
+  ADD COLUMN camera_model VARCHAR(128) NULL,
+  ADD COLUMN lens VARCHAR(255) NULL,
+  ADD COLUMN taken_at DATETIME NULL,
+  ADD COLUMN orientation TINYINT UNSIGNED NULL,
+  ADD COLUMN gps_lat DOUBLE NULL,
+  ADD COLUMN gps_lon DOUBLE NULL;
+CREATE INDEX idx_frames ON images(frames);
+CREATE INDEX idx_gps_lat ON images(gps_lat);
+CREATE INDEX idx_taken_at ON images(taken_at);
+CREATE TABLE IF NOT EXISTS image_colors (
+  image_id BIGINT UNSIGNED NOT NULL,
+  seq TINYINT UNSIGNED NOT NULL,
+  r SMALLINT NOT NULL,
+  g SMALLINT NOT NULL,
+  b SMALLINT NOT NULL,
+  share DOUBLE NOT NULL,
+  PRIMARY KEY (image_id, seq),
+  INDEX idx_image_colors_r (r),
+  CONSTRAINT fk_image_colors_image FOREIGN KEY (image_id) REFERENCES images(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,5 @@
+ALTER TABLE links
+  DROP COLUMN alt,
+  DROP COLUMN title,
+  DROP COLUMN caption,
+  DROP COLUMN page_title;
@@ -0,0 +1,5 @@
+ALTER TABLE links
+  ADD COLUMN alt TEXT NULL,
+  ADD COLUMN title TEXT NULL,
+  ADD COLUMN caption TEXT NULL,
+  ADD COLUMN page_title TEXT NULL;
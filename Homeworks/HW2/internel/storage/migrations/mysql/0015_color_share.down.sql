@@ -0,0 +1,3 @@
+CREATE INDEX idx_color_key ON images(color_key);
+DROP INDEX idx_color_key_share ON images;
+ALTER TABLE images DROP COLUMN color_share;
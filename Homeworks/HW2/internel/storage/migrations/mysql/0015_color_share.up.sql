@@ -0,0 +1,5 @@
+ALTER TABLE images ADD COLUMN color_share TINYINT UNSIGNED NULL;
+UPDATE images SET color_share = ORD(SUBSTRING(color_hist, color_key + 1, 1))
+WHERE color_key IS NOT NULL AND LENGTH(color_hist) = 64;
+CREATE INDEX idx_color_key_share ON images(color_key, color_share);
+DROP INDEX idx_color_key ON images;
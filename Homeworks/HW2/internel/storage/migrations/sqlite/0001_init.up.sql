@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS images (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  url TEXT NOT NULL,
+  page_url TEXT NOT NULL,
+  filename TEXT NULL,
+  alt TEXT NULL,
+  title TEXT NULL,
+  width INTEGER NULL,
+  height INTEGER NULL,
+  format TEXT NULL,
+  thumb_path TEXT NULL,
+  thumb_mime TEXT NULL,
+  thumb_blob BLOB NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (url, page_url)
+);
+CREATE INDEX IF NOT EXISTS idx_format ON images(format);
+CREATE INDEX IF NOT EXISTS idx_filename ON images(filename);
+CREATE INDEX IF NOT EXISTS idx_width ON images(width);
+CREATE INDEX IF NOT EXISTS idx_height ON images(height);
+CREATE INDEX IF NOT EXISTS idx_created_at ON images(created_at);
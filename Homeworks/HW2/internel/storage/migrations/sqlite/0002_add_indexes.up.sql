@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_format_created ON images(format, created_at);
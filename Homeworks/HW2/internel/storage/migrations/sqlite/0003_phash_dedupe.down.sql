@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_phash_b0;
+DROP INDEX IF EXISTS idx_phash_b1;
+DROP INDEX IF EXISTS idx_phash_b2;
+DROP INDEX IF EXISTS idx_phash_b3;
+DROP INDEX IF EXISTS idx_dup_group;
+ALTER TABLE images DROP COLUMN phash;
+ALTER TABLE images DROP COLUMN phash_b0;
+ALTER TABLE images DROP COLUMN phash_b1;
+ALTER TABLE images DROP COLUMN phash_b2;
+ALTER TABLE images DROP COLUMN phash_b3;
+ALTER TABLE images DROP COLUMN dup_group;
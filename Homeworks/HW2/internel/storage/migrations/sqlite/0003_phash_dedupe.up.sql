@@ -0,0 +1,11 @@
+ALTER TABLE images ADD COLUMN phash INTEGER NULL;
+ALTER TABLE images ADD COLUMN phash_b0 INTEGER NULL;
+ALTER TABLE images ADD COLUMN phash_b1 INTEGER NULL;
+ALTER TABLE images ADD COLUMN phash_b2 INTEGER NULL;
+ALTER TABLE images ADD COLUMN phash_b3 INTEGER NULL;
+ALTER TABLE images ADD COLUMN dup_group INTEGER NULL;
+CREATE INDEX IF NOT EXISTS idx_phash_b0 ON images(phash_b0);
+CREATE INDEX IF NOT EXISTS idx_phash_b1 ON images(phash_b1);
+CREATE INDEX IF NOT EXISTS idx_phash_b2 ON images(phash_b2);
+CREATE INDEX IF NOT EXISTS idx_phash_b3 ON images(phash_b3);
+CREATE INDEX IF NOT EXISTS idx_dup_group ON images(dup_group);
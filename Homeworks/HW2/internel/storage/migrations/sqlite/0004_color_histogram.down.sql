@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_color_key;
+ALTER TABLE images DROP COLUMN color_hist;
+ALTER TABLE images DROP COLUMN color_key;
@@ -0,0 +1,3 @@
+ALTER TABLE images ADD COLUMN color_hist BLOB NULL;
+ALTER TABLE images ADD COLUMN color_key INTEGER NULL;
+CREATE INDEX IF NOT EXISTS idx_color_key ON images(color_key);
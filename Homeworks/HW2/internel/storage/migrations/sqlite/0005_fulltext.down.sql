@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS images_fts_au;
+DROP TRIGGER IF EXISTS images_fts_ad;
+DROP TRIGGER IF EXISTS images_fts_ai;
+DROP TABLE IF EXISTS images_fts;
+ALTER TABLE images DROP COLUMN caption;
+ALTER TABLE images DROP COLUMN page_title;
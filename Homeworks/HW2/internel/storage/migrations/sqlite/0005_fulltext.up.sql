@@ -0,0 +1,22 @@
+ALTER TABLE images ADD COLUMN caption TEXT NULL;
+ALTER TABLE images ADD COLUMN page_title TEXT NULL;
+CREATE VIRTUAL TABLE IF NOT EXISTS images_fts USING fts5(
+  alt, title, filename, page_url, caption, page_title,
+  content='images', content_rowid='id'
+);
+CREATE TRIGGER images_fts_ai AFTER INSERT ON images BEGIN
+  INSERT INTO images_fts(rowid, alt, title, filename, page_url, caption, page_title)
+  VALUES (new.id, new.alt, new.title, new.filename, new.page_url, new.caption, new.page_title);
+END;
+CREATE TRIGGER images_fts_ad AFTER DELETE ON images BEGIN
+  INSERT INTO images_fts(images_fts, rowid, alt, title, filename, page_url, caption, page_title)
+  VALUES ('delete', old.id, old.alt, old.title, old.filename, old.page_url, old.caption, old.page_title);
+END;
+CREATE TRIGGER images_fts_au AFTER UPDATE OF alt, title, filename, page_url, caption, page_title ON images BEGIN
+  INSERT INTO images_fts(images_fts, rowid, alt, title, filename, page_url, caption, page_title)
+  VALUES ('delete', old.id, old.alt, old.title, old.filename, old.page_url, old.caption, old.page_title);
+  INSERT INTO images_fts(rowid, alt, title, filename, page_url, caption, page_title)
+  VALUES (new.id, new.alt, new.title, new.filename, new.page_url, new.caption, new.page_title);
+END;
+-- index rows stored before this migration
+INSERT INTO images_fts(images_fts) VALUES ('rebuild');
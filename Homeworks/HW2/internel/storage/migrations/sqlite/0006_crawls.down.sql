@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_crawl_created;
+ALTER TABLE images DROP COLUMN crawl_id;
+DROP TABLE IF EXISTS crawls;
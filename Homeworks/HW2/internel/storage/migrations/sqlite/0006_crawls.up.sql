@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS crawls (
+  id TEXT PRIMARY KEY,
+  seeds TEXT NOT NULL,
+  config TEXT NOT NULL,
+  started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  finished_at TIMESTAMP NULL,
+  status TEXT NOT NULL,
+  stop_reason TEXT NULL,
+  runs INTEGER NOT NULL DEFAULT 1,
+  pages_processed INTEGER NOT NULL DEFAULT 0,
+  images_stored INTEGER NOT NULL DEFAULT 0,
+  fetch_errors INTEGER NOT NULL DEFAULT 0,
+  image_errors INTEGER NOT NULL DEFAULT 0,
+  db_errors INTEGER NOT NULL DEFAULT 0,
+  robots_skipped INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_crawls_started ON crawls(started_at);
+ALTER TABLE images ADD COLUMN crawl_id TEXT NULL;
+CREATE INDEX IF NOT EXISTS idx_crawl_created ON images(crawl_id, created_at);
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS pages (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  url TEXT NOT NULL,
+  url_hash TEXT NOT NULL UNIQUE,
+  final_url TEXT NULL,
+  status INTEGER NULL,
+  content_type TEXT NULL,
+  depth INTEGER NOT NULL DEFAULT 0,
+  kind TEXT NOT NULL,
+  rendered INTEGER NOT NULL DEFAULT 0,
+  fetched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  error TEXT NULL,
+  crawl_id TEXT NULL,
+  out_links INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_pages_crawl ON pages(crawl_id);
+CREATE TABLE IF NOT EXISTS links (
+  from_page INTEGER NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+  to_url TEXT NOT NULL,
+  to_hash TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  PRIMARY KEY (from_page, to_hash, kind)
+);
+CREATE INDEX IF NOT EXISTS idx_links_to ON links(to_hash, kind);
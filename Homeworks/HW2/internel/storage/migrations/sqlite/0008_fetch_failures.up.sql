@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS fetch_failures (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  crawl_id TEXT NOT NULL,
+  url TEXT NOT NULL,
+  url_hash TEXT NOT NULL,
+  host TEXT NOT NULL DEFAULT '',
+  kind TEXT NOT NULL,
+  class TEXT NOT NULL,
+  status INTEGER NULL,
+  message TEXT NULL,
+  occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (crawl_id, url_hash, kind)
+);
+CREATE INDEX IF NOT EXISTS idx_failures_class_host ON fetch_failures(class, host);
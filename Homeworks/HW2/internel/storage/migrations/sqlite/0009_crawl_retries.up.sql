@@ -0,0 +1 @@
+ALTER TABLE crawls ADD COLUMN retries INTEGER NOT NULL DEFAULT 0;
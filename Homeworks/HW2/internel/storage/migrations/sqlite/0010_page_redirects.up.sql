@@ -0,0 +1 @@
+ALTER TABLE pages ADD COLUMN redirects TEXT NULL;
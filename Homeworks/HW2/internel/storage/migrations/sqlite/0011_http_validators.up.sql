@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS http_validators (
+  url_hash TEXT NOT NULL PRIMARY KEY,
+  url TEXT NOT NULL,
+  etag TEXT NULL,
+  last_modified TEXT NULL,
+  checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE crawls ADD COLUMN unchanged INTEGER NOT NULL DEFAULT 0;
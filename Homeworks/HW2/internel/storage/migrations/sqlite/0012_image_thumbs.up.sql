@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS image_thumbs (
+  image_id INTEGER NOT NULL REFERENCES images(id) ON DELETE CASCADE,
+  profile TEXT NOT NULL,
+  width INTEGER NOT NULL,
+  height INTEGER NOT NULL,
+  mime TEXT NOT NULL,
+  path TEXT NULL,
+  data BLOB NULL,
+  PRIMARY KEY (image_id, profile)
+);
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS image_colors;
+DROP INDEX IF EXISTS idx_frames;
+DROP INDEX IF EXISTS idx_gps_lat;
+DROP INDEX IF EXISTS idx_taken_at;
+ALTER TABLE images DROP COLUMN bytes;
+ALTER TABLE images DROP COLUMN bit_depth;
+ALTER TABLE images DROP COLUMN frames;
+ALTER TABLE images DROP COLUMN icc_profile;
+ALTER TABLE images DROP COLUMN camera_make;
+ALTER TABLE images DROP COLUMN camera_model;
+ALTER TABLE images DROP COLUMN lens;
+ALTER TABLE images DROP COLUMN taken_at;
+ALTER TABLE images DROP COLUMN orientation;
+ALTER TABLE images DROP COLUMN gps_lat;
+ALTER TABLE images DROP COLUMN gps_lon;
@@ -0,0 +1,24 @@
+ALTER TABLE images ADD COLUMN bytes INTEGER NULL;
+ALTER TABLE images ADD COLUMN bit_depth INTEGER NULL;
+ALTER TABLE images ADD COLUMN frames INTEGER NULL;
+ALTER TABLE images ADD COLUMN icc_profile TEXT NULL;
+ALTER TABLE images ADD COLUMN camera_make TEXT NULL;
+ALTER TABLE images ADD COLUMN camera_model TEXT NULL;
+ALTER TABLE images ADD COLUMN lens TEXT NULL;
+ALTER TABLE images ADD COLUMN taken_at TIMESTAMP NULL;
+ALTER TABLE images ADD COLUMN orientation INTEGER NULL;
+ALTER TABLE images ADD COLUMN gps_lat REAL NULL;
+ALTER TABLE images ADD COLUMN gps_lon REAL NULL;
+CREATE INDEX IF NOT EXISTS idx_frames ON images(frames);
+CREATE INDEX IF NOT EXISTS idx_gps_lat ON images(gps_lat);
+CREATE INDEX IF NOT EXISTS idx_taken_at ON images(taken_at);
+CREATE TABLE IF NOT EXISTS image_colors (
+  image_id INTEGER NOT NULL REFERENCES images(id) ON DELETE CASCADE,
+  seq INTEGER NOT NULL,
+  r INTEGER NOT NULL,
+  g INTEGER NOT NULL,
+  b INTEGER NOT NULL,
+  share REAL NOT NULL,
+  PRIMARY KEY (image_id, seq)
+);
+CREATE INDEX IF NOT EXISTS idx_image_colors_r ON image_colors(r);
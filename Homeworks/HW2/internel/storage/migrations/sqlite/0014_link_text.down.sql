@@ -0,0 +1,4 @@
+ALTER TABLE links DROP COLUMN alt;
+ALTER TABLE links DROP COLUMN title;
+ALTER TABLE links DROP COLUMN caption;
+ALTER TABLE links DROP COLUMN page_title;
@@ -0,0 +1,4 @@
+ALTER TABLE links ADD COLUMN alt TEXT NULL;
+ALTER TABLE links ADD COLUMN title TEXT NULL;
+ALTER TABLE links ADD COLUMN caption TEXT NULL;
+ALTER TABLE links ADD COLUMN page_title TEXT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_color_key_share;
+CREATE INDEX IF NOT EXISTS idx_color_key ON images(color_key);
+ALTER TABLE images DROP COLUMN color_share;
@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Link kinds stored in the links table.
+const (
+	LinkPage     = "page"
+	LinkImage    = "image"
+	LinkResource = "resource"
+)
+
+// PageInsert is one fetched URL (page, stylesheet, script or sitemap) and
+// its outgoing edges.
+type PageInsert struct {
+	URL         string
+	FinalURL    string
+	Status      int
+	ContentType string
+	Depth       int
+	Kind        string
+	Rendered    bool
+	FetchedAt   time.Time
+	Error       string
+	CrawlID     string
+	// Redirects are the hops that led from URL to FinalURL.
+	Redirects []Redirect
+	Links     []Link
+}
+
+// Redirect is one hop of a redirect chain: URL answered with Status.
+type Redirect struct {
+	URL    string
+	Status int
+}
+
+type Link struct {
+	URL  string
+	Kind string // LinkPage, LinkImage or LinkResource
+	// Alt, Title, Caption and PageTitle are the text an image was found
+	// with, so that a page replayed from its stored links (see
+	// crawl.recrawl) still passes it on. Empty for other kinds.
+	Alt       string
+	Title     string
+	Caption   string
+	PageTitle string
+}
+
+type PageRecord struct {
+	ID          uint64
+	URL         string
+	FinalURL    sql.NullString
+	Status      sql.NullInt64
+	ContentType sql.NullString
+	Depth       int
+	Kind        string
+	Rendered    bool
+	FetchedAt   time.Time
+	Error       sql.NullString
+	CrawlID     sql.NullString
+	OutLinks    int
+	Redirects   []Redirect
+}
+
+// linkBatch is the number of links inserted per statement.
+const linkBatch = 200
+
+var pageColumns = []string{
+	"url", "url_hash", "final_url", "status", "content_type", "depth", "kind",
+	"rendered", "fetched_at", "error", "crawl_id", "out_links", "redirects",
+}
+
+const pageSelect = `SELECT pages.id, pages.url, pages.final_url, pages.status, pages.content_type, pages.depth, pages.kind,
+  pages.rendered, pages.fetched_at, pages.error, pages.crawl_id, pages.out_links, pages.redirects FROM pages`
+
+// urlHash keys URLs in unique indexes; MySQL cannot index full TEXT columns.
+func urlHash(u string) string {
+	h := sha256.Sum256([]byte(u))
+	return hex.EncodeToString(h[:])
+}
+
+// SavePage stores the latest fetch of p.URL and replaces its outgoing links.
+// A failed fetch also drops the URL's validators: its stored links are gone,
+// so the next crawl has to fetch it in full instead of revalidating.
+func (r *sqlStore) SavePage(ctx context.Context, p PageInsert) error {
+	links := dedupeLinks(p.Links)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	hash := urlHash(p.URL)
+	_, err = tx.ExecContext(ctx, r.dialect.upsert("pages", pageColumns, []string{"url_hash"}, false),
+		p.URL, hash, nullIfEmpty(p.FinalURL), nullIntIfZero(p.Status), nullIfEmpty(p.ContentType), p.Depth, p.Kind,
+		p.Rendered, p.FetchedAt.UTC(), nullIfEmpty(p.Error), nullIfEmpty(p.CrawlID), len(links),
+		nullIfEmpty(formatRedirects(p.Redirects)))
+	if err != nil {
+		return err
+	}
+	var id uint64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM pages WHERE url_hash = ?`, hash).Scan(&id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM links WHERE from_page = ?`, id); err != nil {
+		return err
+	}
+	for start := 0; start < len(links); start += linkBatch {
+		batch := links[start:min(start+linkBatch, len(links))]
+		q := "INSERT INTO links (from_page, to_url, to_hash, kind, alt, title, caption, page_title) VALUES "
+		args := make([]any, 0, 8*len(batch))
+		for i, l := range batch {
+			if i > 0 {
+				q += ", "
+			}
+			q += "(?, ?, ?, ?, ?, ?, ?, ?)"
+			args = append(args, id, l.URL, urlHash(l.URL), l.Kind,
+				nullIfEmpty(l.Alt), nullIfEmpty(l.Title), nullIfEmpty(l.Caption), nullIfEmpty(l.PageTitle))
+		}
+		if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+			return err
+		}
+	}
+	if p.Error != "" {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM http_validators WHERE url_hash = ?`, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// dedupeLinks keeps the first link per (URL, Kind), the links table's key.
+func dedupeLinks(in []Link) []Link {
+	type key struct{ url, kind string }
+	seen := make(map[key]struct{}, len(in))
+	out := make([]Link, 0, len(in))
+	for _, l := range in {
+		if l.URL == "" {
+			continue
+		}
+		k := key{l.URL, l.Kind}
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		out = append(out, l)
+	}
+	return out
+}
+
+func (r *sqlStore) GetPage(ctx context.Context, url string) (PageRecord, error) {
+	return scanPage(r.db.QueryRowContext(ctx, pageSelect+` WHERE pages.url_hash = ?`, urlHash(url)))
+}
+
+// PagesLinkingTo returns pages with a LinkPage edge to url.
+func (r *sqlStore) PagesLinkingTo(ctx context.Context, url string, limit int) ([]PageRecord, error) {
+	return r.pagesWithEdge(ctx, url, LinkPage, limit)
+}
+
+// PagesUsingImage returns pages with a LinkImage edge to imageURL.
+func (r *sqlStore) PagesUsingImage(ctx context.Context, imageURL string, limit int) ([]PageRecord, error) {
+	return r.pagesWithEdge(ctx, imageURL, LinkImage, limit)
+}
+
+func (r *sqlStore) pagesWithEdge(ctx context.Context, to, kind string, limit int) ([]PageRecord, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := r.db.QueryContext(ctx, pageSelect+`
+JOIN links ON links.from_page = pages.id
+WHERE links.to_hash = ? AND links.kind = ?
+ORDER BY pages.url
+LIMIT ?`, urlHash(to), kind, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PageRecord
+	for rows.Next() {
+		p, err := scanPage(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// PageLinks returns the outgoing edges of page id.
+func (r *sqlStore) PageLinks(ctx context.Context, id uint64, limit int) ([]Link, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT to_url, kind, alt, title, caption, page_title FROM links WHERE from_page = ? ORDER BY kind, to_url LIMIT ?`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Link
+	for rows.Next() {
+		var (
+			l                              Link
+			alt, title, caption, pageTitle sql.NullString
+		)
+		if err := rows.Scan(&l.URL, &l.Kind, &alt, &title, &caption, &pageTitle); err != nil {
+			return nil, err
+		}
+		l.Alt, l.Title, l.Caption, l.PageTitle = alt.String, title.String, caption.String, pageTitle.String
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+func scanPage(row rowScanner) (PageRecord, error) {
+	var (
+		p         PageRecord
+		redirects sql.NullString
+	)
+	err := row.Scan(&p.ID, &p.URL, &p.FinalURL, &p.Status, &p.ContentType, &p.Depth, &p.Kind,
+		&p.Rendered, &p.FetchedAt, &p.Error, &p.CrawlID, &p.OutLinks, &redirects)
+	p.Redirects = parseRedirects(redirects.String)
+	return p, err
+}
+
+// formatRedirects stores a chain as one "<status> <url>" line per hop.
+func formatRedirects(rs []Redirect) string {
+	var b strings.Builder
+	for i, r := range rs {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(strconv.Itoa(r.Status))
+		b.WriteByte(' ')
+		b.WriteString(r.URL)
+	}
+	return b.String()
+}
+
+func parseRedirects(s string) []Redirect {
+	var out []Redirect
+	for _, line := range strings.Split(s, "\n") {
+		status, u, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		code, _ := strconv.Atoi(status)
+		out = append(out, Redirect{URL: u, Status: code})
+	}
+	return out
+}
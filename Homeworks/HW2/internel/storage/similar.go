@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"math/bits"
+	"slices"
+	"sort"
+)
+
+const (
+	// histBins is the length of a stored color histogram; color_key holds
+	// the index of its largest bin.
+	histBins = 64
+	// similarKeys is how many of the query's largest histogram bins are
+	// probed through the (color_key, color_share) index.
+	similarKeys = 3
+	// similarBucketCandidates caps the rows read from each probed color
+	// bucket on either side of the query's share, so the cost stays flat
+	// however large the table grows. DHash band matches are not capped.
+	similarBucketCandidates = 1000
+	// SimilarMaxDistance drops candidates that only share an index bucket.
+	SimilarMaxDistance = 0.4
+)
+
+// Similar finds neighbours of image id in two steps. Candidates come from the
+// indexed columns only: every row sharing a 16-bit DHash band (near in shape),
+// and the rows whose dominant color is one of the query's top colors, in a
+// share closest to the query's. They are then ranked by the mean of the DHash
+// Hamming distance (32 bits apart counts as unrelated) and the L1 distance
+// between color histograms.
+func (r *sqlStore) Similar(ctx context.Context, id uint64, limit int) ([]SimilarImage, error) {
+	if limit <= 0 {
+		limit = 12
+	}
+	var (
+		ph    sql.NullInt64
+		group sql.NullInt64
+		hist  []byte
+	)
+	err := r.db.QueryRowContext(ctx,
+		`SELECT phash, dup_group, color_hist FROM images WHERE id = ?`, id).Scan(&ph, &group, &hist)
+	if err != nil {
+		return nil, err
+	}
+	if len(hist) != histBins {
+		hist = nil
+	}
+
+	// Rows of the query's own near-duplicate group are left out.
+	exclude := ` AND id <> ?`
+	excludeArgs := []any{id}
+	if group.Valid {
+		exclude += ` AND (dup_group IS NULL OR dup_group <> ?)`
+		excludeArgs = append(excludeArgs, group.Int64)
+	}
+	cands := map[uint64]similarCandidate{}
+	if ph.Valid {
+		h := uint64(ph.Int64)
+		args := append([]any{phashBand(h, 0), phashBand(h, 1), phashBand(h, 2), phashBand(h, 3)}, excludeArgs...)
+		err := r.similarCandidates(ctx, cands, `SELECT id, phash, color_hist FROM images
+WHERE (phash_b0 = ? OR phash_b1 = ? OR phash_b2 = ? OR phash_b3 = ?)`+exclude, args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if hist != nil {
+		// A row whose largest bin is k differs from the query by at least
+		// |hist[k] - color_share| in that bin, so each bucket is read outward
+		// from the query's own share.
+		for _, k := range topBins(hist, similarKeys) {
+			for _, q := range []string{
+				`color_share >= ?` + exclude + ` ORDER BY color_share, id LIMIT ?`,
+				`color_share < ?` + exclude + ` ORDER BY color_share DESC, id LIMIT ?`,
+			} {
+				args := append([]any{k, int(hist[k])}, excludeArgs...)
+				args = append(args, similarBucketCandidates)
+				err := r.similarCandidates(ctx, cands,
+					`SELECT id, phash, color_hist FROM images WHERE color_key = ? AND `+q, args...)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	type scored struct {
+		id   uint64
+		dist float64
+	}
+	var ranked []scored
+	for cid, c := range cands {
+		var parts []float64
+		if ph.Valid && c.phash.Valid {
+			parts = append(parts, min(float64(bits.OnesCount64(uint64(ph.Int64^c.phash.Int64)))/32, 1))
+		}
+		if hist != nil && len(c.hist) == histBins {
+			parts = append(parts, histDistance(hist, c.hist))
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		d := 0.0
+		for _, p := range parts {
+			d += p
+		}
+		d /= float64(len(parts))
+		if d <= SimilarMaxDistance {
+			ranked = append(ranked, scored{cid, d})
+		}
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].dist != ranked[j].dist {
+			return ranked[i].dist < ranked[j].dist
+		}
+		return ranked[i].id < ranked[j].id
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	if len(ranked) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]any, len(ranked))
+	for i, s := range ranked {
+		ids[i] = s.id
+	}
+	recs, err := r.db.QueryContext(ctx,
+		`SELECT `+imageColumns+` FROM images WHERE id IN (`+placeholders(len(ids))+`)`, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer recs.Close()
+	byID := make(map[uint64]ImageRecord, len(ranked))
+	for recs.Next() {
+		rec, err := scanImage(recs)
+		if err != nil {
+			return nil, err
+		}
+		byID[rec.ID] = rec
+	}
+	if err := recs.Err(); err != nil {
+		return nil, err
+	}
+	recs.Close()
+
+	found := make([]ImageRecord, 0, len(ranked))
+	dist := make([]float64, 0, len(ranked))
+	for _, s := range ranked {
+		if rec, ok := byID[s.id]; ok {
+			found = append(found, rec)
+			dist = append(dist, s.dist)
+		}
+	}
+	if err := r.loadDetails(ctx, found); err != nil {
+		return nil, err
+	}
+	out := make([]SimilarImage, len(found))
+	for i, rec := range found {
+		out[i] = SimilarImage{ImageRecord: rec, Distance: dist[i]}
+	}
+	return out, nil
+}
+
+type similarCandidate struct {
+	phash sql.NullInt64
+	hist  []byte
+}
+
+// similarCandidates adds the (id, phash, color_hist) rows of q to cands.
+func (r *sqlStore) similarCandidates(ctx context.Context, cands map[uint64]similarCandidate, q string, args ...any) error {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id uint64
+			c  similarCandidate
+		)
+		if err := rows.Scan(&id, &c.phash, &c.hist); err != nil {
+			return err
+		}
+		cands[id] = c
+	}
+	return rows.Err()
+}
+
+// topBins returns the indexes of the n largest non-empty histogram bins,
+// largest first. The largest bin is always returned.
+func topBins(hist []byte, n int) []int {
+	idx := make([]int, len(hist))
+	for i := range idx {
+		idx[i] = i
+	}
+	slices.SortStableFunc(idx, func(a, b int) int { return int(hist[b]) - int(hist[a]) })
+	k := 1
+	for k < n && k < len(idx) && hist[idx[k]] > 0 {
+		k++
+	}
+	return idx[:k]
+}
+
+// histDistance is the L1 distance between two histograms scaled to 0..1.
+func histDistance(a, b []byte) float64 {
+	sum := 0
+	for i := range a {
+		d := int(a[i]) - int(b[i])
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+	}
+	return float64(sum) / (2 * 255)
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteRepository is an embedded, pure-Go Repository for local development and CI.
+type SQLiteRepository struct {
+	sqlStore
+}
+
+var sqliteDialect = dialect{
+	name: "sqlite",
+	upsert: func(table string, cols, key []string, keepExisting bool) string {
+		var set []string
+		for _, c := range nonKey(cols, key) {
+			if keepExisting {
+				set = append(set, fmt.Sprintf("%s = COALESCE(%s.%s, excluded.%s)", c, table, c, c))
+			} else {
+				set = append(set, fmt.Sprintf("%s = excluded.%s", c, c))
+			}
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT(%s) DO UPDATE SET %s",
+			table, strings.Join(cols, ", "), placeholders(len(cols)), strings.Join(key, ", "), strings.Join(set, ", "))
+	},
+	fullText: func(terms []string) fullTextQuery {
+		// FTS5 over the images_fts index from migration 0005. Every term is a
+		// quoted prefix query and any term may match; bm25 weighs alt, title
+		// and caption above filename, page URL and page title.
+		quoted := make([]string, len(terms))
+		for i, t := range terms {
+			quoted[i] = `"` + t + `"*`
+		}
+		match := strings.Join(quoted, " OR ")
+		return fullTextQuery{
+			where:     "images.id IN (SELECT rowid FROM images_fts WHERE images_fts MATCH ?)",
+			whereArgs: []any{match},
+			join: `JOIN (SELECT rowid AS fts_id, bm25(images_fts, 3.0, 3.0, 2.0, 1.0, 3.0, 1.0) AS fts_rank
+  FROM images_fts WHERE images_fts MATCH ?) fts ON fts.fts_id = images.id`,
+			joinArgs: []any{match},
+			rank:     "fts.fts_rank",
+		}
+	},
+	tableExists:      `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`,
+	transactionalDDL: true,
+}
+
+// OpenSQLite opens (creating if needed) the database file at path;
+// ":memory:" gives a throwaway in-memory database.
+func OpenSQLite(path string) (*SQLiteRepository, error) {
+	dsn := path
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	dsn += sep + "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection also keeps ":memory:" databases shared.
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r := &SQLiteRepository{sqlStore{db: db, dialect: sqliteDialect}}
+	if err := r.checkSchema(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return r, nil
+}
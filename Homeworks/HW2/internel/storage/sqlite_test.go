@@ -0,0 +1,674 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestSQLite(t *testing.T) Repository {
+	t.Helper()
+	repo, err := Open("sqlite::memory:")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = repo.Close() })
+	if _, err := repo.MigrateUp(context.Background()); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	return repo
+}
+
+func TestSQLite_InsertMergesDuplicates(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	first := ImageInsert{URL: "https://x/a.png", PageURL: "https://x/", Alt: "first", Format: "png"}
+	if err := repo.InsertImage(ctx, first); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	// Same (url, page_url): keeps first-seen alt, fills in missing fields.
+	second := ImageInsert{URL: "https://x/a.png", PageURL: "https://x/", Alt: "second", Width: 10, Height: 20,
+		ThumbMIME: "image/jpeg", ThumbBlob: []byte{1, 2, 3}}
+	if err := repo.InsertImage(ctx, second); err != nil {
+		t.Fatalf("insert dup: %v", err)
+	}
+
+	items, total, err := repo.Search(ctx, SearchParams{})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if total != 1 || len(items) != 1 {
+		t.Fatalf("total=%d items=%d, want 1", total, len(items))
+	}
+	rec := items[0]
+	if rec.Alt.String != "first" || rec.Width.Int64 != 10 || rec.Height.Int64 != 20 || rec.Format.String != "png" {
+		t.Fatalf("unexpected merged record: %+v", rec)
+	}
+	if rec.CreatedAt.IsZero() {
+		t.Fatalf("created_at not scanned")
+	}
+
+	mime, blob, err := repo.GetThumb(ctx, rec.ID, "")
+	if err != nil || mime != "image/jpeg" || len(blob) != 3 {
+		t.Fatalf("GetThumb = %q %v %v", mime, blob, err)
+	}
+	got, err := repo.GetImage(ctx, rec.ID)
+	if err != nil || got.URL != first.URL {
+		t.Fatalf("GetImage = %+v %v", got, err)
+	}
+}
+
+func TestSQLite_SearchFilters(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	for _, in := range []ImageInsert{
+		{URL: "https://x/logo.svg", PageURL: "https://x/", Filename: "logo.svg", Format: "svg", Width: 100, Height: 50},
+		{URL: "https://x/cat.jpg", PageURL: "https://x/pets", Filename: "cat.jpg", Format: "jpeg", Width: 800, Height: 600},
+		{URL: "https://x/dog.jpg", PageURL: "https://x/pets", Filename: "dog.jpg", Format: "jpeg", Width: 300, Height: 200},
+	} {
+		if err := repo.InsertImage(ctx, in); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	minW := 250
+	items, total, err := repo.Search(ctx, SearchParams{FormatEquals: "jpeg", MinWidth: &minW, PageURLContains: "pets"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if total != 2 || len(items) != 2 {
+		t.Fatalf("total=%d, want 2", total)
+	}
+
+	items, total, err = repo.Search(ctx, SearchParams{FilenameContains: "cat", PageSize: 1})
+	if err != nil || total != 1 || items[0].Filename.String != "cat.jpg" {
+		t.Fatalf("filename search: %v %d %v", items, total, err)
+	}
+
+	items, _, err = repo.Search(ctx, SearchParams{Sort: "-width"})
+	if err != nil || len(items) != 3 || items[0].Filename.String != "cat.jpg" || items[2].Filename.String != "logo.svg" {
+		t.Fatalf("sort -width: %v %v", items, err)
+	}
+	if _, _, err := repo.Search(ctx, SearchParams{Sort: "thumb_blob"}); !errors.Is(err, ErrInvalidSort) {
+		t.Fatalf("bad sort err = %v, want ErrInvalidSort", err)
+	}
+}
+
+func TestSQLite_NearDuplicateGroups(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	const h = 0xF0F0_1234_ABCD_0001
+	for _, in := range []ImageInsert{
+		{URL: "https://x/big.jpg", PageURL: "https://x/", PHash: h, HasPHash: true},
+		{URL: "https://cdn/small.jpg?w=100", PageURL: "https://x/", PHash: h ^ 0b101, HasPHash: true}, // 2 bits apart
+		{URL: "https://x/big.jpg", PageURL: "https://x/other"},                                        // same URL elsewhere
+		{URL: "https://x/unrelated.jpg", PageURL: "https://x/", PHash: ^uint64(h), HasPHash: true},
+	} {
+		if err := repo.InsertImage(ctx, in); err != nil {
+			t.Fatalf("insert %s: %v", in.URL, err)
+		}
+	}
+
+	all, total, err := repo.Search(ctx, SearchParams{})
+	if err != nil || total != 4 {
+		t.Fatalf("search: %d %v", total, err)
+	}
+	groups := map[string]int64{}
+	counts := map[string]int{}
+	for _, rec := range all {
+		groups[rec.URL+" "+rec.PageURL] = rec.DupGroup.Int64
+		counts[rec.URL+" "+rec.PageURL] = rec.DupCount
+	}
+	g := groups["https://x/big.jpg https://x/"]
+	if g == 0 || groups["https://cdn/small.jpg?w=100 https://x/"] != g || groups["https://x/big.jpg https://x/other"] != g {
+		t.Fatalf("expected the three copies in one group: %v", groups)
+	}
+	if groups["https://x/unrelated.jpg https://x/"] == g {
+		t.Fatalf("unrelated image joined the group: %v", groups)
+	}
+	if counts["https://x/big.jpg https://x/"] != 2 || counts["https://x/unrelated.jpg https://x/"] != 0 {
+		t.Fatalf("unexpected dup counts: %v", counts)
+	}
+
+	_, total, err = repo.Search(ctx, SearchParams{CollapseDuplicates: true})
+	if err != nil || total != 2 {
+		t.Fatalf("collapsed total = %d %v, want 2", total, err)
+	}
+	_, total, err = repo.Search(ctx, SearchParams{DupGroup: uint64(g)})
+	if err != nil || total != 3 {
+		t.Fatalf("group total = %d %v, want 3", total, err)
+	}
+}
+
+func TestSQLite_Similar(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	hist := func(bins map[int]byte) []byte {
+		h := make([]byte, histBins)
+		for i, v := range bins {
+			h[i] = v
+		}
+		return h
+	}
+	reddish := hist(map[int]byte{48: 200, 52: 55})
+	const h = 0xF0F0_1234_ABCD_0001
+	for _, in := range []ImageInsert{
+		{URL: "https://x/query.jpg", PageURL: "https://x/", PHash: h, HasPHash: true, ColorHist: reddish},
+		{URL: "https://x/copy.jpg", PageURL: "https://x/", PHash: h ^ 1, HasPHash: true, ColorHist: reddish},
+		{URL: "https://x/near.jpg", PageURL: "https://x/", PHash: h ^ 0xFF, HasPHash: true, ColorHist: hist(map[int]byte{48: 190, 52: 65})},
+		{URL: "https://x/red-square.png", PageURL: "https://x/", ColorHist: hist(map[int]byte{48: 255})},
+		{URL: "https://x/blue.jpg", PageURL: "https://x/", PHash: ^uint64(h), HasPHash: true, ColorHist: hist(map[int]byte{3: 255})},
+	} {
+		if err := repo.InsertImage(ctx, in); err != nil {
+			t.Fatalf("insert %s: %v", in.URL, err)
+		}
+	}
+	items, _, err := repo.Search(ctx, SearchParams{URLContains: "query"})
+	if err != nil || len(items) != 1 {
+		t.Fatalf("search: %v %v", items, err)
+	}
+
+	sim, err := repo.Similar(ctx, items[0].ID, 10)
+	if err != nil {
+		t.Fatalf("Similar: %v", err)
+	}
+	var got []string
+	for _, s := range sim {
+		got = append(got, s.URL)
+	}
+	want := []string{"https://x/near.jpg", "https://x/red-square.png"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("Similar = %v, want %v", got, want)
+	}
+	if sim[0].Distance <= 0 || sim[0].Distance > sim[1].Distance {
+		t.Fatalf("distances not ascending: %v %v", sim[0].Distance, sim[1].Distance)
+	}
+}
+
+// TestSQLite_SimilarCrowdedBucket fills the query's dominant color bucket
+// with more unrelated images than Similar reads from it.
+func TestSQLite_SimilarCrowdedBucket(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	hist := func(bins map[int]byte) []byte {
+		h := make([]byte, histBins)
+		for i, v := range bins {
+			h[i] = v
+		}
+		return h
+	}
+	for i := range similarBucketCandidates + 10 {
+		in := ImageInsert{URL: fmt.Sprintf("https://x/decoy-%d.jpg", i), PageURL: "https://x/",
+			ColorHist: hist(map[int]byte{48: 130, 0: 125})}
+		if err := repo.InsertImage(ctx, in); err != nil {
+			t.Fatalf("insert %s: %v", in.URL, err)
+		}
+	}
+	const h = 0xF0F0_1234_ABCD_0001
+	for _, in := range []ImageInsert{
+		{URL: "https://x/query.jpg", PageURL: "https://x/", PHash: h, HasPHash: true, ColorHist: hist(map[int]byte{48: 200, 52: 55})},
+		// Same shape, but a smaller share of the bucket than every decoy.
+		{URL: "https://x/recolored.jpg", PageURL: "https://x/", PHash: h ^ 0xFF, HasPHash: true,
+			ColorHist: hist(map[int]byte{48: 120, 52: 55, 0: 80})},
+		// No DHash; only reachable through its color.
+		{URL: "https://x/red.gif", PageURL: "https://x/", ColorHist: hist(map[int]byte{48: 195, 52: 60})},
+	} {
+		if err := repo.InsertImage(ctx, in); err != nil {
+			t.Fatalf("insert %s: %v", in.URL, err)
+		}
+	}
+	items, _, err := repo.Search(ctx, SearchParams{URLContains: "query"})
+	if err != nil || len(items) != 1 {
+		t.Fatalf("search: %v %v", items, err)
+	}
+
+	sim, err := repo.Similar(ctx, items[0].ID, 10)
+	if err != nil {
+		t.Fatalf("Similar: %v", err)
+	}
+	var got []string
+	for _, s := range sim {
+		got = append(got, s.URL)
+	}
+	want := []string{"https://x/red.gif", "https://x/recolored.jpg"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("Similar = %v, want %v", got, want)
+	}
+}
+
+func TestSQLite_FullTextRelevance(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	for _, in := range []ImageInsert{
+		{URL: "https://x/1.jpg", PageURL: "https://x/blog", Alt: "red bicycle", Caption: "A red bicycle leaning on a red wall", Format: "jpeg"},
+		{URL: "https://x/2.jpg", PageURL: "https://x/blog", Alt: "a wall", PageTitle: "Bicycles we love", Format: "jpeg"},
+		{URL: "https://x/3.png", PageURL: "https://x/red", Filename: "redlogo.png", Format: "png"},
+		{URL: "https://x/4.jpg", PageURL: "https://x/cats", Alt: "sleeping cat", Format: "jpeg"},
+	} {
+		if err := repo.InsertImage(ctx, in); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	items, total, err := repo.Search(ctx, SearchParams{Q: "Red bicycle!"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if total != 3 || items[0].URL != "https://x/1.jpg" {
+		t.Fatalf("total=%d first=%v", total, items)
+	}
+
+	// Field filters still apply on top of the text query.
+	items, total, err = repo.Search(ctx, SearchParams{Q: "red bicycle", FormatEquals: "png"})
+	if err != nil || total != 1 || items[0].Filename.String != "redlogo.png" {
+		t.Fatalf("filtered: %d %v %v", total, items, err)
+	}
+
+	// An explicit sort overrides relevance.
+	items, _, err = repo.Search(ctx, SearchParams{Q: "bicycle", Sort: "id"})
+	if err != nil || len(items) != 2 || items[0].URL != "https://x/1.jpg" {
+		t.Fatalf("sorted: %v %v", items, err)
+	}
+
+	if _, total, err = repo.Search(ctx, SearchParams{Q: `"*)(`}); err != nil || total != 0 {
+		t.Fatalf("punctuation only: %d %v", total, err)
+	}
+
+	// Updates keep the index in sync.
+	if err := repo.InsertImage(ctx, ImageInsert{URL: "https://x/4.jpg", PageURL: "https://x/cats", Caption: "tabby"}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, total, err = repo.Search(ctx, SearchParams{Q: "tabby"}); err != nil || total != 1 {
+		t.Fatalf("after update: %d %v", total, err)
+	}
+}
+
+func TestSQLite_CrawlSessions(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	start := time.Now().Add(-time.Minute)
+	if err := repo.StartCrawl(ctx, CrawlStart{ID: "c1", Seeds: []string{"https://x/", "https://y/"}, Config: `{"Workers":8}`, StartedAt: start}); err != nil {
+		t.Fatalf("StartCrawl: %v", err)
+	}
+	for _, in := range []ImageInsert{
+		{URL: "https://x/a.png", PageURL: "https://x/", CrawlID: "c1"},
+		{URL: "https://x/b.png", PageURL: "https://x/", CrawlID: "c2"},
+		{URL: "https://x/a.png", PageURL: "https://x/", CrawlID: "c2"}, // re-seen: keeps c1
+	} {
+		if err := repo.InsertImage(ctx, in); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	items, total, err := repo.Search(ctx, SearchParams{CrawlID: "c1"})
+	if err != nil || total != 1 || items[0].CrawlID.String != "c1" {
+		t.Fatalf("crawl filter: %d %v %v", total, items, err)
+	}
+
+	st := CrawlStats{PagesProcessed: 3, ImagesStored: 1, FetchErrors: 2, RobotsSkipped: 1, Retries: 4, Unchanged: 5}
+	if err := repo.FinishCrawl(ctx, "c1", "timeout", st); err != nil {
+		t.Fatalf("FinishCrawl: %v", err)
+	}
+	c, err := repo.GetCrawl(ctx, "c1")
+	if err != nil {
+		t.Fatalf("GetCrawl: %v", err)
+	}
+	if c.Status != CrawlFinished || c.StopReason.String != "timeout" || !c.FinishedAt.Valid || c.CrawlStats != st ||
+		len(c.Seeds) != 2 || c.Runs != 1 || c.StartedAt.Unix() != start.Unix() {
+		t.Fatalf("unexpected crawl: %+v", c)
+	}
+
+	// Resuming reopens the same session.
+	if err := repo.StartCrawl(ctx, CrawlStart{ID: "c1", Seeds: []string{"https://x/"}, Config: "{}", StartedAt: time.Now()}); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	if err := repo.StartCrawl(ctx, CrawlStart{ID: "c2", Config: "{}", StartedAt: time.Now()}); err != nil {
+		t.Fatalf("StartCrawl c2: %v", err)
+	}
+	list, err := repo.ListCrawls(ctx, 10)
+	if err != nil || len(list) != 2 || list[0].ID != "c2" {
+		t.Fatalf("ListCrawls: %v %v", list, err)
+	}
+	if c := list[1]; c.Status != CrawlRunning || c.Runs != 2 || c.FinishedAt.Valid || c.StartedAt.Unix() != start.Unix() {
+		t.Fatalf("resumed crawl: %+v", c)
+	}
+}
+
+func TestSQLite_PageGraph(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	now := time.Now()
+	for _, p := range []PageInsert{
+		{URL: "https://x/", Status: 200, ContentType: "text/html", Kind: "page", FetchedAt: now, CrawlID: "c1",
+			Links: []Link{{URL: "https://x/about", Kind: LinkPage}, {URL: "https://x/logo.png", Kind: LinkImage}, {URL: "https://x/about", Kind: LinkPage}}},
+		{URL: "https://x/blog", FinalURL: "https://x/blog/", Status: 200, Kind: "page", Depth: 1, Rendered: true, FetchedAt: now,
+			Redirects: []Redirect{{"https://x/blog", 301}},
+			Links: []Link{{URL: "https://x/about", Kind: LinkPage}, {URL: "https://x/app.css", Kind: LinkResource},
+				{URL: "https://x/logo.png", Kind: LinkImage, Alt: "Logo", Caption: "Our logo", PageTitle: "Blog"},
+				{URL: "https://x/logo.png", Kind: LinkImage, Alt: "second use"}}},
+		{URL: "https://x/about", Status: 500, Kind: "page", Depth: 1, FetchedAt: now, Error: "boom"},
+	} {
+		if err := repo.SavePage(ctx, p); err != nil {
+			t.Fatalf("SavePage %s: %v", p.URL, err)
+		}
+	}
+
+	from, err := repo.PagesLinkingTo(ctx, "https://x/about", 10)
+	if err != nil || len(from) != 2 || from[0].URL != "https://x/" || from[1].FinalURL.String != "https://x/blog/" || !from[1].Rendered ||
+		len(from[1].Redirects) != 1 || from[1].Redirects[0] != (Redirect{"https://x/blog", 301}) || from[0].Redirects != nil {
+		t.Fatalf("PagesLinkingTo = %+v %v", from, err)
+	}
+	using, err := repo.PagesUsingImage(ctx, "https://x/logo.png", 10)
+	if err != nil || len(using) != 2 {
+		t.Fatalf("PagesUsingImage = %+v %v", using, err)
+	}
+
+	// Image edges keep the text of the image's first use on the page.
+	blog, err := repo.GetPage(ctx, "https://x/blog")
+	if err != nil || blog.OutLinks != 3 {
+		t.Fatalf("GetPage blog = %+v %v", blog, err)
+	}
+	blogLinks, err := repo.PageLinks(ctx, blog.ID, 10)
+	if err != nil || len(blogLinks) != 3 || blogLinks[0] != (Link{URL: "https://x/logo.png", Kind: LinkImage,
+		Alt: "Logo", Caption: "Our logo", PageTitle: "Blog"}) || blogLinks[1].Alt != "" {
+		t.Fatalf("PageLinks blog = %+v %v", blogLinks, err)
+	}
+
+	home, err := repo.GetPage(ctx, "https://x/")
+	if err != nil || home.OutLinks != 2 || home.Status.Int64 != 200 || home.CrawlID.String != "c1" {
+		t.Fatalf("GetPage = %+v %v", home, err)
+	}
+
+	// Refetching replaces the outgoing links.
+	if err := repo.SavePage(ctx, PageInsert{URL: "https://x/", Status: 200, Kind: "page", FetchedAt: now,
+		Links: []Link{{URL: "https://x/contact", Kind: LinkPage}}}); err != nil {
+		t.Fatalf("resave: %v", err)
+	}
+	links, err := repo.PageLinks(ctx, home.ID, 10)
+	if err != nil || len(links) != 1 || links[0].URL != "https://x/contact" {
+		t.Fatalf("PageLinks = %v %v", links, err)
+	}
+	if from, _ := repo.PagesLinkingTo(ctx, "https://x/about", 10); len(from) != 1 {
+		t.Fatalf("stale edge kept: %+v", from)
+	}
+	about, err := repo.GetPage(ctx, "https://x/about")
+	if err != nil || about.Error.String != "boom" || about.Status.Int64 != 500 {
+		t.Fatalf("error page = %+v %v", about, err)
+	}
+}
+
+func TestSQLite_Failures(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	for _, f := range []FailureInsert{
+		{CrawlID: "c1", URL: "https://a.example/x", Kind: "page", Class: "http_4xx", StatusCode: 404, Message: "http status 404 Not Found"},
+		{CrawlID: "c1", URL: "https://a.example/y.png", Kind: "image", Class: "http_4xx", StatusCode: 403},
+		{CrawlID: "c1", URL: "https://b.example/", Kind: "page", Class: "dns"},
+		{CrawlID: "c2", URL: "https://a.example/x", Kind: "page", Class: "timeout"},
+		// A distinct URL, but the same host.
+		{CrawlID: "c1", URL: "https://A.example/x", Kind: "page", Class: "http_5xx", StatusCode: 503},
+		// Same URL, kind and crawl: replaces the 404.
+		{CrawlID: "c1", URL: "https://a.example/x", Kind: "page", Class: "http_5xx", StatusCode: 502},
+	} {
+		if err := repo.RecordFailure(ctx, f); err != nil {
+			t.Fatalf("RecordFailure %s: %v", f.URL, err)
+		}
+	}
+
+	counts, err := repo.FailureCounts(ctx, FailureFilter{CrawlID: "c1"})
+	if err != nil {
+		t.Fatalf("FailureCounts: %v", err)
+	}
+	got := map[string]int{}
+	for _, c := range counts {
+		got[c.Class+"@"+c.Host] = c.Count
+	}
+	want := map[string]int{"http_4xx@a.example": 1, "http_5xx@a.example": 2, "dns@b.example": 1}
+	if len(got) != len(want) {
+		t.Fatalf("counts = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("counts = %v, want %v", got, want)
+		}
+	}
+
+	list, err := repo.ListFailures(ctx, FailureFilter{Class: "http_5xx", Host: "a.example"})
+	if err != nil || len(list) != 2 {
+		t.Fatalf("ListFailures = %+v %v", list, err)
+	}
+	for _, f := range list {
+		if f.URL == "https://a.example/x" && f.StatusCode.Int64 != 502 {
+			t.Fatalf("failure not replaced: %+v", f)
+		}
+	}
+}
+
+func TestSQLite_Validators(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	if _, err := repo.GetValidators(ctx, "https://x/a.png"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("missing validators: %v", err)
+	}
+	first := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	if err := repo.SaveValidators(ctx, Validators{URL: "https://x/a.png", ETag: `"v1"`, CheckedAt: first}); err != nil {
+		t.Fatalf("SaveValidators: %v", err)
+	}
+	v, err := repo.GetValidators(ctx, "https://x/a.png")
+	if err != nil || v.ETag != `"v1"` || v.LastModified != "" || !v.CheckedAt.Equal(first) {
+		t.Fatalf("GetValidators = %+v %v", v, err)
+	}
+	lm := "Mon, 01 Jan 2024 00:00:00 GMT"
+	if err := repo.SaveValidators(ctx, Validators{URL: "https://x/a.png", LastModified: lm}); err != nil {
+		t.Fatalf("SaveValidators: %v", err)
+	}
+	if v, err := repo.GetValidators(ctx, "https://x/a.png"); err != nil || v.ETag != "" || v.LastModified != lm || !v.CheckedAt.After(first) {
+		t.Fatalf("replaced validators = %+v %v", v, err)
+	}
+}
+
+func TestSQLite_ThumbVariants(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	variant := func(profile string, w, h int, mime string, b byte) ThumbInsert {
+		return ThumbInsert{ThumbVariant: ThumbVariant{Profile: profile, Width: w, Height: h, MIME: mime}, Blob: []byte{b}}
+	}
+	in := ImageInsert{URL: "https://x/logo.png", PageURL: "https://x/", ThumbMIME: "image/png", ThumbBlob: []byte{2},
+		Thumbs: []ThumbInsert{variant("400w", 400, 100, "image/png", 4), variant("200w", 200, 50, "image/png", 2), variant("sq100", 100, 100, "image/png", 1)}}
+	if err := repo.InsertImage(ctx, in); err != nil {
+		t.Fatal(err)
+	}
+	// A re-insert keeps the stored variants and adds new ones.
+	in.Thumbs = []ThumbInsert{variant("200w", 200, 50, "image/jpeg", 9), variant("800w", 800, 200, "image/png", 8)}
+	if err := repo.InsertImage(ctx, in); err != nil {
+		t.Fatal(err)
+	}
+
+	items, _, err := repo.Search(ctx, SearchParams{})
+	if err != nil || len(items) != 1 {
+		t.Fatalf("search: %v %d", err, len(items))
+	}
+	var got []string
+	for _, v := range items[0].Thumbs {
+		got = append(got, fmt.Sprintf("%s:%dx%d:%s", v.Profile, v.Width, v.Height, v.MIME))
+	}
+	if want := "sq100:100x100:image/png 200w:200x50:image/png 400w:400x100:image/png 800w:800x200:image/png"; strings.Join(got, " ") != want {
+		t.Fatalf("variants = %v, want %s", got, want)
+	}
+	rec, err := repo.GetImage(ctx, items[0].ID)
+	if err != nil || len(rec.Thumbs) != 4 {
+		t.Fatalf("GetImage thumbs = %v %v", rec.Thumbs, err)
+	}
+
+	for size, want := range map[string]byte{"400w": 4, "sq100": 1, "": 2, "1000w": 2} {
+		mime, blob, err := repo.GetThumb(ctx, rec.ID, size)
+		if err != nil || mime != "image/png" || len(blob) != 1 || blob[0] != want {
+			t.Errorf("GetThumb(%q) = %q %v %v, want byte %d", size, mime, blob, err, want)
+		}
+	}
+}
+
+func TestSQLite_ReplaceChangedImage(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	const old, changed = 0x0123_4567_89AB_CDEF, 0xFEDC_0000_7654_3210
+	for _, in := range []ImageInsert{
+		{URL: "https://x/a.jpg", PageURL: "https://x/", Alt: "first", Width: 10, Height: 10, Format: "png",
+			PHash: old, HasPHash: true, ThumbMIME: "image/png", ThumbBlob: []byte{1}, CameraMake: "Canon",
+			Thumbs: []ThumbInsert{{ThumbVariant: ThumbVariant{Profile: "sq100", Width: 100, Height: 100, MIME: "image/png"}, Blob: []byte{1}}},
+			Colors: []ImageColor{{R: 255, Share: 0.5}, {B: 255, Share: 0.5}}},
+		{URL: "https://x/old-copy.jpg", PageURL: "https://x/", PHash: old ^ 1, HasPHash: true},
+		{URL: "https://x/new-copy.jpg", PageURL: "https://x/", PHash: changed ^ 1, HasPHash: true},
+	} {
+		if err := repo.InsertImage(ctx, in); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The picture at a.jpg changed: new size, format, hash, thumbs and colors.
+	err := repo.InsertImage(ctx, ImageInsert{URL: "https://x/a.jpg", PageURL: "https://x/", Alt: "second",
+		Caption: "added later", Width: 30, Height: 20, Format: "jpeg", PHash: changed, HasPHash: true,
+		ThumbMIME: "image/jpeg", ThumbBlob: []byte{2},
+		Thumbs:  []ThumbInsert{{ThumbVariant: ThumbVariant{Profile: "200w", Width: 200, Height: 133, MIME: "image/jpeg"}, Blob: []byte{2}}},
+		Colors:  []ImageColor{{G: 255, Share: 1}},
+		Replace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, _, err := repo.Search(ctx, SearchParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := map[string]int64{}
+	var id uint64
+	for _, rec := range items {
+		groups[rec.URL] = rec.DupGroup.Int64
+		if rec.URL == "https://x/a.jpg" {
+			id = rec.ID
+		}
+	}
+	rec, err := repo.GetImage(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Width.Int64 != 30 || rec.Format.String != "jpeg" || rec.CameraMake.Valid || uint64(rec.PHash.Int64) != changed {
+		t.Fatalf("content not replaced: %+v", rec)
+	}
+	if rec.Alt.String != "first" || rec.Caption.String != "added later" {
+		t.Fatalf("alt %q caption %q, want first-seen alt and the new caption", rec.Alt.String, rec.Caption.String)
+	}
+	if len(rec.Thumbs) != 1 || rec.Thumbs[0].Profile != "200w" || len(rec.Colors) != 1 || rec.Colors[0].Hex() != "#00ff00" {
+		t.Fatalf("thumbs %+v colors %+v", rec.Thumbs, rec.Colors)
+	}
+	if mime, blob, err := repo.GetThumb(ctx, id, ""); err != nil || mime != "image/jpeg" || blob[0] != 2 {
+		t.Fatalf("default thumb = %q %v %v", mime, blob, err)
+	}
+	if groups["https://x/a.jpg"] != groups["https://x/new-copy.jpg"] || groups["https://x/a.jpg"] == groups["https://x/old-copy.jpg"] {
+		t.Fatalf("groups %v: a.jpg should have moved to the group of new-copy.jpg", groups)
+	}
+}
+
+func TestSQLite_ImageMetadata(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+
+	taken := time.Date(2024, 5, 6, 5, 8, 9, 0, time.UTC)
+	for _, in := range []ImageInsert{
+		{URL: "https://x/photo.jpg", PageURL: "https://x/", Format: "jpeg", Bytes: 123456, BitDepth: 8, Frames: 1,
+			ICCProfile: "Display P3", CameraMake: "Canon", CameraModel: "Canon EOS R5", Lens: "RF50mm F1.8 STM",
+			TakenAt: taken, Orientation: 6, HasGPS: true, GPSLat: 52.5, GPSLon: -13.26,
+			Colors: []ImageColor{{R: 250, G: 10, B: 10, Share: 0.6}, {R: 10, G: 10, B: 250, Share: 0.4}}},
+		{URL: "https://x/anim.gif", PageURL: "https://x/", Format: "gif", Bytes: 999, BitDepth: 8, Frames: 12,
+			Colors: []ImageColor{{R: 0, G: 200, B: 0, Share: 1}}},
+		{URL: "https://x/plain.png", PageURL: "https://x/", Format: "png", Frames: 1},
+	} {
+		if err := repo.InsertImage(ctx, in); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found := func(p SearchParams) string {
+		t.Helper()
+		items, _, err := repo.Search(ctx, p)
+		if err != nil {
+			t.Fatalf("%+v: %v", p, err)
+		}
+		var names []string
+		for _, it := range items {
+			names = append(names, it.URL[len("https://x/"):])
+		}
+		return strings.Join(names, " ")
+	}
+	for _, tc := range []struct {
+		p    SearchParams
+		want string
+	}{
+		{SearchParams{HasGPS: true}, "photo.jpg"},
+		{SearchParams{Animated: true}, "anim.gif"},
+		{SearchParams{CameraContains: "eos"}, "photo.jpg"},
+		{SearchParams{Color: "#ff0000"}, "photo.jpg"},
+		{SearchParams{Color: "#0000f0"}, "photo.jpg"},
+		{SearchParams{Color: "0c0"}, "anim.gif"},
+		{SearchParams{Color: "#808080"}, ""},
+		{SearchParams{Color: "#808080", ColorDistance: 220}, "anim.gif photo.jpg"},
+		{SearchParams{Sort: "-bytes"}, "photo.jpg anim.gif plain.png"},
+	} {
+		if got := found(tc.p); got != tc.want {
+			t.Errorf("%+v: got %q, want %q", tc.p, got, tc.want)
+		}
+	}
+	if _, _, err := repo.Search(ctx, SearchParams{Color: "red"}); !errors.Is(err, ErrInvalidColor) {
+		t.Fatalf("bad color: %v", err)
+	}
+
+	items, _, err := repo.Search(ctx, SearchParams{HasGPS: true})
+	if err != nil || len(items) != 1 {
+		t.Fatalf("search: %v", err)
+	}
+	rec, err := repo.GetImage(ctx, items[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Bytes.Int64 != 123456 || rec.BitDepth.Int64 != 8 || rec.ICCProfile.String != "Display P3" || rec.Camera() != "Canon EOS R5" ||
+		rec.Lens.String != "RF50mm F1.8 STM" || !rec.TakenAt.Time.Equal(taken) || rec.Orientation.Int64 != 6 ||
+		rec.GPSLat.Float64 != 52.5 || rec.GPSLon.Float64 != -13.26 {
+		t.Fatalf("record = %+v", rec)
+	}
+	if len(rec.Colors) != 2 || rec.Colors[0].Hex() != "#fa0a0a" || rec.Colors[1].Share != 0.4 {
+		t.Fatalf("colors = %+v", rec.Colors)
+	}
+}
+
+func TestSQLite_InsertImageIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestSQLite(t)
+	// Without image_colors the last step of the insert fails.
+	if _, err := repo.(*SQLiteRepository).db.ExecContext(ctx, `DROP TABLE image_colors`); err != nil {
+		t.Fatal(err)
+	}
+	in := ImageInsert{URL: "https://x/a.png", PageURL: "https://x/", Format: "png",
+		Thumbs: []ThumbInsert{{ThumbVariant: ThumbVariant{Profile: "200w", Width: 200, Height: 100, MIME: "image/png"}, Blob: []byte("png")}},
+		Colors: []ImageColor{{R: 1, G: 2, B: 3, Share: 1}}}
+	if err := repo.InsertImage(ctx, in); err == nil {
+		t.Fatal("insert succeeded without image_colors")
+	}
+	if items, total, err := repo.Search(ctx, SearchParams{}); err != nil || total != 0 || len(items) != 0 {
+		t.Fatalf("search after failed insert: %d images (%v), want none", total, err)
+	}
+}
@@ -0,0 +1,483 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/bits"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// sqlStore holds the queries shared by the MySQL and SQLite repositories.
+// Both drivers use '?' placeholders and LIMIT/OFFSET; the few statements that
+// differ between the two dialects live in dialect.
+type sqlStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+type dialect struct {
+	// name selects the migrations/<name> directory.
+	name string
+	// upsert renders an INSERT of cols that, when key already exists, merges
+	// the new row into the old one. With keepExisting, non-NULL existing values
+	// win (first-seen metadata); otherwise the new values overwrite them.
+	upsert func(table string, cols, key []string, keepExisting bool) string
+	// fullText renders the relevance search for the terms of a
+	// SearchParams.Q (see ftsTerms).
+	fullText func(terms []string) fullTextQuery
+	// tableExists counts tables named by its single placeholder.
+	tableExists string
+	// lock/unlock guard migrations on one connection; empty means no locking.
+	lock   string
+	unlock string
+	// ignoreMigrationError tolerates "already exists" errors from schemas
+	// created before the migration runner existed.
+	ignoreMigrationError func(error) bool
+	// transactionalDDL means schema changes can be rolled back, so each
+	// migration commits together with its schema_migrations row.
+	transactionalDDL bool
+}
+
+// fullTextQuery is a dialect's rendering of a free-text search. where keeps
+// the matching rows; rank is an ORDER BY expression putting the best match
+// first, which may need join added to the FROM clause.
+type fullTextQuery struct {
+	where     string
+	whereArgs []any
+	join      string
+	joinArgs  []any
+	rank      string
+	rankArgs  []any
+}
+
+// maxFTSTerms bounds the size of the generated full-text query.
+const maxFTSTerms = 16
+
+// ftsTerms splits a free-text query into lower-case words. Punctuation is
+// dropped so user input never reaches the dialect's query syntax.
+func ftsTerms(q string) []string {
+	var out []string
+	for _, f := range strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !slices.Contains(out, f) {
+			out = append(out, f)
+		}
+		if len(out) == maxFTSTerms {
+			break
+		}
+	}
+	return out
+}
+
+// placeholders returns "?, ?, ..." for n values.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// nonKey returns cols without the entries in key.
+func nonKey(cols, key []string) []string {
+	var out []string
+	for _, c := range cols {
+		if !slices.Contains(key, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// DupMaxDistance is the largest DHash Hamming distance at which two images
+// count as copies of the same picture. The hash is indexed as four 16-bit
+// bands, so by the pigeonhole principle every hash within 3 bits shares at
+// least one band with the query and is guaranteed to be found.
+const DupMaxDistance = 3
+
+const imageColumns = `id, url, page_url, filename, alt, title, caption, page_title,
+  width, height, format, thumb_path, thumb_mime, created_at,
+  phash, dup_group, crawl_id,
+  bytes, bit_depth, frames, icc_profile, camera_make, camera_model, lens,
+  taken_at, orientation, gps_lat, gps_lon,
+  CASE WHEN images.dup_group IS NULL THEN 0
+       ELSE (SELECT COUNT(*) FROM images d WHERE d.dup_group = images.dup_group) - 1 END`
+
+var imageInsertColumns = []string{
+	"url", "page_url", "filename", "alt", "title", "caption", "page_title", "width", "height", "format",
+	"thumb_path", "thumb_mime", "thumb_blob",
+	"phash", "phash_b0", "phash_b1", "phash_b2", "phash_b3", "dup_group",
+	"color_hist", "color_key", "color_share", "crawl_id",
+	"bytes", "bit_depth", "frames", "icc_profile", "camera_make", "camera_model", "lens",
+	"taken_at", "orientation", "gps_lat", "gps_lon",
+}
+
+// imageFirstSeenColumns keep their first-seen values even when a changed
+// image replaces the stored content (see ImageInsert.Replace).
+var imageFirstSeenColumns = map[string]bool{
+	"url": true, "page_url": true, "filename": true, "alt": true, "title": true,
+	"caption": true, "page_title": true, "crawl_id": true,
+}
+
+func (r *sqlStore) Close() error {
+	if r == nil || r.db == nil {
+		return nil
+	}
+	return r.db.Close()
+}
+
+// InsertImage inserts a record. Duplicate (url,page_url) keeps first-seen
+// metadata but fills in fields that were missing. The record joins the
+// near-duplicate group of an existing copy of the same picture, or starts a
+// new group named after its own id. With in.Replace the content columns,
+// thumbnails and colors are overwritten instead of merged.
+func (r *sqlStore) InsertImage(ctx context.Context, in ImageInsert) error {
+	if r == nil || r.db == nil {
+		return errors.New("nil repository")
+	}
+	group, err := r.findDupGroup(ctx, in)
+	if err != nil {
+		return err
+	}
+
+	var ph, b0, b1, b2, b3 any
+	if in.HasPHash {
+		ph = int64(in.PHash)
+		b0, b1, b2, b3 = phashBand(in.PHash, 0), phashBand(in.PHash, 1), phashBand(in.PHash, 2), phashBand(in.PHash, 3)
+	}
+	var dg any
+	if group != 0 {
+		dg = group
+	}
+	var hist, ck, share any
+	if len(in.ColorHist) == histBins {
+		k := topBins(in.ColorHist, 1)[0]
+		hist, ck, share = in.ColorHist, k, int(in.ColorHist[k])
+	}
+	var taken sql.NullTime
+	if !in.TakenAt.IsZero() {
+		taken = sql.NullTime{Time: in.TakenAt.UTC(), Valid: true}
+	}
+	var lat, lon sql.NullFloat64
+	if in.HasGPS {
+		lat, lon = sql.NullFloat64{Float64: in.GPSLat, Valid: true}, sql.NullFloat64{Float64: in.GPSLon, Valid: true}
+	}
+
+	// The row, its dup_group, thumbnails and colors are saved together so a
+	// failure never leaves an image without its details.
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := []any{
+		in.URL, in.PageURL,
+		nullIfEmpty(in.Filename),
+		nullIfEmpty(in.Alt),
+		nullIfEmpty(in.Title),
+		nullIfEmpty(in.Caption),
+		nullIfEmpty(in.PageTitle),
+		nullIntIfZero(in.Width),
+		nullIntIfZero(in.Height),
+		nullIfEmpty(in.Format),
+		nullIfEmpty(in.ThumbPath),
+		nullIfEmpty(in.ThumbMIME),
+		in.ThumbBlob,
+		ph, b0, b1, b2, b3, dg,
+		hist, ck, share,
+		nullIfEmpty(in.CrawlID),
+		nullIntIfZero(int(in.Bytes)),
+		nullIntIfZero(in.BitDepth),
+		nullIntIfZero(in.Frames),
+		nullIfEmpty(in.ICCProfile),
+		nullIfEmpty(in.CameraMake),
+		nullIfEmpty(in.CameraModel),
+		nullIfEmpty(in.Lens),
+		taken,
+		nullIntIfZero(in.Orientation),
+		lat, lon,
+	}
+	q := r.dialect.upsert("images", imageInsertColumns, []string{"url", "page_url"}, true)
+	if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+		return err
+	}
+	if in.Replace {
+		var set []string
+		var vals []any
+		for i, c := range imageInsertColumns {
+			if !imageFirstSeenColumns[c] {
+				set = append(set, c+" = ?")
+				vals = append(vals, args[i])
+			}
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE images SET `+strings.Join(set, ", ")+` WHERE url = ? AND page_url = ?`,
+			append(vals, in.URL, in.PageURL)...)
+		if err != nil {
+			return err
+		}
+	}
+	if group == 0 {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE images SET dup_group = id WHERE url = ? AND page_url = ? AND dup_group IS NULL`,
+			in.URL, in.PageURL)
+		if err != nil {
+			return err
+		}
+	}
+	if in.Replace || len(in.Thumbs) > 0 || len(in.Colors) > 0 {
+		var id uint64
+		if err := tx.QueryRowContext(ctx, `SELECT id FROM images WHERE url = ? AND page_url = ?`, in.URL, in.PageURL).Scan(&id); err != nil {
+			return err
+		}
+		if err := r.saveThumbs(ctx, tx, id, in.Thumbs, in.Replace); err != nil {
+			return err
+		}
+		if err := r.saveColors(ctx, tx, id, in.Colors, in.Replace); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// findDupGroup returns the group of an existing copy of in: the same URL
+// stored for another page, or the nearest image by DHash within DupMaxDistance.
+// A replaced image is matched by DHash only, leaving out its own stale row.
+func (r *sqlStore) findDupGroup(ctx context.Context, in ImageInsert) (uint64, error) {
+	var group, self uint64
+	var err error
+	if in.Replace {
+		err = r.db.QueryRowContext(ctx,
+			`SELECT id FROM images WHERE url = ? AND page_url = ?`, in.URL, in.PageURL).Scan(&self)
+	} else {
+		err = r.db.QueryRowContext(ctx,
+			`SELECT dup_group FROM images WHERE url = ? AND dup_group IS NOT NULL LIMIT 1`, in.URL).Scan(&group)
+		if err == nil {
+			return group, nil
+		}
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if !in.HasPHash {
+		return 0, nil
+	}
+
+	cands, err := r.phashCandidates(ctx, in.PHash, self)
+	if err != nil {
+		return 0, err
+	}
+	best := DupMaxDistance + 1
+	for _, c := range cands {
+		if !c.group.Valid {
+			continue
+		}
+		if d := bits.OnesCount64(c.phash ^ in.PHash); d < best {
+			best = d
+			group = uint64(c.group.Int64)
+		}
+	}
+	return group, nil
+}
+
+type phashCandidate struct {
+	id    uint64
+	phash uint64
+	group sql.NullInt64
+}
+
+// phashCandidates returns images sharing at least one 16-bit band with h.
+func (r *sqlStore) phashCandidates(ctx context.Context, h uint64, excludeID uint64) ([]phashCandidate, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT id, phash, dup_group FROM images
+WHERE (phash_b0 = ? OR phash_b1 = ? OR phash_b2 = ? OR phash_b3 = ?) AND id <> ?`,
+		phashBand(h, 0), phashBand(h, 1), phashBand(h, 2), phashBand(h, 3), excludeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []phashCandidate
+	for rows.Next() {
+		var c phashCandidate
+		var ph int64
+		if err := rows.Scan(&c.id, &ph, &c.group); err != nil {
+			return nil, err
+		}
+		c.phash = uint64(ph)
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func phashBand(h uint64, i int) int {
+	return int(h >> (16 * uint(3-i)) & 0xffff)
+}
+
+func (r *sqlStore) GetImage(ctx context.Context, id uint64) (ImageRecord, error) {
+	q := `SELECT ` + imageColumns + ` FROM images WHERE id = ? LIMIT 1`
+	rec, err := scanImage(r.db.QueryRowContext(ctx, q, id))
+	if err != nil {
+		return ImageRecord{}, err
+	}
+	recs := []ImageRecord{rec}
+	if err := r.loadDetails(ctx, recs); err != nil {
+		return ImageRecord{}, err
+	}
+	return recs[0], nil
+}
+
+func (r *sqlStore) Search(ctx context.Context, p SearchParams) (results []ImageRecord, total int, err error) {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.PageSize <= 0 || p.PageSize > 200 {
+		p.PageSize = 40
+	}
+
+	where := "WHERE 1=1"
+	args := []any{}
+
+	addLike := func(field, v string) {
+		if v == "" {
+			return
+		}
+		where += fmt.Sprintf(" AND %s LIKE ?", field)
+		args = append(args, "%"+v+"%")
+	}
+	addEq := func(field, v string) {
+		if v == "" {
+			return
+		}
+		where += fmt.Sprintf(" AND %s = ?", field)
+		args = append(args, v)
+	}
+	addCmp := func(field, op string, v *int) {
+		if v == nil {
+			return
+		}
+		where += fmt.Sprintf(" AND %s %s ?", field, op)
+		args = append(args, *v)
+	}
+
+	addLike("url", p.URLContains)
+	addLike("page_url", p.PageURLContains)
+	addLike("filename", p.FilenameContains)
+	addLike("alt", p.AltContains)
+	addLike("title", p.TitleContains)
+	addEq("format", p.FormatEquals)
+	addEq("crawl_id", p.CrawlID)
+	addCmp("width", ">=", p.MinWidth)
+	addCmp("width", "<=", p.MaxWidth)
+	addCmp("height", ">=", p.MinHeight)
+	addCmp("height", "<=", p.MaxHeight)
+	var ft fullTextQuery
+	if p.Q != "" {
+		if terms := ftsTerms(p.Q); len(terms) > 0 {
+			ft = r.dialect.fullText(terms)
+			where += " AND " + ft.where
+			args = append(args, ft.whereArgs...)
+		} else {
+			where += " AND 1=0"
+		}
+	}
+	if p.DupGroup != 0 {
+		where += " AND dup_group = ?"
+		args = append(args, p.DupGroup)
+	}
+	if p.CameraContains != "" {
+		where += " AND (camera_make LIKE ? OR camera_model LIKE ?)"
+		args = append(args, "%"+p.CameraContains+"%", "%"+p.CameraContains+"%")
+	}
+	if p.HasGPS {
+		where += " AND gps_lat IS NOT NULL"
+	}
+	if p.Animated {
+		where += " AND frames > 1"
+	}
+	if p.Color != "" {
+		c, err := ParseHexColor(p.Color)
+		if err != nil {
+			return nil, 0, err
+		}
+		w, a := colorFilter(c, p.ColorDistance)
+		where += " AND " + w
+		args = append(args, a...)
+	}
+	if p.CollapseDuplicates {
+		// Keep one representative (the oldest matching row) per group.
+		where += " AND (images.dup_group IS NULL OR images.id IN (SELECT MIN(id) FROM images " + where + " GROUP BY dup_group))"
+		args = append(args, args...)
+	}
+
+	var (
+		join      string
+		order     string
+		joinArgs  []any
+		orderArgs []any
+	)
+	sort := p.EffectiveSort()
+	if sort == SortRelevance && ft.rank == "" {
+		sort = DefaultSort
+	}
+	if sort == SortRelevance {
+		join, joinArgs = ft.join, ft.joinArgs
+		order, orderArgs = "ORDER BY "+ft.rank+", images.id DESC", ft.rankArgs
+	} else if order, err = orderBy(sort); err != nil {
+		return nil, 0, err
+	}
+
+	// total
+	qCount := "SELECT COUNT(*) FROM images " + where
+	if err := r.db.QueryRowContext(ctx, qCount, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	offset := (p.Page - 1) * p.PageSize
+	q := `
+SELECT ` + imageColumns + `
+FROM images ` + join + `
+` + where + `
+` + order + `
+LIMIT ? OFFSET ?`
+	args2 := append(append(append(append([]any{}, joinArgs...), args...), orderArgs...), p.PageSize, offset)
+
+	rows, err := r.db.QueryContext(ctx, q, args2...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rec, err := scanImage(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close() // before the next query: SQLite has a single connection
+	if err := r.loadDetails(ctx, results); err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanImage(row rowScanner) (ImageRecord, error) {
+	var rec ImageRecord
+	err := row.Scan(
+		&rec.ID, &rec.URL, &rec.PageURL, &rec.Filename, &rec.Alt, &rec.Title, &rec.Caption, &rec.PageTitle,
+		&rec.Width, &rec.Height, &rec.Format, &rec.ThumbPath, &rec.ThumbMIME, &rec.CreatedAt,
+		&rec.PHash, &rec.DupGroup, &rec.CrawlID,
+		&rec.Bytes, &rec.BitDepth, &rec.Frames, &rec.ICCProfile, &rec.CameraMake, &rec.CameraModel, &rec.Lens,
+		&rec.TakenAt, &rec.Orientation, &rec.GPSLat, &rec.GPSLon,
+		&rec.DupCount,
+	)
+	return rec, err
+}
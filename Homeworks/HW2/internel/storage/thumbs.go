@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var thumbColumns = []string{"image_id", "profile", "width", "height", "mime", "path", "data"}
+
+// saveThumbs stores the thumbnail variants of image id. Like the image row,
+// variants already stored are kept, unless replace drops them first.
+func (r *sqlStore) saveThumbs(ctx context.Context, tx *sql.Tx, id uint64, thumbs []ThumbInsert, replace bool) error {
+	if replace {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM image_thumbs WHERE image_id = ?`, id); err != nil {
+			return err
+		}
+	}
+	if len(thumbs) == 0 {
+		return nil
+	}
+	q := r.dialect.upsert("image_thumbs", thumbColumns, []string{"image_id", "profile"}, true)
+	for _, t := range thumbs {
+		if _, err := tx.ExecContext(ctx, q, id, t.Profile, t.Width, t.Height, t.MIME, nullIfEmpty(t.Path), t.Blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadThumbs fills in the Thumbs of recs with one query.
+func (r *sqlStore) loadThumbs(ctx context.Context, recs []ImageRecord) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	ids := make([]any, len(recs))
+	at := make(map[uint64]int, len(recs))
+	for i, rec := range recs {
+		ids[i] = rec.ID
+		at[rec.ID] = i
+	}
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT image_id, profile, width, height, mime FROM image_thumbs WHERE image_id IN (`+placeholders(len(ids))+`)
+ORDER BY image_id, width, profile`, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uint64
+		var v ThumbVariant
+		if err := rows.Scan(&id, &v.Profile, &v.Width, &v.Height, &v.MIME); err != nil {
+			return err
+		}
+		i := at[id]
+		recs[i].Thumbs = append(recs[i].Thumbs, v)
+	}
+	return rows.Err()
+}
+
+func (r *sqlStore) GetThumb(ctx context.Context, id uint64, profile string) (mime string, blob []byte, err error) {
+	if profile != "" {
+		err := r.db.QueryRowContext(ctx,
+			`SELECT mime, data FROM image_thumbs WHERE image_id = ? AND profile = ?`, id, profile).Scan(&mime, &blob)
+		if err == nil {
+			return mime, blob, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", nil, err
+		}
+	}
+	q := `SELECT thumb_mime, thumb_blob FROM images WHERE id = ? LIMIT 1`
+	var m sql.NullString
+	row := r.db.QueryRowContext(ctx, q, id)
+	if err := row.Scan(&m, &blob); err != nil {
+		return "", nil, err
+	}
+	if m.Valid {
+		return m.String, blob, nil
+	}
+	return "application/octet-stream", blob, nil
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Validators are the HTTP cache validators last seen for a page or image
+// URL. CheckedAt is when the URL was last fetched or revalidated; a crawl
+// with a recrawl window skips URLs checked more recently than that.
+type Validators struct {
+	URL          string
+	ETag         string
+	LastModified string
+	CheckedAt    time.Time
+}
+
+var validatorColumns = []string{"url_hash", "url", "etag", "last_modified", "checked_at"}
+
+// GetValidators returns sql.ErrNoRows for URLs never fetched successfully.
+func (r *sqlStore) GetValidators(ctx context.Context, url string) (Validators, error) {
+	var (
+		v        = Validators{URL: url}
+		etag, lm sql.NullString
+	)
+	err := r.db.QueryRowContext(ctx,
+		`SELECT etag, last_modified, checked_at FROM http_validators WHERE url_hash = ?`, urlHash(url)).
+		Scan(&etag, &lm, &v.CheckedAt)
+	v.ETag, v.LastModified = etag.String, lm.String
+	return v, err
+}
+
+func (r *sqlStore) SaveValidators(ctx context.Context, v Validators) error {
+	at := v.CheckedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, r.dialect.upsert("http_validators", validatorColumns, []string{"url_hash"}, false),
+		urlHash(v.URL), v.URL, nullIfEmpty(v.ETag), nullIfEmpty(v.LastModified), at.UTC())
+	return err
+}
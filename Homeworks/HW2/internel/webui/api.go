@@ -0,0 +1,418 @@
+package webui
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourname/go-image-crawler/internal/storage"
+)
+
+// The JSON API mirrors the HTML search page under /api/v1. List filters use
+// the same query parameter names as "/"; unlike the HTML page, malformed
+// parameters are rejected with a 400 instead of being ignored.
+
+// apiMaxPageSize matches the cap applied by storage.Search.
+const apiMaxPageSize = 200
+
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+}
+
+type apiImage struct {
+	ID        uint64       `json:"id"`
+	URL       string       `json:"url"`
+	PageURL   string       `json:"page_url"`
+	Filename  *string      `json:"filename,omitempty"`
+	Alt       *string      `json:"alt,omitempty"`
+	Title     *string      `json:"title,omitempty"`
+	Caption   *string      `json:"caption,omitempty"`
+	PageTitle *string      `json:"page_title,omitempty"`
+	Width     *int64       `json:"width,omitempty"`
+	Height    *int64       `json:"height,omitempty"`
+	Format    *string      `json:"format,omitempty"`
+	ThumbMIME *string      `json:"thumb_mime,omitempty"`
+	Thumbs    []apiThumb   `json:"thumbs,omitempty"`
+	PHash     string       `json:"phash,omitempty"`
+	DupGroup  *int64       `json:"dup_group,omitempty"`
+	DupCount  int          `json:"dup_count"`
+	CrawlID   *string      `json:"crawl_id,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	Links     apiItemLinks `json:"links"`
+
+	Bytes      *int64     `json:"bytes,omitempty"`
+	BitDepth   *int64     `json:"bit_depth,omitempty"`
+	Frames     *int64     `json:"frames,omitempty"`
+	Animated   bool       `json:"animated"`
+	ICCProfile *string    `json:"icc_profile,omitempty"`
+	EXIF       *apiEXIF   `json:"exif,omitempty"`
+	Colors     []apiColor `json:"colors,omitempty"`
+}
+
+type apiEXIF struct {
+	Make        *string    `json:"make,omitempty"`
+	Model       *string    `json:"model,omitempty"`
+	Lens        *string    `json:"lens,omitempty"`
+	TakenAt     *time.Time `json:"taken_at,omitempty"`
+	Orientation *int64     `json:"orientation,omitempty"`
+	GPS         *apiGPS    `json:"gps,omitempty"`
+}
+
+type apiGPS struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// apiColor is a dominant color; Share is its fraction of the pixels.
+type apiColor struct {
+	Hex   string  `json:"hex"`
+	Share float64 `json:"share"`
+}
+
+// apiThumb is a thumbnail variant, fetched from its URL.
+type apiThumb struct {
+	Size   string `json:"size"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	MIME   string `json:"mime"`
+	URL    string `json:"url"`
+}
+
+type apiItemLinks struct {
+	Self  string `json:"self"`
+	Thumb string `json:"thumb"`
+	HTML  string `json:"html"`
+}
+
+type apiList struct {
+	Items    []apiImage   `json:"items"`
+	Total    int          `json:"total"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+	Pages    int          `json:"pages"`
+	Sort     string       `json:"sort"`
+	Links    apiListLinks `json:"links"`
+}
+
+type apiListLinks struct {
+	Self  string `json:"self"`
+	First string `json:"first"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last"`
+}
+
+func (s *Server) apiRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/images", s.apiListImages)
+	mux.HandleFunc("/api/v1/images/{id}", s.apiGetImage)
+	mux.HandleFunc("/api/v1/images/{id}/thumb", s.apiGetThumb)
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, &apiError{Status: http.StatusNotFound, Code: "not_found", Message: "no such endpoint: " + r.URL.Path})
+	})
+}
+
+func (s *Server) apiListImages(w http.ResponseWriter, r *http.Request) {
+	if !apiMethodGET(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	p, aerr := apiSearchParams(q, s.PageSize)
+	if aerr != nil {
+		writeAPIError(w, aerr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	items, total, err := s.Repo.Search(ctx, p)
+	if errors.Is(err, storage.ErrInvalidSort) {
+		writeAPIError(w, &apiError{Status: http.StatusBadRequest, Code: "invalid_parameter", Message: err.Error(), Param: "sort"})
+		return
+	}
+	if err != nil {
+		writeAPIError(w, internalError(r, err))
+		return
+	}
+
+	pages := (total + p.PageSize - 1) / p.PageSize
+	if pages < 1 {
+		pages = 1
+	}
+	pageLink := func(n int) string {
+		v := url.Values{}
+		for k, vs := range q {
+			v[k] = vs
+		}
+		v.Set("page", strconv.Itoa(n))
+		v.Set("page_size", strconv.Itoa(p.PageSize))
+		return r.URL.Path + "?" + v.Encode()
+	}
+	out := apiList{
+		Items:    make([]apiImage, 0, len(items)),
+		Total:    total,
+		Page:     p.Page,
+		PageSize: p.PageSize,
+		Pages:    pages,
+		Sort:     p.EffectiveSort(),
+		Links: apiListLinks{
+			Self:  pageLink(p.Page),
+			First: pageLink(1),
+			Last:  pageLink(pages),
+		},
+	}
+	if p.Page > 1 {
+		out.Links.Prev = pageLink(min(p.Page-1, pages))
+	}
+	if p.Page < pages {
+		out.Links.Next = pageLink(p.Page + 1)
+	}
+	for _, rec := range items {
+		out.Items = append(out.Items, toAPIImage(rec))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) apiGetImage(w http.ResponseWriter, r *http.Request) {
+	if !apiMethodGET(w, r) {
+		return
+	}
+	id, aerr := apiPathID(r)
+	if aerr != nil {
+		writeAPIError(w, aerr)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rec, err := s.Repo.GetImage(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeAPIError(w, &apiError{Status: http.StatusNotFound, Code: "not_found", Message: fmt.Sprintf("image %d not found", id)})
+		return
+	}
+	if err != nil {
+		writeAPIError(w, internalError(r, err))
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIImage(rec))
+}
+
+func (s *Server) apiGetThumb(w http.ResponseWriter, r *http.Request) {
+	if !apiMethodGET(w, r) {
+		return
+	}
+	id, aerr := apiPathID(r)
+	if aerr != nil {
+		writeAPIError(w, aerr)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	mime, blob, err := s.Repo.GetThumb(ctx, id, r.URL.Query().Get("size"))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeAPIError(w, &apiError{Status: http.StatusNotFound, Code: "not_found", Message: fmt.Sprintf("image %d not found", id)})
+		return
+	}
+	if err != nil {
+		writeAPIError(w, internalError(r, err))
+		return
+	}
+	if len(blob) == 0 {
+		writeAPIError(w, &apiError{Status: http.StatusNotFound, Code: "no_thumbnail", Message: fmt.Sprintf("image %d has no thumbnail", id)})
+		return
+	}
+	writeThumb(w, mime, blob)
+}
+
+// apiSearchParams parses the list filters strictly.
+func apiSearchParams(q url.Values, defPageSize int) (storage.SearchParams, *apiError) {
+	p := storage.SearchParams{
+		Q:                q.Get("q"),
+		URLContains:      q.Get("url"),
+		PageURLContains:  q.Get("page_url"),
+		FilenameContains: q.Get("filename"),
+		AltContains:      q.Get("alt"),
+		TitleContains:    q.Get("title"),
+		FormatEquals:     q.Get("format"),
+		CrawlID:          q.Get("crawl"),
+		CameraContains:   q.Get("camera"),
+		Color:            strings.TrimSpace(q.Get("color")),
+		Sort:             q.Get("sort"),
+		Page:             1,
+		PageSize:         defPageSize,
+	}
+	if p.PageSize <= 0 || p.PageSize > apiMaxPageSize {
+		p.PageSize = 40
+	}
+
+	var aerr *apiError
+	intParam := func(name string, min, max int) *int {
+		v := strings.TrimSpace(q.Get(name))
+		if v == "" || aerr != nil {
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < min || n > max {
+			aerr = &apiError{Status: http.StatusBadRequest, Code: "invalid_parameter",
+				Message: fmt.Sprintf("%s must be an integer between %d and %d", name, min, max), Param: name}
+			return nil
+		}
+		return &n
+	}
+	const maxInt = int(^uint32(0) >> 1)
+	p.MinWidth = intParam("min_w", 0, maxInt)
+	p.MaxWidth = intParam("max_w", 0, maxInt)
+	p.MinHeight = intParam("min_h", 0, maxInt)
+	p.MaxHeight = intParam("max_h", 0, maxInt)
+	if n := intParam("page", 1, maxInt); n != nil {
+		p.Page = *n
+	}
+	if n := intParam("page_size", 1, apiMaxPageSize); n != nil {
+		p.PageSize = *n
+	}
+	if v := strings.TrimSpace(q.Get("dup")); v != "" && aerr == nil {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			aerr = &apiError{Status: http.StatusBadRequest, Code: "invalid_parameter", Message: "dup must be an image group id", Param: "dup"}
+		}
+		p.DupGroup = n
+	}
+	boolParam := func(name string) bool {
+		v := q.Get(name)
+		if v == "" || aerr != nil {
+			return false
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			aerr = &apiError{Status: http.StatusBadRequest, Code: "invalid_parameter", Message: name + " must be a boolean", Param: name}
+		}
+		return b
+	}
+	p.CollapseDuplicates = boolParam("collapse")
+	p.HasGPS = boolParam("gps")
+	p.Animated = boolParam("animated")
+	if p.Color != "" && aerr == nil {
+		if _, err := storage.ParseHexColor(p.Color); err != nil {
+			aerr = &apiError{Status: http.StatusBadRequest, Code: "invalid_parameter", Message: err.Error(), Param: "color"}
+		}
+	}
+	if n := intParam("color_distance", 1, 442); n != nil {
+		p.ColorDistance = *n
+	}
+	return p, aerr
+}
+
+func apiPathID(r *http.Request) (uint64, *apiError) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil || id == 0 {
+		return 0, &apiError{Status: http.StatusBadRequest, Code: "invalid_parameter", Message: "id must be a positive integer", Param: "id"}
+	}
+	return id, nil
+}
+
+func apiMethodGET(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	writeAPIError(w, &apiError{Status: http.StatusMethodNotAllowed, Code: "method_not_allowed", Message: r.Method + " is not supported"})
+	return false
+}
+
+// internalError logs err and hides the details from the client.
+func internalError(r *http.Request, err error) *apiError {
+	log.Printf("api: %s %s: %v", r.Method, r.URL.RequestURI(), err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &apiError{Status: http.StatusGatewayTimeout, Code: "timeout", Message: "the database did not answer in time"}
+	}
+	return &apiError{Status: http.StatusInternalServerError, Code: "internal", Message: "internal server error"}
+}
+
+func toAPIImage(rec storage.ImageRecord) apiImage {
+	str := func(v sql.NullString) *string {
+		if !v.Valid {
+			return nil
+		}
+		return &v.String
+	}
+	num := func(v sql.NullInt64) *int64 {
+		if !v.Valid {
+			return nil
+		}
+		return &v.Int64
+	}
+	out := apiImage{
+		ID:        rec.ID,
+		URL:       rec.URL,
+		PageURL:   rec.PageURL,
+		Filename:  str(rec.Filename),
+		Alt:       str(rec.Alt),
+		Title:     str(rec.Title),
+		Caption:   str(rec.Caption),
+		PageTitle: str(rec.PageTitle),
+		Width:     num(rec.Width),
+		Height:    num(rec.Height),
+		Format:    str(rec.Format),
+		ThumbMIME: str(rec.ThumbMIME),
+		DupGroup:  num(rec.DupGroup),
+		DupCount:  rec.DupCount,
+		CrawlID:   str(rec.CrawlID),
+		CreatedAt: rec.CreatedAt,
+		Links: apiItemLinks{
+			Self:  fmt.Sprintf("/api/v1/images/%d", rec.ID),
+			Thumb: fmt.Sprintf("/api/v1/images/%d/thumb", rec.ID),
+			HTML:  fmt.Sprintf("/image?id=%d", rec.ID),
+		},
+	}
+	for _, t := range rec.Thumbs {
+		out.Thumbs = append(out.Thumbs, apiThumb{
+			Size: t.Profile, Width: t.Width, Height: t.Height, MIME: t.MIME,
+			URL: fmt.Sprintf("/api/v1/images/%d/thumb?size=%s", rec.ID, url.QueryEscape(t.Profile)),
+		})
+	}
+	if rec.PHash.Valid {
+		out.PHash = fmt.Sprintf("%016x", uint64(rec.PHash.Int64))
+	}
+	out.Bytes, out.BitDepth, out.Frames = num(rec.Bytes), num(rec.BitDepth), num(rec.Frames)
+	out.Animated = rec.Frames.Int64 > 1
+	out.ICCProfile = str(rec.ICCProfile)
+	exif := apiEXIF{Make: str(rec.CameraMake), Model: str(rec.CameraModel), Lens: str(rec.Lens), Orientation: num(rec.Orientation)}
+	if rec.TakenAt.Valid {
+		exif.TakenAt = &rec.TakenAt.Time
+	}
+	if rec.GPSLat.Valid && rec.GPSLon.Valid {
+		exif.GPS = &apiGPS{Lat: rec.GPSLat.Float64, Lon: rec.GPSLon.Float64}
+	}
+	if exif != (apiEXIF{}) {
+		out.EXIF = &exif
+	}
+	for _, c := range rec.Colors {
+		out.Colors = append(out.Colors, apiColor{Hex: c.Hex(), Share: math.Round(c.Share*1000) / 1000})
+	}
+	return out
+}
+
+func writeAPIError(w http.ResponseWriter, e *apiError) {
+	writeJSON(w, e.Status, map[string]*apiError{"error": e})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
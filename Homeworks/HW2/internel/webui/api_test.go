@@ -0,0 +1,230 @@
+package webui
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourname/go-image-crawler/internal/storage"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	ctx := context.Background()
+	repo, err := storage.Open("sqlite::memory:")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = repo.Close() })
+	if _, err := repo.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	for _, in := range []storage.ImageInsert{
+		{URL: "https://x/a.png", PageURL: "https://x/", Filename: "a.png", Format: "png", Width: 100, Height: 100,
+			ThumbMIME: "image/jpeg", ThumbBlob: []byte{0xff, 0xd8}, Thumbs: []storage.ThumbInsert{
+				{ThumbVariant: storage.ThumbVariant{Profile: "100w", Width: 100, Height: 100, MIME: "image/jpeg"}, Blob: []byte{0xff, 0xd8}},
+				{ThumbVariant: storage.ThumbVariant{Profile: "400w", Width: 400, Height: 400, MIME: "image/png"}, Blob: []byte{0x89, 'P', 'N'}},
+				{ThumbVariant: storage.ThumbVariant{Profile: "sq50", Width: 50, Height: 50, MIME: "image/jpeg"}, Blob: []byte{0xff}},
+			}},
+		{URL: "https://x/b.jpg", PageURL: "https://x/", Filename: "b.jpg", Format: "jpeg", Width: 300, Height: 200},
+		{URL: "https://x/c.jpg", PageURL: "https://x/", Filename: "c.jpg", Format: "jpeg", Width: 200, Height: 50},
+	} {
+		if err := repo.InsertImage(ctx, in); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	return &Server{Repo: repo, PageSize: 40}
+}
+
+func get(t *testing.T, h http.Handler, target string, v any) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+	if v != nil {
+		if err := json.Unmarshal(rr.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s: bad JSON %q: %v", target, rr.Body.String(), err)
+		}
+	}
+	return rr
+}
+
+func TestAPI_ListFiltersSortsAndPaginates(t *testing.T) {
+	h := newTestServer(t).Routes()
+
+	var list apiList
+	rr := get(t, h, "/api/v1/images?format=jpeg&sort=-width&page_size=1", &list)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+	}
+	if list.Total != 2 || list.Pages != 2 || len(list.Items) != 1 || *list.Items[0].Filename != "b.jpg" {
+		t.Fatalf("unexpected page: %+v", list)
+	}
+	if list.Links.Prev != "" || list.Links.Next != "/api/v1/images?format=jpeg&page=2&page_size=1&sort=-width" {
+		t.Fatalf("links = %+v", list.Links)
+	}
+
+	var page2 apiList
+	rr = get(t, h, list.Links.Next, &page2)
+	if rr.Code != http.StatusOK || len(page2.Items) != 1 || *page2.Items[0].Filename != "c.jpg" || page2.Links.Next != "" {
+		t.Fatalf("second page: %d %+v", rr.Code, page2)
+	}
+}
+
+func TestAPI_ImageAndThumb(t *testing.T) {
+	h := newTestServer(t).Routes()
+
+	var list apiList
+	get(t, h, "/api/v1/images?filename=a.png", &list)
+	if len(list.Items) != 1 {
+		t.Fatalf("items = %+v", list.Items)
+	}
+	var img apiImage
+	rr := get(t, h, list.Items[0].Links.Self, &img)
+	if rr.Code != http.StatusOK || img.URL != "https://x/a.png" || *img.Width != 100 {
+		t.Fatalf("image: %d %+v", rr.Code, img)
+	}
+
+	rr = get(t, h, img.Links.Thumb, nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/jpeg" || rr.Body.Len() != 2 {
+		t.Fatalf("thumb: %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestAPI_StructuredErrors(t *testing.T) {
+	h := newTestServer(t).Routes()
+
+	for _, tc := range []struct {
+		target string
+		status int
+		code   string
+		param  string
+	}{
+		{"/api/v1/images?min_w=wide", http.StatusBadRequest, "invalid_parameter", "min_w"},
+		{"/api/v1/images?sort=thumb_blob", http.StatusBadRequest, "invalid_parameter", "sort"},
+		{"/api/v1/images?page_size=5000", http.StatusBadRequest, "invalid_parameter", "page_size"},
+		{"/api/v1/images/abc", http.StatusBadRequest, "invalid_parameter", "id"},
+		{"/api/v1/images/999", http.StatusNotFound, "not_found", ""},
+		{"/api/v1/images/2/thumb", http.StatusNotFound, "no_thumbnail", ""},
+		{"/api/v2/images", http.StatusNotFound, "not_found", ""},
+	} {
+		var body struct{ Error apiError }
+		rr := get(t, h, tc.target, &body)
+		if rr.Code != tc.status || body.Error.Code != tc.code || body.Error.Param != tc.param || body.Error.Message == "" {
+			t.Errorf("%s: %d %+v, want %d %s param=%q", tc.target, rr.Code, body.Error, tc.status, tc.code, tc.param)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/images", nil))
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") == "" {
+		t.Fatalf("POST: %d", rr.Code)
+	}
+}
+
+func TestRoutes_Metrics(t *testing.T) {
+	h := newTestServer(t).Routes()
+	before := httpRequests.Value("/api/v1/images/{id}", http.MethodGet, "404")
+
+	get(t, h, "/api/v1/images/999", nil)
+	get(t, h, "/api/v1/images/998", nil)
+	if got := httpRequests.Value("/api/v1/images/{id}", http.MethodGet, "404") - before; got != 2 {
+		t.Fatalf("requests counted under the route pattern: %v, want 2", got)
+	}
+
+	rr := get(t, h, "/metrics", nil)
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("metrics: %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rr.Body.String(), `web_http_requests_total{route="/api/v1/images/{id}",method="GET",code="404"}`) {
+		t.Fatalf("metrics body lacks the request counter:\n%s", rr.Body.String())
+	}
+}
+
+func TestThumbVariants(t *testing.T) {
+	h := newTestServer(t).Routes()
+
+	var img apiImage
+	get(t, h, "/api/v1/images/1", &img)
+	// Narrowest first.
+	if len(img.Thumbs) != 3 || img.Thumbs[2].Size != "400w" || img.Thumbs[2].URL != "/api/v1/images/1/thumb?size=400w" {
+		t.Fatalf("thumbs: %+v", img.Thumbs)
+	}
+	for _, target := range []string{img.Thumbs[2].URL, "/thumb?id=1&size=400w"} {
+		rr := get(t, h, target, nil)
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" || rr.Body.Len() != 3 {
+			t.Errorf("%s: %d %q %d bytes", target, rr.Code, rr.Header().Get("Content-Type"), rr.Body.Len())
+		}
+	}
+	// Unknown sizes fall back to the default thumbnail.
+	if rr := get(t, h, "/thumb?id=1&size=9000w", nil); rr.Header().Get("Content-Type") != "image/jpeg" || rr.Body.Len() != 2 {
+		t.Errorf("fallback: %q %d bytes", rr.Header().Get("Content-Type"), rr.Body.Len())
+	}
+
+	rec, err := newTestServer(t).Repo.GetImage(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The square crop is left out.
+	if got, want := string(SrcSet(rec.ID, rec.Thumbs)), "/thumb?id=1&size=100w 100w, /thumb?id=1&size=400w 400w"; got != want {
+		t.Fatalf("SrcSet = %q, want %q", got, want)
+	}
+	small := []storage.ThumbVariant{{Profile: "200w", Width: 50, Height: 20}, {Profile: "400w", Width: 50, Height: 20}}
+	if got, want := string(SrcSet(7, small)), "/thumb?id=7&size=200w 50w"; got != want {
+		t.Fatalf("SrcSet of a small image = %q, want %q", got, want)
+	}
+}
+
+func TestLegacySVGThumbIsSandboxed(t *testing.T) {
+	s := newTestServer(t)
+	err := s.Repo.InsertImage(context.Background(), storage.ImageInsert{URL: "https://x/old.svg", PageURL: "https://x/", Format: "svg",
+		ThumbMIME: "image/svg+xml", ThumbBlob: []byte(`<svg onload="alert(1)"/>`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.Routes()
+	for target, sandboxed := range map[string]bool{"/thumb?id=4": true, "/api/v1/images/4/thumb": true, "/thumb?id=1": false} {
+		rr := get(t, h, target, nil)
+		if csp := rr.Header().Get("Content-Security-Policy"); rr.Code != http.StatusOK || strings.Contains(csp, "sandbox") != sandboxed {
+			t.Errorf("%s: %d %q, CSP %q", target, rr.Code, rr.Header().Get("Content-Type"), csp)
+		}
+	}
+}
+
+func TestAPI_MetadataFilters(t *testing.T) {
+	s := newTestServer(t)
+	err := s.Repo.InsertImage(context.Background(), storage.ImageInsert{URL: "https://x/photo.jpg", PageURL: "https://x/", Format: "jpeg",
+		Bytes: 2048, BitDepth: 8, Frames: 1, CameraMake: "Canon", CameraModel: "Canon EOS R5", HasGPS: true, GPSLat: 1.5, GPSLon: -2.5,
+		Colors: []storage.ImageColor{{R: 255, G: 0, B: 0, Share: 0.75}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.Routes()
+
+	var list apiList
+	rr := get(t, h, "/api/v1/images?gps=true&color=%23f00&camera=eos", &list)
+	if rr.Code != http.StatusOK || len(list.Items) != 1 {
+		t.Fatalf("filtered list: %d %s", rr.Code, rr.Body)
+	}
+	img := list.Items[0]
+	if *img.Bytes != 2048 || img.Animated || img.EXIF == nil || img.EXIF.GPS == nil || img.EXIF.GPS.Lon != -2.5 ||
+		len(img.Colors) != 1 || img.Colors[0] != (apiColor{Hex: "#ff0000", Share: 0.75}) {
+		t.Fatalf("item = %s", rr.Body)
+	}
+	if get(t, h, "/api/v1/images?animated=1", &list); list.Total != 0 {
+		t.Fatalf("animated matched %d images", list.Total)
+	}
+
+	for target, param := range map[string]string{
+		"/api/v1/images?color=red":                        "color",
+		"/api/v1/images?gps=maybe":                        "gps",
+		"/api/v1/images?color=%23f00&color_distance=1000": "color_distance",
+	} {
+		var body struct{ Error apiError }
+		if rr := get(t, h, target, &body); rr.Code != http.StatusBadRequest || body.Error.Param != param {
+			t.Errorf("%s: %d %+v", target, rr.Code, body.Error)
+		}
+	}
+}
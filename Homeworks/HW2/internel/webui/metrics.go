@@ -0,0 +1,57 @@
+package webui
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yourname/go-image-crawler/internal/metrics"
+)
+
+var (
+	httpRequests = metrics.Default.NewCounter("web_http_requests_total",
+		`Requests served, by route pattern ("unmatched" for 404s outside every route), method and status code.`, "route", "method", "code")
+	httpDuration = metrics.Default.NewHistogram("web_http_request_duration_seconds",
+		"Time to serve a request, by route pattern.", nil, "route")
+	httpInFlight = metrics.Default.NewGauge("web_http_requests_in_flight",
+		"Requests being served.")
+)
+
+// instrument counts and times the requests mux serves. Routes are labeled
+// by their pattern so that ids in paths do not make new series.
+func instrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		mux.ServeHTTP(sw, r) // sets r.Pattern
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		httpDuration.Observe(time.Since(start).Seconds(), route)
+		httpRequests.Inc(route, r.Method, strconv.Itoa(sw.code))
+	})
+}
+
+// statusWriter remembers the status code written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.code, w.wroteHeader = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -2,17 +2,21 @@ package webui
 
 import (
 	"context"
+	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/yourname/go-image-crawler/internal/metrics"
 	"github.com/yourname/go-image-crawler/internal/storage"
 )
 
 type Server struct {
-	Repo     *storage.Repository
+	Repo     storage.Repository
 	Tmpl     *template.Template
 	PageSize int
 }
@@ -22,12 +26,19 @@ func (s *Server) Routes() http.Handler {
 	mux.HandleFunc("/", s.handleIndex)
 	mux.HandleFunc("/thumb", s.handleThumb)
 	mux.HandleFunc("/image", s.handleImage)
+	mux.HandleFunc("/similar", s.handleSimilar)
+	mux.HandleFunc("/crawls", s.handleCrawls)
+	mux.HandleFunc("/page", s.handlePage)
+	mux.HandleFunc("/failures", s.handleFailures)
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
-	return mux
+	mux.Handle("/metrics", metrics.Default.Handler())
+	s.apiRoutes(mux)
+	return instrument(mux)
 }
 
 type indexView struct {
 	Params     storage.SearchParams
+	Crawls     []storage.CrawlRecord
 	Items      []storage.ImageRecord
 	Total      int
 	Pages      int
@@ -36,19 +47,31 @@ type indexView struct {
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	p := storage.SearchParams{
+		Q:                r.URL.Query().Get("q"),
 		URLContains:      r.URL.Query().Get("url"),
 		PageURLContains:  r.URL.Query().Get("page_url"),
 		FilenameContains: r.URL.Query().Get("filename"),
 		AltContains:      r.URL.Query().Get("alt"),
 		TitleContains:    r.URL.Query().Get("title"),
 		FormatEquals:     r.URL.Query().Get("format"),
+		CrawlID:          r.URL.Query().Get("crawl"),
 		Page:             atoiDefault(r.URL.Query().Get("page"), 1),
 		PageSize:         s.PageSize,
 	}
+	p.DupGroup = atou64(r.URL.Query().Get("dup"))
+	p.CollapseDuplicates = r.URL.Query().Get("collapse") == "1"
 	p.MinWidth = atoiPtr(r.URL.Query().Get("min_w"))
 	p.MaxWidth = atoiPtr(r.URL.Query().Get("max_w"))
 	p.MinHeight = atoiPtr(r.URL.Query().Get("min_h"))
 	p.MaxHeight = atoiPtr(r.URL.Query().Get("max_h"))
+	p.CameraContains = r.URL.Query().Get("camera")
+	p.HasGPS = r.URL.Query().Get("gps") == "1"
+	p.Animated = r.URL.Query().Get("animated") == "1"
+	if c := strings.TrimSpace(r.URL.Query().Get("color")); c != "" {
+		if _, err := storage.ParseHexColor(c); err == nil {
+			p.Color = c
+		}
+	}
 
 	q := r.URL.Query()
 	q.Del("page")
@@ -73,8 +96,11 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	if pages < 1 {
 		pages = 1
 	}
+	// Only feeds the crawl filter; the search results stand on their own.
+	crawls, _ := s.Repo.ListCrawls(ctx, crawlFilterLimit)
 	view := indexView{
 		Params:     p,
+		Crawls:     crawls,
 		Items:      items,
 		Total:      total,
 		Pages:      pages,
@@ -93,7 +119,7 @@ func (s *Server) handleThumb(w http.ResponseWriter, r *http.Request) {
 	}
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
-	mime, blob, err := s.Repo.GetThumb(ctx, id)
+	mime, blob, err := s.Repo.GetThumb(ctx, id, r.URL.Query().Get("size"))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -101,11 +127,38 @@ func (s *Server) handleThumb(w http.ResponseWriter, r *http.Request) {
 	if mime == "" {
 		mime = "application/octet-stream"
 	}
+	writeThumb(w, mime, blob)
+}
+
+// writeThumb serves a thumbnail. Images stored before SVGs were rasterized
+// may still have raw SVG thumbnails; those are sandboxed so that opening
+// one directly cannot run scripts or load anything.
+func writeThumb(w http.ResponseWriter, mime string, blob []byte) {
 	w.Header().Set("Content-Type", mime)
 	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if strings.HasPrefix(mime, "image/svg") {
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	}
 	_, _ = w.Write(blob)
 }
 
+// SrcSet lists the width-bounded thumbnail variants ("<N>w" profiles) of
+// image id for an <img srcset>; square crops have another aspect ratio and
+// are left out, as are variants as wide as one already listed (small images
+// are not enlarged, so their variants can match). It is empty when the
+// image has no such variants.
+func SrcSet(id uint64, thumbs []storage.ThumbVariant) template.Srcset {
+	var parts []string
+	seen := make(map[int]bool)
+	for _, t := range thumbs {
+		if strings.HasSuffix(t.Profile, "w") && !seen[t.Width] {
+			seen[t.Width] = true
+			parts = append(parts, fmt.Sprintf("/thumb?id=%d&size=%s %dw", id, url.QueryEscape(t.Profile), t.Width))
+		}
+	}
+	return template.Srcset(strings.Join(parts, ", "))
+}
+
 func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
 	id := atou64(r.URL.Query().Get("id"))
 	if id == 0 {
@@ -120,9 +173,211 @@ func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
+	// The similar and used-on sections are best-effort; the record alone is still useful.
+	similar, _ := s.Repo.Similar(ctx, id, imageSimilarLimit)
+	usedOn, _ := s.Repo.PagesUsingImage(ctx, rec.URL, graphListLimit)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.Tmpl.ExecuteTemplate(w, "image.html", imageView{ImageRecord: rec, Similar: similar, UsedOn: usedOn})
+}
+
+// imageSimilarLimit is the number of similar images shown on the details page.
+const imageSimilarLimit = 8
+
+type imageView struct {
+	storage.ImageRecord
+	Similar []storage.SimilarImage
+	UsedOn  []storage.PageRecord
+}
+
+// graphListLimit caps the page lists drawn from the link graph.
+const graphListLimit = 200
+
+type pageView struct {
+	Page       storage.PageRecord
+	LinkedFrom []storage.PageRecord
+	Links      []storage.Link
+}
+
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	u := strings.TrimSpace(r.URL.Query().Get("url"))
+	if u == "" {
+		http.Error(w, "missing url", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	page, err := s.Repo.GetPage(ctx, u)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	from, err := s.Repo.PagesLinkingTo(ctx, u, graphListLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	links, err := s.Repo.PageLinks(ctx, page.ID, 1000)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.Tmpl.ExecuteTemplate(w, "page.html", pageView{Page: page, LinkedFrom: from, Links: links})
+}
+
+type similarView struct {
+	Source storage.ImageRecord
+	Items  []storage.SimilarImage
+	Limit  int
+}
+
+func (s *Server) handleSimilar(w http.ResponseWriter, r *http.Request) {
+	id := atou64(r.URL.Query().Get("id"))
+	if id == 0 {
+		http.Error(w, "missing id", http.StatusBadRequest)
+		return
+	}
+	limit := atoiDefault(r.URL.Query().Get("limit"), 48)
+	if limit > 200 {
+		limit = 200
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	src, err := s.Repo.GetImage(ctx, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	items, err := s.Repo.Similar(ctx, id, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.Tmpl.ExecuteTemplate(w, "similar.html", similarView{Source: src, Items: items, Limit: limit})
+}
+
+// crawlFilterLimit is the number of recent crawls offered in the search form.
+const crawlFilterLimit = 50
+
+func (s *Server) handleCrawls(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	crawls, err := s.Repo.ListCrawls(ctx, atoiDefault(r.URL.Query().Get("limit"), 100))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = s.Tmpl.ExecuteTemplate(w, "crawls.html", crawls)
+}
+
+// failureCauses says who is most likely at fault for each failure class
+// (the render.ErrorClass values), in report order.
+var failureCauses = []struct{ Class, Cause string }{
+	{"dns", "site"},
+	{"tls", "site"},
+	{"http_4xx", "site"},
+	{"http_5xx", "site"},
+	{"decode", "site"},
+	{"unsupported", "crawler format support"},
+	{"timeout", "network or crawler timeout"},
+	{"network", "network"},
+	{"oversized", "crawler size limit"},
+	{"robots", "robots.txt policy"},
+	{"other", "unknown"},
+}
+
+type failureClassRow struct {
+	Class string
+	Cause string
+	Count int
+}
+
+type failureHostRow struct {
+	Host    string
+	Total   int
+	ByClass []int // aligned with failuresView.Classes
+}
+
+type failuresView struct {
+	Filter  storage.FailureFilter
+	Crawls  []storage.CrawlRecord
+	Total   int
+	Classes []failureClassRow
+	Hosts   []failureHostRow
+	Recent  []storage.FailureRecord
+}
+
+// failureListLimit caps the recent-failures table.
+const failureListLimit = 200
+
+func (s *Server) handleFailures(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := storage.FailureFilter{
+		CrawlID: strings.TrimSpace(q.Get("crawl")),
+		Class:   strings.TrimSpace(q.Get("class")),
+		Host:    strings.ToLower(strings.TrimSpace(q.Get("host"))),
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	counts, err := s.Repo.FailureCounts(ctx, f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lf := f
+	lf.Limit = failureListLimit
+	recent, err := s.Repo.ListFailures(ctx, lf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The crawl picker is best-effort.
+	crawls, _ := s.Repo.ListCrawls(ctx, crawlFilterLimit)
+
+	v := failuresView{Filter: f, Crawls: crawls, Recent: recent}
+	col := map[string]int{}
+	for _, fc := range failureCauses {
+		col[fc.Class] = len(v.Classes)
+		v.Classes = append(v.Classes, failureClassRow{Class: fc.Class, Cause: fc.Cause})
+	}
+	hostRow := map[string]int{}
+	for _, c := range counts {
+		i, ok := col[c.Class]
+		if !ok {
+			col[c.Class] = len(v.Classes)
+			i = len(v.Classes)
+			v.Classes = append(v.Classes, failureClassRow{Class: c.Class, Cause: "unknown"})
+		}
+		v.Classes[i].Count += c.Count
+		v.Total += c.Count
+
+		h, ok := hostRow[c.Host]
+		if !ok {
+			h = len(v.Hosts)
+			hostRow[c.Host] = h
+			v.Hosts = append(v.Hosts, failureHostRow{Host: c.Host})
+		}
+		v.Hosts[h].Total += c.Count
+	}
+	for i := range v.Hosts {
+		v.Hosts[i].ByClass = make([]int, len(v.Classes))
+	}
+	for _, c := range counts {
+		v.Hosts[hostRow[c.Host]].ByClass[col[c.Class]] += c.Count
+	}
+	sort.SliceStable(v.Hosts, func(i, j int) bool { return v.Hosts[i].Total > v.Hosts[j].Total })
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_ = s.Tmpl.ExecuteTemplate(w, "image.html", rec)
+	_ = s.Tmpl.ExecuteTemplate(w, "failures.html", v)
 }
 
 func atoiDefault(s string, def int) int {